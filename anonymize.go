@@ -0,0 +1,284 @@
+package stackoverflow
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// names of fields that can be listed in AnonymizeConfig.Keep and
+// AnonymizeConfig.Synthesize
+const (
+	FieldUserID          = "UserID"
+	FieldAccountID       = "AccountID"
+	FieldDisplayName     = "DisplayName"
+	FieldAboutMe         = "AboutMe"
+	FieldWebsiteURL      = "WebsiteURL"
+	FieldLocation        = "Location"
+	FieldProfileImageURL = "ProfileImageURL"
+	FieldMentions        = "Mentions"
+)
+
+// AnonymizeConfig describes how to anonymize records
+type AnonymizeConfig struct {
+	// Key is a secret used to pseudonymize ids and names. The same key
+	// always gives the same pseudonyms, so tables anonymized separately
+	// stay consistent
+	Key string
+	// Keep lists fields that are not changed
+	Keep []string
+	// Synthesize lists free-form user fields (AboutMe, WebsiteURL, Location,
+	// ProfileImageURL) that are replaced with fake values instead of
+	// being blanked
+	Synthesize []string
+}
+
+// Anonymizer removes personal information from records. User and account
+// ids are replaced by a keyed permutation so they remain unique and
+// consistent across all tables
+type Anonymizer struct {
+	keep       map[string]bool
+	synthesize map[string]bool
+	key        []byte
+	userIDs    cipher.Block
+	accountIDs cipher.Block
+}
+
+var (
+	// a mention starts at the beginning of text or after whitespace, so
+	// e-mail addresses and code like foo@bar.com are not mentions
+	rxMention = regexp.MustCompile(`(^|\s)@[\p{L}\p{N}_\-.]*[\p{L}\p{N}_]`)
+
+	fakeLocations = []string{"Earth", "Europe", "Asia", "North America", "South America", "Africa", "Oceania"}
+)
+
+// NewAnonymizer returns a new Anonymizer
+func NewAnonymizer(config *AnonymizeConfig) (*Anonymizer, error) {
+	if config.Key == "" {
+		return nil, fmt.Errorf("AnonymizeConfig.Key must be set")
+	}
+	a := &Anonymizer{
+		keep:       map[string]bool{},
+		synthesize: map[string]bool{},
+	}
+	for _, name := range config.Keep {
+		if !isAnonymizeField(name) {
+			return nil, fmt.Errorf("unknown field '%s' in AnonymizeConfig.Keep", name)
+		}
+		a.keep[name] = true
+	}
+	for _, name := range config.Synthesize {
+		switch name {
+		case FieldAboutMe, FieldWebsiteURL, FieldLocation, FieldProfileImageURL:
+			a.synthesize[name] = true
+		default:
+			return nil, fmt.Errorf("field '%s' in AnonymizeConfig.Synthesize can't be synthesized", name)
+		}
+	}
+	sum := sha256.Sum256([]byte(config.Key))
+	a.key = sum[:]
+	// separate keys so that the same number maps to different user and account ids
+	var err error
+	a.userIDs, err = aes.NewCipher(deriveKey(a.key, "userid"))
+	if err != nil {
+		return nil, err
+	}
+	a.accountIDs, err = aes.NewCipher(deriveKey(a.key, "accountid"))
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func isAnonymizeField(name string) bool {
+	switch name {
+	case FieldUserID, FieldAccountID, FieldDisplayName, FieldAboutMe, FieldWebsiteURL, FieldLocation, FieldProfileImageURL, FieldMentions:
+		return true
+	}
+	return false
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)[:16]
+}
+
+// permute is a 4-round Feistel network over 32-bit numbers with AES as
+// round function. We cycle-walk until the result is a positive int32, which
+// makes it a permutation of 1...2^31-1
+func permute(block cipher.Block, id int) int {
+	var in, out [aes.BlockSize]byte
+	x := uint32(id)
+	for {
+		l, r := uint16(x>>16), uint16(x)
+		for round := byte(0); round < 4; round++ {
+			in[0] = round
+			binary.BigEndian.PutUint16(in[1:], r)
+			block.Encrypt(out[:], in[:])
+			l, r = r, l^binary.BigEndian.Uint16(out[:])
+		}
+		x = uint32(l)<<16 | uint32(r)
+		if x > 0 && x < 1<<31 {
+			return int(x)
+		}
+	}
+}
+
+// UserID returns pseudonymized user id. Special ids like -1 (Community user)
+// and 0 (no user) are not changed
+func (a *Anonymizer) UserID(id int) int {
+	if id <= 0 || a.keep[FieldUserID] {
+		return id
+	}
+	return permute(a.userIDs, id)
+}
+
+// AccountID returns pseudonymized account id
+func (a *Anonymizer) AccountID(id int) int {
+	if id <= 0 || a.keep[FieldAccountID] {
+		return id
+	}
+	return permute(a.accountIDs, id)
+}
+
+// DisplayName returns pseudonymized display name of a user with a given id.
+// If user id is not known (e.g. for deleted users), the name is derived
+// from the original name
+func (a *Anonymizer) DisplayName(userID int, name string) string {
+	if name == "" || a.keep[FieldDisplayName] {
+		return name
+	}
+	if userID > 0 {
+		return "user" + strconv.Itoa(a.UserID(userID))
+	}
+	if userID < 0 {
+		// Community and other special users
+		return name
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte("displayname:" + name))
+	return "user-" + hex.EncodeToString(mac.Sum(nil)[:4])
+}
+
+// StripMentions replaces @mentions in comment text with @user
+func (a *Anonymizer) StripMentions(s string) string {
+	if a.keep[FieldMentions] {
+		return s
+	}
+	return rxMention.ReplaceAllString(s, "${1}@user")
+}
+
+// userField returns the value of free-form user field after anonymization
+func (a *Anonymizer) userField(field string, v string, newID int) string {
+	if v == "" || a.keep[field] {
+		return v
+	}
+	if !a.synthesize[field] {
+		return ""
+	}
+	switch field {
+	case FieldAboutMe:
+		return fmt.Sprintf("<p>About user%d</p>", newID)
+	case FieldWebsiteURL:
+		return fmt.Sprintf("https://example.com/users/%d", newID)
+	case FieldLocation:
+		if newID < 0 {
+			newID = -newID
+		}
+		return fakeLocations[newID%len(fakeLocations)]
+	case FieldProfileImageURL:
+		return fmt.Sprintf("https://example.com/users/%d.png", newID)
+	}
+	return ""
+}
+
+// AnonymizeUser anonymizes User record in place
+func (a *Anonymizer) AnonymizeUser(u *User) {
+	u.DisplayName = a.DisplayName(u.ID, u.DisplayName)
+	u.ID = a.UserID(u.ID)
+	u.AccountID = a.AccountID(u.AccountID)
+	u.AboutMe = a.userField(FieldAboutMe, u.AboutMe, u.ID)
+	u.WebsiteURL = a.userField(FieldWebsiteURL, u.WebsiteURL, u.ID)
+	u.Location = a.userField(FieldLocation, u.Location, u.ID)
+	u.ProfileImageURL = a.userField(FieldProfileImageURL, u.ProfileImageURL, u.ID)
+}
+
+// AnonymizePost anonymizes Post record in place
+func (a *Anonymizer) AnonymizePost(p *Post) {
+	p.OwnerDisplayName = a.DisplayName(p.OwnerUserID, p.OwnerDisplayName)
+	p.OwnerUserID = a.UserID(p.OwnerUserID)
+	p.LastEditorDisplayName = a.DisplayName(p.LastEditorUserID, p.LastEditorDisplayName)
+	p.LastEditorUserID = a.UserID(p.LastEditorUserID)
+}
+
+// AnonymizeComment anonymizes Comment record in place
+func (a *Anonymizer) AnonymizeComment(c *Comment) {
+	c.UserDisplayName = a.DisplayName(c.UserID, c.UserDisplayName)
+	c.UserID = a.UserID(c.UserID)
+	c.Text = a.StripMentions(c.Text)
+}
+
+// AnonymizeBadge anonymizes Badge record in place
+func (a *Anonymizer) AnonymizeBadge(b *Badge) {
+	b.UserID = a.UserID(b.UserID)
+}
+
+// AnonymizeVote anonymizes Vote record in place
+func (a *Anonymizer) AnonymizeVote(v *Vote) {
+	v.UserID = a.UserID(v.UserID)
+}
+
+// closeVoter is the subset of JSON in PostHistory.Text that has
+// information about a user who voted to close, reopen, delete etc.
+type closeVoter struct {
+	ID          int    `json:"Id"`
+	DisplayName string `json:"DisplayName"`
+}
+
+// AnonymizePostHistory anonymizes PostHistory record in place
+func (a *Anonymizer) AnonymizePostHistory(h *PostHistory) {
+	h.UserDisplayName = a.DisplayName(h.UserID, h.UserDisplayName)
+	h.UserID = a.UserID(h.UserID)
+	switch h.PostHistoryTypeID {
+	case HistoryPostClosed, HistoryPostReopened, HistoryPostDeleted, HistoryPostUndeleted, HistoryPostLocked, HistoryPostUnlocked:
+		h.Text = a.anonymizeVotersJSON(h.Text)
+	}
+}
+
+// anonymizeVotersJSON anonymizes "Voters" array in JSON text of close,
+// reopen, delete etc. post history entries. Other values are preserved
+func (a *Anonymizer) anonymizeVotersJSON(s string) string {
+	if s == "" {
+		return s
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return s
+	}
+	var voters []closeVoter
+	if err := json.Unmarshal(m["Voters"], &voters); err != nil {
+		return s
+	}
+	for i := range voters {
+		voters[i].DisplayName = a.DisplayName(voters[i].ID, voters[i].DisplayName)
+		voters[i].ID = a.UserID(voters[i].ID)
+	}
+	d, err := json.Marshal(voters)
+	if err != nil {
+		return s
+	}
+	m["Voters"] = d
+	d, err = json.Marshal(m)
+	if err != nil {
+		return s
+	}
+	return string(d)
+}
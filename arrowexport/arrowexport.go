@@ -0,0 +1,253 @@
+// Package arrowexport converts records read with stackoverflow.Reader to
+// Apache Arrow record batches.
+//
+// Arrow schema is derived from Go struct of the record:
+//
+//	int          int64
+//	string       utf8
+//	time.Time    timestamp[ms, tz=UTC], null if zero
+//	[]string     list<utf8>
+//
+// Column names are names from json tags (e.g. "owner_user_id"). Fields
+// with omitempty in json tag are nullable and their zero values are null.
+package arrowexport
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/kjk/stackoverflow"
+)
+
+// DefaultBatchSize is number of rows in a record batch if batch size is 0
+const DefaultBatchSize = 64 * 1024
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// column describes how to append a struct field to arrow builder
+type column struct {
+	index    int
+	field    arrow.Field
+	appendFn func(b array.Builder, v reflect.Value, nullable bool)
+}
+
+func appendInt(b array.Builder, v reflect.Value, nullable bool) {
+	n := v.Int()
+	if nullable && n == 0 {
+		b.AppendNull()
+		return
+	}
+	b.(*array.Int64Builder).Append(n)
+}
+
+func appendString(b array.Builder, v reflect.Value, nullable bool) {
+	s := v.String()
+	if nullable && s == "" {
+		b.AppendNull()
+		return
+	}
+	b.(*array.StringBuilder).Append(s)
+}
+
+func appendTime(b array.Builder, v reflect.Value, nullable bool) {
+	t := v.Interface().(time.Time)
+	if t.IsZero() {
+		b.AppendNull()
+		return
+	}
+	ms := t.UnixNano() / int64(time.Millisecond)
+	b.(*array.TimestampBuilder).Append(arrow.Timestamp(ms))
+}
+
+func appendStrings(b array.Builder, v reflect.Value, nullable bool) {
+	n := v.Len()
+	if nullable && n == 0 {
+		b.AppendNull()
+		return
+	}
+	lb := b.(*array.ListBuilder)
+	lb.Append(true)
+	vb := lb.ValueBuilder().(*array.StringBuilder)
+	for i := 0; i < n; i++ {
+		vb.Append(v.Index(i).String())
+	}
+}
+
+// columnsOf returns columns for fields of struct type t
+func columnsOf(t reflect.Type) ([]column, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("arrowexport: %s is not a struct", t)
+	}
+	var res []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		name := f.Name
+		nullable := false
+		if tag := f.Tag.Get("json"); tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					nullable = true
+				}
+			}
+		}
+		col := column{index: i}
+		var typ arrow.DataType
+		switch {
+		case f.Type == timeType:
+			typ = arrow.FixedWidthTypes.Timestamp_ms
+			nullable = true
+			col.appendFn = appendTime
+		case f.Type.Kind() == reflect.Int:
+			typ = arrow.PrimitiveTypes.Int64
+			col.appendFn = appendInt
+		case f.Type.Kind() == reflect.String:
+			typ = arrow.BinaryTypes.String
+			col.appendFn = appendString
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.String:
+			typ = arrow.ListOf(arrow.BinaryTypes.String)
+			col.appendFn = appendStrings
+		default:
+			return nil, fmt.Errorf("arrowexport: field %s.%s has unsupported type %s", t.Name(), f.Name, f.Type)
+		}
+		col.field = arrow.Field{Name: name, Type: typ, Nullable: nullable}
+		res = append(res, col)
+	}
+	return res, nil
+}
+
+func schemaOf(cols []column) *arrow.Schema {
+	fields := make([]arrow.Field, len(cols))
+	for i, col := range cols {
+		fields[i] = col.field
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// SchemaOf returns arrow schema for a record e.g. &stackoverflow.Post{}
+func SchemaOf(rec interface{}) (*arrow.Schema, error) {
+	cols, err := columnsOf(reflect.TypeOf(rec))
+	if err != nil {
+		return nil, err
+	}
+	return schemaOf(cols), nil
+}
+
+// RecordReader reads records from stackoverflow.Reader in batches of
+// arrow records. It implements array.RecordReader
+type RecordReader struct {
+	refCount  int64
+	r         *stackoverflow.Reader
+	batchSize int
+	schema    *arrow.Schema
+	cols      []column
+	b         *array.RecordBuilder
+	rec       array.Record
+	err       error
+}
+
+// NewRecordReader returns a RecordReader reading from r. Each record has
+// at most batchSize rows (DefaultBatchSize if 0). If mem is nil, Go
+// allocator is used
+func NewRecordReader(r *stackoverflow.Reader, batchSize int, mem memory.Allocator) (*RecordReader, error) {
+	cols, err := columnsOf(reflect.TypeOf(r.Record()))
+	if err != nil {
+		return nil, err
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if mem == nil {
+		mem = memory.NewGoAllocator()
+	}
+	schema := schemaOf(cols)
+	return &RecordReader{
+		refCount:  1,
+		r:         r,
+		batchSize: batchSize,
+		schema:    schema,
+		cols:      cols,
+		b:         array.NewRecordBuilder(mem, schema),
+	}, nil
+}
+
+// Retain increases reference count
+func (rr *RecordReader) Retain() {
+	atomic.AddInt64(&rr.refCount, 1)
+}
+
+// Release decreases reference count. When it reaches 0, memory of
+// the current record is released
+func (rr *RecordReader) Release() {
+	if atomic.AddInt64(&rr.refCount, -1) != 0 {
+		return
+	}
+	if rr.rec != nil {
+		rr.rec.Release()
+		rr.rec = nil
+	}
+	if rr.b != nil {
+		rr.b.Release()
+		rr.b = nil
+	}
+}
+
+// Schema returns schema of records
+func (rr *RecordReader) Schema() *arrow.Schema {
+	return rr.schema
+}
+
+// Next reads next batch of rows. Returns false at the end or on error
+func (rr *RecordReader) Next() bool {
+	if rr.rec != nil {
+		rr.rec.Release()
+		rr.rec = nil
+	}
+	if rr.err != nil || rr.b == nil {
+		return false
+	}
+	n := 0
+	for n < rr.batchSize && rr.r.Next() {
+		v := reflect.ValueOf(rr.r.Record()).Elem()
+		for i, col := range rr.cols {
+			col.appendFn(rr.b.Field(i), v.Field(col.index), col.field.Nullable)
+		}
+		n++
+	}
+	rr.err = rr.r.Err()
+	if n == 0 {
+		return false
+	}
+	rr.rec = rr.b.NewRecord()
+	return true
+}
+
+// Record returns current batch. It's valid until next call to Next.
+// Call Retain on it to keep it longer
+func (rr *RecordReader) Record() array.Record {
+	return rr.rec
+}
+
+// Err returns error from reading records, if any
+func (rr *RecordReader) Err() error {
+	return rr.err
+}
@@ -10,13 +10,13 @@ import (
 
 // Badge tells which badge a given user has
 type Badge struct {
-	ID     int
-	UserID int
-	Name   string
-	Date   time.Time
+	ID     int       `json:"badge_id"`
+	UserID int       `json:"user_id"`
+	Name   string    `json:"name"`
+	Date   time.Time `json:"date"`
 }
 
-func decodeBadgeAttr(attr xml.Attr, b *Badge) error {
+func decodeBadgeAttr(attr xml.Attr, b *Badge, in *Interner) error {
 	var err error
 	name := strings.ToLower(attr.Name.Local)
 	v := attr.Value
@@ -26,7 +26,7 @@ func decodeBadgeAttr(attr xml.Attr, b *Badge) error {
 	case "userid":
 		b.UserID, err = strconv.Atoi(v)
 	case "name":
-		b.Name = v
+		b.Name = in.String(v)
 	case "date":
 		b.Date, err = decodeTime(v)
 	default:
@@ -35,15 +35,22 @@ func decodeBadgeAttr(attr xml.Attr, b *Badge) error {
 	return err
 }
 
-func decodeBadgeRow(t xml.Token, b *Badge) error {
+func decodeBadgeRow(t xml.Token, b *Badge, in *Interner) error {
 	// have been checked before that this is "row" element
 	*b = Badge{}
 	e, _ := t.(xml.StartElement)
 	for _, attr := range e.Attr {
-		err := decodeBadgeAttr(attr, b)
+		err := decodeBadgeAttr(attr, b, in)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+func encodeBadgeRow(rw *rowWriter, b *Badge) {
+	rw.int("Id", b.ID)
+	rw.intOpt("UserId", b.UserID)
+	rw.str("Name", b.Name)
+	rw.timeOpt("Date", b.Date)
+}
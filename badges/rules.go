@@ -0,0 +1,374 @@
+package badges
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+)
+
+const day = 24 * time.Hour
+
+// Award is a badge given to a user, computed by a Rule
+type Award struct {
+	UserID int
+	Name   string
+	Date   time.Time
+	// post the badge was awarded for, 0 if not for a single post
+	PostID int
+}
+
+// Context is data needed by rules
+type Context struct {
+	// needs Posts and Votes tables
+	DS     *stackoverflow.Dataset
+	Scores *stackoverflow.ScoreTimelines
+
+	// answer id -> date it was accepted
+	accepted map[int]time.Time
+	tags     map[string]bool
+}
+
+// NewContext returns a context for computing badges from a dataset with
+// Posts and Votes
+func NewContext(ds *stackoverflow.Dataset) *Context {
+	c := &Context{
+		DS:       ds,
+		Scores:   stackoverflow.NewScoreTimelines(),
+		accepted: map[int]time.Time{},
+		tags:     map[string]bool{},
+	}
+	for i := range ds.Votes {
+		v := &ds.Votes[i]
+		c.Scores.AddVote(v)
+		if v.VoteTypeID == stackoverflow.VoteAcceptedByOriginator {
+			c.accepted[v.PostID] = v.CreationDate
+		}
+	}
+	for i := range ds.Posts {
+		for _, tag := range ds.Posts[i].Tags {
+			c.tags[tag] = true
+		}
+	}
+	return c
+}
+
+// ScoreReached returns date when score of a post first reached a given
+// value or zero time if it never did
+func (c *Context) ScoreReached(postID int, score int) time.Time {
+	t := c.Scores.Post(postID)
+	if t == nil {
+		return time.Time{}
+	}
+	for _, d := range t.Days {
+		if d.Score >= score {
+			return d.Date
+		}
+	}
+	return time.Time{}
+}
+
+// AcceptedDate returns date when an answer was accepted or zero time
+func (c *Context) AcceptedDate(answerID int) time.Time {
+	return c.accepted[answerID]
+}
+
+// IsTag returns true if name is a tag of some question
+func (c *Context) IsTag(name string) bool {
+	return c.tags[name]
+}
+
+// Rule computes awards of a badge
+type Rule struct {
+	Name string
+	// true for tag badges, whose awards are named after a tag
+	TagBased bool
+	Awards   func(c *Context) []Award
+}
+
+// Rules are rules of common badges
+var Rules = []*Rule{
+	{Name: "Student", Awards: firstPostAwards("Student", stackoverflow.PostQuestion, 1)},
+	{Name: "Teacher", Awards: firstPostAwards("Teacher", stackoverflow.PostAnswer, 1)},
+	{Name: "Nice Answer", Awards: answerScoreAwards("Nice Answer", 10)},
+	{Name: "Good Answer", Awards: answerScoreAwards("Good Answer", 25)},
+	{Name: "Great Answer", Awards: answerScoreAwards("Great Answer", 100)},
+	{Name: "Enlightened", Awards: enlightenedAwards},
+	{Name: "Necromancer", Awards: necromancerAwards},
+	{Name: "tag badges", TagBased: true, Awards: tagAwards},
+}
+
+// owns returns true if a badge with a given name is computed by the rule
+func (r *Rule) owns(c *Context, name string) bool {
+	if r.TagBased {
+		return c.IsTag(name)
+	}
+	return r.Name == name
+}
+
+// firstPostAwards awards a badge once, for the first post of a given type
+// that reached a score
+func firstPostAwards(name string, postType int, score int) func(c *Context) []Award {
+	return func(c *Context) []Award {
+		first := map[int]*Award{}
+		for i := range c.DS.Posts {
+			p := &c.DS.Posts[i]
+			if p.PostTypeID != postType || p.OwnerUserID <= 0 {
+				continue
+			}
+			d := c.ScoreReached(p.ID, score)
+			if d.IsZero() {
+				continue
+			}
+			a := first[p.OwnerUserID]
+			if a == nil || d.Before(a.Date) {
+				first[p.OwnerUserID] = &Award{UserID: p.OwnerUserID, Name: name, Date: d, PostID: p.ID}
+			}
+		}
+		var res []Award
+		for _, a := range first {
+			res = append(res, *a)
+		}
+		return res
+	}
+}
+
+// answerScoreAwards awards a badge for every answer that reached a score
+func answerScoreAwards(name string, score int) func(c *Context) []Award {
+	return func(c *Context) []Award {
+		var res []Award
+		for i := range c.DS.Posts {
+			p := &c.DS.Posts[i]
+			if p.PostTypeID != stackoverflow.PostAnswer || p.OwnerUserID <= 0 {
+				continue
+			}
+			if d := c.ScoreReached(p.ID, score); !d.IsZero() {
+				res = append(res, Award{UserID: p.OwnerUserID, Name: name, Date: d, PostID: p.ID})
+			}
+		}
+		return res
+	}
+}
+
+// enlightenedAwards awards Enlightened for the first answer to a question
+// that was accepted and has score of 10 or more
+func enlightenedAwards(c *Context) []Award {
+	var res []Award
+	for i := range c.DS.Posts {
+		q := &c.DS.Posts[i]
+		a := c.DS.AcceptedAnswer(q)
+		if q.PostTypeID != stackoverflow.PostQuestion || a == nil || a.OwnerUserID <= 0 {
+			continue
+		}
+		isFirst := true
+		for _, other := range c.DS.Answers(q.ID) {
+			if other.CreationDate.Before(a.CreationDate) {
+				isFirst = false
+				break
+			}
+		}
+		d := c.ScoreReached(a.ID, 10)
+		if !isFirst || d.IsZero() {
+			continue
+		}
+		if accepted := c.AcceptedDate(a.ID); accepted.After(d) {
+			d = accepted
+		}
+		res = append(res, Award{UserID: a.OwnerUserID, Name: "Enlightened", Date: d, PostID: a.ID})
+	}
+	return res
+}
+
+// necromancerAwards awards Necromancer for an answer posted more than 60
+// days after the question, with score of 5 or more
+func necromancerAwards(c *Context) []Award {
+	var res []Award
+	for i := range c.DS.Posts {
+		a := &c.DS.Posts[i]
+		if a.PostTypeID != stackoverflow.PostAnswer || a.OwnerUserID <= 0 {
+			continue
+		}
+		q := c.DS.Question(a)
+		if q == nil || a.CreationDate.Sub(q.CreationDate) <= 60*day {
+			continue
+		}
+		if d := c.ScoreReached(a.ID, 5); !d.IsZero() {
+			res = append(res, Award{UserID: a.OwnerUserID, Name: "Necromancer", Date: d, PostID: a.ID})
+		}
+	}
+	return res
+}
+
+// tag badges: bronze, silver and gold, for total score and number of
+// non community wiki answers in a tag
+var tagLevels = []struct {
+	score   int
+	answers int
+}{{100, 20}, {400, 80}, {1000, 200}}
+
+type tagEvent struct {
+	date    time.Time
+	score   int
+	answers int
+}
+
+type userTag struct {
+	userID int
+	tag    string
+}
+
+func tagAwards(c *Context) []Award {
+	byUserTag := map[userTag][]*stackoverflow.Post{}
+	for i := range c.DS.Posts {
+		a := &c.DS.Posts[i]
+		if a.PostTypeID != stackoverflow.PostAnswer || a.OwnerUserID <= 0 || !a.CommunityOwnedDate.IsZero() {
+			continue
+		}
+		q := c.DS.Question(a)
+		if q == nil {
+			continue
+		}
+		for _, tag := range q.Tags {
+			k := userTag{a.OwnerUserID, tag}
+			byUserTag[k] = append(byUserTag[k], a)
+		}
+	}
+	var res []Award
+	for k, answers := range byUserTag {
+		if len(answers) < tagLevels[0].answers {
+			continue
+		}
+		var events []tagEvent
+		for _, a := range answers {
+			events = append(events, tagEvent{date: a.CreationDate, answers: 1})
+			if t := c.Scores.Post(a.ID); t != nil {
+				for _, d := range t.Days {
+					events = append(events, tagEvent{date: d.Date, score: d.Up - d.Down})
+				}
+			}
+		}
+		sort.SliceStable(events, func(i, j int) bool { return events[i].date.Before(events[j].date) })
+		score, n, level := 0, 0, 0
+		for _, e := range events {
+			score += e.score
+			n += e.answers
+			for level < len(tagLevels) && score >= tagLevels[level].score && n >= tagLevels[level].answers {
+				res = append(res, Award{UserID: k.userID, Name: k.tag, Date: e.date.Truncate(day)})
+				level++
+			}
+		}
+	}
+	return res
+}
+
+// Recompute returns awards computed by rules, sorted by date
+func Recompute(c *Context, rules []*Rule) []Award {
+	var res []Award
+	for _, r := range rules {
+		res = append(res, r.Awards(c)...)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := &res[i], &res[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.Name < b.Name
+	})
+	return res
+}
+
+// Mismatch is a badge of a user that was awarded a different number of
+// times or on a different day than computed
+type Mismatch struct {
+	UserID int
+	Name   string
+	// number of awards in Badges.xml and computed
+	Expected int
+	Computed int
+	// dates of the first award
+	ExpectedDate time.Time
+	ComputedDate time.Time
+}
+
+type userBadge struct {
+	userID int
+	name   string
+}
+
+type badgeCounts struct {
+	expected, computed         int
+	expectedDate, computedDate time.Time
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+	return a
+}
+
+// Compare compares awards computed by rules with badges from Badges.xml.
+// Only badges that rules compute are compared. Dates are compared by day.
+// Mismatches are sorted by badge name and user
+func Compare(c *Context, rules []*Rule, computed []Award, badges []stackoverflow.Badge) []*Mismatch {
+	m := map[userBadge]*badgeCounts{}
+	get := func(userID int, name string) *badgeCounts {
+		k := userBadge{userID, name}
+		bc := m[k]
+		if bc == nil {
+			bc = &badgeCounts{}
+			m[k] = bc
+		}
+		return bc
+	}
+	for i := range computed {
+		a := &computed[i]
+		bc := get(a.UserID, a.Name)
+		bc.computed++
+		bc.computedDate = earlier(bc.computedDate, a.Date.Truncate(day))
+	}
+	owned := map[string]bool{}
+	for i := range badges {
+		b := &badges[i]
+		isOwned, ok := owned[b.Name]
+		if !ok {
+			for _, r := range rules {
+				if r.owns(c, b.Name) {
+					isOwned = true
+					break
+				}
+			}
+			owned[b.Name] = isOwned
+		}
+		if !isOwned {
+			continue
+		}
+		bc := get(b.UserID, b.Name)
+		bc.expected++
+		bc.expectedDate = earlier(bc.expectedDate, b.Date.Truncate(day))
+	}
+	var res []*Mismatch
+	for k, bc := range m {
+		if bc.expected == bc.computed && bc.expectedDate.Equal(bc.computedDate) {
+			continue
+		}
+		res = append(res, &Mismatch{
+			UserID:       k.userID,
+			Name:         k.name,
+			Expected:     bc.expected,
+			Computed:     bc.computed,
+			ExpectedDate: bc.expectedDate,
+			ComputedDate: bc.computedDate,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].UserID < res[j].UserID
+	})
+	return res
+}
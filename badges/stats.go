@@ -0,0 +1,99 @@
+// Package badges analyzes badges from Badges.xml and recomputes common
+// badges from posts and votes.
+package badges
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+)
+
+// MonthCount is number of awards in a month
+type MonthCount struct {
+	Month time.Time
+	Count int
+}
+
+// Stats is statistics of awards of a badge
+type Stats struct {
+	Name string
+	// number of awards
+	Count int
+	// number of distinct users who have the badge
+	Users int
+	// fraction of all users who have the badge
+	Rarity float64
+	// date of the first award
+	First time.Time
+	// first users who got the badge, in the order of awards
+	FirstRecipients []int
+	// number of awards per month, sorted by month
+	Timeline []MonthCount
+	// median and mean time from signup to the first award of the badge,
+	// for users with known signup date
+	MedianTimeToBadge time.Duration
+	MeanTimeToBadge   time.Duration
+}
+
+// Analyze returns statistics of badges, sorted by number of users, rarest
+// first. signup returns creation date of a user (zero if not known), nUsers
+// is number of all users, for Rarity. nFirst is number of FirstRecipients
+func Analyze(badges []stackoverflow.Badge, signup func(userID int) time.Time, nUsers int, nFirst int) []*Stats {
+	byName := map[string][]*stackoverflow.Badge{}
+	for i := range badges {
+		b := &badges[i]
+		byName[b.Name] = append(byName[b.Name], b)
+	}
+	var res []*Stats
+	for name, awards := range byName {
+		sort.SliceStable(awards, func(i, j int) bool { return awards[i].Date.Before(awards[j].Date) })
+		s := &Stats{
+			Name:  name,
+			Count: len(awards),
+			First: awards[0].Date,
+		}
+		seen := map[int]bool{}
+		var durations []time.Duration
+		var month time.Time
+		for _, b := range awards {
+			m := time.Date(b.Date.Year(), b.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+			if len(s.Timeline) == 0 || !m.Equal(month) {
+				s.Timeline = append(s.Timeline, MonthCount{Month: m})
+				month = m
+			}
+			s.Timeline[len(s.Timeline)-1].Count++
+			if seen[b.UserID] {
+				continue
+			}
+			seen[b.UserID] = true
+			if len(s.FirstRecipients) < nFirst {
+				s.FirstRecipients = append(s.FirstRecipients, b.UserID)
+			}
+			if t := signup(b.UserID); !t.IsZero() && !b.Date.Before(t) {
+				durations = append(durations, b.Date.Sub(t))
+			}
+		}
+		s.Users = len(seen)
+		if nUsers > 0 {
+			s.Rarity = float64(s.Users) / float64(nUsers)
+		}
+		if len(durations) > 0 {
+			sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+			s.MedianTimeToBadge = durations[len(durations)/2]
+			var sum time.Duration
+			for _, d := range durations {
+				sum += d
+			}
+			s.MeanTimeToBadge = sum / time.Duration(len(durations))
+		}
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Users != res[j].Users {
+			return res[i].Users < res[j].Users
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
@@ -0,0 +1,403 @@
+// Package blobstore implements an append-only store for texts (e.g. Post.Body,
+// Comment.Text or User.AboutMe) with random access by reference.
+//
+// Texts are appended to blocks. Each block can be compressed. The file
+// has the following layout:
+//
+//	magic (8 bytes)
+//	blocks
+//	index: for each block, offset (8 bytes), stored size (4 bytes), size (4 bytes)
+//	footer: index offset (8 bytes), number of blocks (4 bytes), compression (4 bytes), magic (8 bytes)
+//
+// Inside a block each text is stored as uvarint-encoded length followed by
+// text bytes. All numbers are little-endian.
+package blobstore
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	magic = "SOBLOBS1"
+
+	// DefaultBlockSize is the size of uncompressed block if Options.BlockSize is 0
+	DefaultBlockSize = 64 * 1024
+
+	compressionNone  = 0
+	compressionFlate = 1
+
+	indexEntrySize = 16
+	footerSize     = 24
+
+	// how many decompressed blocks Reader caches
+	blockCacheSize = 16
+)
+
+var (
+	// ErrInvalidRef is returned by Reader.Get for references that are not in the store
+	ErrInvalidRef = errors.New("blobstore: invalid reference")
+	// ErrInvalidFormat is returned when opening a file that is not a valid store
+	ErrInvalidFormat = errors.New("blobstore: invalid file format")
+)
+
+// Ref is a reference to a text in the store. It encodes index of the block
+// in upper 32 bits and offset of text inside uncompressed block in lower 32 bits
+type Ref uint64
+
+func newRef(block int, offset int) Ref {
+	return Ref(uint64(block)<<32 | uint64(offset))
+}
+
+// Block returns index of the block
+func (r Ref) Block() int {
+	return int(r >> 32)
+}
+
+// Offset returns offset of the text inside uncompressed block
+func (r Ref) Offset() int {
+	return int(uint32(r))
+}
+
+// String returns decimal representation of the reference, which can be parsed with ParseRef
+func (r Ref) String() string {
+	return strconv.FormatUint(uint64(r), 10)
+}
+
+// ParseRef parses reference returned by Ref.String()
+func ParseRef(s string) (Ref, error) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("blobstore: invalid reference '%s'", s)
+	}
+	return Ref(n), nil
+}
+
+// Options for Writer
+type Options struct {
+	// BlockSize is approximate size of uncompressed block. Texts are not
+	// split across blocks so a block with a large text is bigger
+	BlockSize int
+	// Compress tells to compress blocks. Compressed stores are smaller
+	// but reading a text requires decompressing its block
+	Compress bool
+}
+
+type blockInfo struct {
+	offset     int64
+	storedSize int
+	size       int
+}
+
+// Writer appends texts to a store
+type Writer struct {
+	w         io.Writer
+	bw        *bufio.Writer
+	opts      Options
+	block     bytes.Buffer
+	compBuf   bytes.Buffer
+	flateW    *flate.Writer
+	blocks    []blockInfo
+	pos       int64
+	varintBuf [binary.MaxVarintLen64]byte
+	err       error
+}
+
+// Create creates a store file
+func Create(path string, opts *Options) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(f, opts)
+}
+
+// NewWriter returns a Writer writing store to w. If w is io.Closer, it's
+// closed by Writer.Close
+func NewWriter(w io.Writer, opts *Options) (*Writer, error) {
+	res := &Writer{
+		w:  w,
+		bw: bufio.NewWriter(w),
+	}
+	if opts != nil {
+		res.opts = *opts
+	}
+	if res.opts.BlockSize <= 0 {
+		res.opts.BlockSize = DefaultBlockSize
+	}
+	if res.opts.Compress {
+		var err error
+		res.flateW, err = flate.NewWriter(&res.compBuf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+	}
+	res.write([]byte(magic))
+	return res, res.err
+}
+
+func (w *Writer) write(d []byte) {
+	if w.err != nil {
+		return
+	}
+	var n int
+	n, w.err = w.bw.Write(d)
+	w.pos += int64(n)
+}
+
+func (w *Writer) flushBlock() {
+	if w.block.Len() == 0 || w.err != nil {
+		return
+	}
+	d := w.block.Bytes()
+	if w.opts.Compress {
+		w.compBuf.Reset()
+		w.flateW.Reset(&w.compBuf)
+		w.flateW.Write(d)
+		w.err = w.flateW.Close()
+		d = w.compBuf.Bytes()
+	}
+	bi := blockInfo{
+		offset:     w.pos,
+		storedSize: len(d),
+		size:       w.block.Len(),
+	}
+	w.blocks = append(w.blocks, bi)
+	w.write(d)
+	w.block.Reset()
+}
+
+// Add appends a text to the store and returns reference to it
+func (w *Writer) Add(s string) (Ref, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.bw == nil {
+		return 0, os.ErrClosed
+	}
+	n := binary.PutUvarint(w.varintBuf[:], uint64(len(s)))
+	if w.block.Len() > 0 && w.block.Len()+n+len(s) > w.opts.BlockSize {
+		w.flushBlock()
+	}
+	ref := newRef(len(w.blocks), w.block.Len())
+	w.block.Write(w.varintBuf[:n])
+	w.block.WriteString(s)
+	return ref, w.err
+}
+
+// Close writes remaining data and index and closes underlying writer
+// if it's io.Closer
+func (w *Writer) Close() error {
+	if w.bw == nil {
+		return w.err
+	}
+	w.flushBlock()
+	indexOffset := w.pos
+	var buf [footerSize]byte
+	for _, bi := range w.blocks {
+		binary.LittleEndian.PutUint64(buf[0:], uint64(bi.offset))
+		binary.LittleEndian.PutUint32(buf[8:], uint32(bi.storedSize))
+		binary.LittleEndian.PutUint32(buf[12:], uint32(bi.size))
+		w.write(buf[:indexEntrySize])
+	}
+	compression := compressionNone
+	if w.opts.Compress {
+		compression = compressionFlate
+	}
+	binary.LittleEndian.PutUint64(buf[0:], uint64(indexOffset))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(w.blocks)))
+	binary.LittleEndian.PutUint32(buf[12:], uint32(compression))
+	copy(buf[16:], magic)
+	w.write(buf[:])
+	if w.err == nil {
+		w.err = w.bw.Flush()
+	}
+	if c, ok := w.w.(io.Closer); ok {
+		err := c.Close()
+		if w.err == nil {
+			w.err = err
+		}
+	}
+	w.bw = nil
+	return w.err
+}
+
+// Reader reads texts from a store. It's safe for concurrent use
+type Reader struct {
+	r           io.ReaderAt
+	closer      io.Closer
+	blocks      []blockInfo
+	compression int
+
+	mu    sync.Mutex
+	cache map[int][]byte
+	// order in which blocks were added to cache, for eviction
+	cacheOrder []int
+}
+
+// Open opens a store file
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r, err := NewReader(f, st.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// NewReader returns a Reader for a store of a given size
+func NewReader(ra io.ReaderAt, size int64) (*Reader, error) {
+	if size < int64(len(magic)+footerSize) {
+		return nil, ErrInvalidFormat
+	}
+	var buf [footerSize]byte
+	if _, err := ra.ReadAt(buf[:len(magic)], 0); err != nil {
+		return nil, err
+	}
+	if string(buf[:len(magic)]) != magic {
+		return nil, ErrInvalidFormat
+	}
+	if _, err := ra.ReadAt(buf[:], size-footerSize); err != nil {
+		return nil, err
+	}
+	if string(buf[16:]) != magic {
+		return nil, ErrInvalidFormat
+	}
+	indexOffset := int64(binary.LittleEndian.Uint64(buf[0:]))
+	nBlocks := int(binary.LittleEndian.Uint32(buf[8:]))
+	r := &Reader{
+		r:           ra,
+		compression: int(binary.LittleEndian.Uint32(buf[12:])),
+		cache:       map[int][]byte{},
+	}
+	if r.compression != compressionNone && r.compression != compressionFlate {
+		return nil, fmt.Errorf("blobstore: unknown compression %d", r.compression)
+	}
+	if indexOffset+int64(nBlocks)*indexEntrySize != size-footerSize {
+		return nil, ErrInvalidFormat
+	}
+	index := make([]byte, nBlocks*indexEntrySize)
+	if _, err := ra.ReadAt(index, indexOffset); err != nil {
+		return nil, err
+	}
+	r.blocks = make([]blockInfo, nBlocks)
+	for i := range r.blocks {
+		d := index[i*indexEntrySize:]
+		r.blocks[i] = blockInfo{
+			offset:     int64(binary.LittleEndian.Uint64(d)),
+			storedSize: int(binary.LittleEndian.Uint32(d[8:])),
+			size:       int(binary.LittleEndian.Uint32(d[12:])),
+		}
+	}
+	return r, nil
+}
+
+// Close closes the file if Reader was created with Open
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	err := r.closer.Close()
+	r.closer = nil
+	return err
+}
+
+// readBlock returns uncompressed block, from cache if possible
+func (r *Reader) readBlock(idx int) ([]byte, error) {
+	r.mu.Lock()
+	d, ok := r.cache[idx]
+	r.mu.Unlock()
+	if ok {
+		return d, nil
+	}
+	bi := r.blocks[idx]
+	d = make([]byte, bi.storedSize)
+	if _, err := r.r.ReadAt(d, bi.offset); err != nil {
+		return nil, err
+	}
+	if r.compression == compressionFlate {
+		fr := flate.NewReader(bytes.NewReader(d))
+		var err error
+		d, err = ioutil.ReadAll(fr)
+		fr.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(d) != bi.size {
+		return nil, ErrInvalidFormat
+	}
+	r.mu.Lock()
+	if len(r.cacheOrder) >= blockCacheSize {
+		delete(r.cache, r.cacheOrder[0])
+		r.cacheOrder = r.cacheOrder[1:]
+	}
+	r.cache[idx] = d
+	r.cacheOrder = append(r.cacheOrder, idx)
+	r.mu.Unlock()
+	return d, nil
+}
+
+// getUncompressed reads the text directly from the file, without reading
+// the whole block
+func (r *Reader) getUncompressed(bi blockInfo, offset int) (string, error) {
+	var buf [binary.MaxVarintLen64]byte
+	n := len(buf)
+	if rem := bi.size - offset; rem < n {
+		n = rem
+	}
+	if _, err := r.r.ReadAt(buf[:n], bi.offset+int64(offset)); err != nil {
+		return "", err
+	}
+	size, nLen := binary.Uvarint(buf[:n])
+	// compare as uint64 because size from a corrupted file can overflow int
+	if nLen <= 0 || size > uint64(bi.size-offset-nLen) {
+		return "", ErrInvalidRef
+	}
+	d := make([]byte, size)
+	if _, err := r.r.ReadAt(d, bi.offset+int64(offset+nLen)); err != nil {
+		return "", err
+	}
+	return string(d), nil
+}
+
+// Get returns text for a given reference
+func (r *Reader) Get(ref Ref) (string, error) {
+	idx := ref.Block()
+	offset := ref.Offset()
+	if idx >= len(r.blocks) || offset >= r.blocks[idx].size {
+		return "", ErrInvalidRef
+	}
+	if r.compression == compressionNone {
+		return r.getUncompressed(r.blocks[idx], offset)
+	}
+	d, err := r.readBlock(idx)
+	if err != nil {
+		return "", err
+	}
+	size, nLen := binary.Uvarint(d[offset:])
+	if nLen <= 0 || size > uint64(len(d)-offset-nLen) {
+		return "", ErrInvalidRef
+	}
+	start := offset + nLen
+	return string(d[start : start+int(size)]), nil
+}
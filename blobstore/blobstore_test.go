@@ -0,0 +1,82 @@
+package blobstore
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testTexts() []string {
+	texts := []string{"", "a", "hello world", strings.Repeat("large text ", 20000)}
+	for i := 0; i < 2000; i++ {
+		texts = append(texts, fmt.Sprintf("<p>text number %d</p>", i))
+	}
+	return texts
+}
+
+func writeStore(t *testing.T, texts []string, opts *Options) ([]byte, []Ref) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, opts)
+	if err != nil {
+		t.Fatalf("NewWriter() failed with %s", err)
+	}
+	var refs []Ref
+	for _, s := range texts {
+		ref, err := w.Add(s)
+		if err != nil {
+			t.Fatalf("Add() failed with %s", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close() failed with %s", err)
+	}
+	return buf.Bytes(), refs
+}
+
+func TestRoundTrip(t *testing.T) {
+	texts := testTexts()
+	for _, compress := range []bool{false, true} {
+		d, refs := writeStore(t, texts, &Options{BlockSize: 4096, Compress: compress})
+		r, err := NewReader(bytes.NewReader(d), int64(len(d)))
+		if err != nil {
+			t.Fatalf("compress: %v, NewReader() failed with %s", compress, err)
+		}
+		if refs[len(refs)-1].Block() == 0 {
+			t.Fatalf("compress: %v, expected more than one block", compress)
+		}
+		// read in reverse order so that blocks are not read sequentially
+		for i := len(texts) - 1; i >= 0; i-- {
+			got, err := r.Get(refs[i])
+			if err != nil {
+				t.Fatalf("compress: %v, Get(%s) failed with %s", compress, refs[i], err)
+			}
+			if got != texts[i] {
+				t.Fatalf("compress: %v, text %d is different", compress, i)
+			}
+			ref, err := ParseRef(refs[i].String())
+			if err != nil || ref != refs[i] {
+				t.Fatalf("ParseRef(%s) returned %s, %v", refs[i], ref, err)
+			}
+		}
+		if _, err = r.Get(newRef(len(refs)+100, 0)); err != ErrInvalidRef {
+			t.Fatalf("compress: %v, expected ErrInvalidRef for block out of range, got %v", compress, err)
+		}
+	}
+}
+
+func TestCorruptedSize(t *testing.T) {
+	texts := []string{strings.Repeat("x", 64), "y"}
+	d, refs := writeStore(t, texts, nil)
+	// overwrite length of the first text, right after magic, with a
+	// uvarint too large for int
+	copy(d[len(magic):], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	r, err := NewReader(bytes.NewReader(d), int64(len(d)))
+	if err != nil {
+		t.Fatalf("NewReader() failed with %s", err)
+	}
+	if _, err = r.Get(refs[0]); err != ErrInvalidRef {
+		t.Fatalf("expected ErrInvalidRef, got %v", err)
+	}
+}
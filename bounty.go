@@ -0,0 +1,396 @@
+package stackoverflow
+
+import (
+	"sort"
+	"time"
+)
+
+// bountyWindow is the longest time between start and close of a bounty:
+// 7 days of bounty period and 24 hours of grace period
+const bountyWindow = 8 * 24 * time.Hour
+
+// BountyState is a state of a bounty
+type BountyState int
+
+// states of a bounty
+const (
+	BountyOpen BountyState = iota
+	BountyExpired
+	BountyAwarded
+)
+
+var bountyStateNames = []string{"open", "expired", "awarded"}
+
+func (s BountyState) String() string {
+	if s >= 0 && int(s) < len(bountyStateNames) {
+		return bountyStateNames[s]
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s BountyState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Bounty is a bounty on a question, from VoteBountyStart and
+// VoteBountyClose votes
+type Bounty struct {
+	// 0 if award went to a post that is not in the dump and no start
+	// matched it
+	QuestionID int `json:"question_id"`
+	// user who started the bounty, 0 if start is not in the dump
+	UserID int       `json:"user_id,omitempty"`
+	Amount int       `json:"amount"`
+	Start  time.Time `json:"start"`
+	// zero if bounty is open or expired without award
+	Close time.Time   `json:"close"`
+	State BountyState `json:"state"`
+	// answer that got the award, 0 if not known
+	AwardedPostID int `json:"awarded_post_id,omitempty"`
+	// can be half of Amount if bounty was awarded automatically
+	AwardedAmount int `json:"awarded_amount,omitempty"`
+	// true if AwardedPostID was not in the close vote but guessed from
+	// answers existing at the time of close
+	AwardInferred bool `json:"award_inferred,omitempty"`
+	// true if the close vote was on a deleted answer and was matched with
+	// the start by time, so the start may be of a different bounty
+	QuestionInferred bool `json:"question_inferred,omitempty"`
+}
+
+func (b *Bounty) date() time.Time {
+	if b.Start.IsZero() {
+		return b.Close
+	}
+	return b.Start
+}
+
+// Month returns the first day of the month a bounty started in (or closed
+// in if its start is not known)
+func (b *Bounty) Month() time.Time {
+	t := b.date()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// BountySource provides posts and their history to MatchBounties.
+// *Dataset implements it. History is only used to check if answers were
+// deleted when inferring the award
+type BountySource interface {
+	Post(id int) *Post
+	Answers(questionID int) []*Post
+	PostHistoryOf(postID int) []*PostHistory
+}
+
+// deletedAt returns true if post history says a post was deleted at time t
+func deletedAt(history []*PostHistory, t time.Time) bool {
+	deleted := false
+	for _, h := range history {
+		if h.CreationDate.After(t) {
+			continue
+		}
+		switch h.PostHistoryTypeID {
+		case HistoryPostDeleted:
+			deleted = true
+		case HistoryPostUndeleted:
+			deleted = false
+		}
+	}
+	return deleted
+}
+
+// inferAward guesses which answer got a bounty whose close vote is on
+// the question. Candidates are answers that existed and were not deleted
+// at close. Answers posted after start of the bounty are preferred, then
+// the highest scored
+func inferAward(src BountySource, b *Bounty) int {
+	var best *Post
+	bestNew := false
+	for _, a := range src.Answers(b.QuestionID) {
+		if a.CreationDate.After(b.Close.Add(24 * time.Hour)) {
+			continue
+		}
+		if deletedAt(src.PostHistoryOf(a.ID), b.Close) {
+			continue
+		}
+		isNew := !b.Start.IsZero() && !a.CreationDate.Before(b.Start)
+		if best == nil || (isNew && !bestNew) || (isNew == bestNew && a.Score > best.Score) {
+			best = a
+			bestNew = isNew
+		}
+	}
+	if best == nil {
+		return 0
+	}
+	return best.ID
+}
+
+// matchByTime returns an unmatched start within bounty period before close
+// vote v, on any question. Starts with amount of the award (or twice the
+// amount, for automatic awards) are preferred, then the earliest
+func matchByTime(byStart []*Bounty, v *Vote) *Bounty {
+	i := sort.Search(len(byStart), func(i int) bool {
+		return !byStart[i].Start.Before(v.CreationDate.Add(-bountyWindow))
+	})
+	var res *Bounty
+	for ; i < len(byStart) && !byStart[i].Start.After(v.CreationDate); i++ {
+		s := byStart[i]
+		if s.State != BountyOpen {
+			continue
+		}
+		if s.Amount == v.BountyAmount || s.Amount == 2*v.BountyAmount {
+			return s
+		}
+		if res == nil {
+			res = s
+		}
+	}
+	return res
+}
+
+// MatchBounties pairs bounty start and close votes of each question. A close
+// is matched with the earliest unmatched start on the same question within
+// bounty period. A close vote is on the awarded answer; if it's on the
+// question, the answer is inferred (see Bounty.AwardInferred). When the
+// awarded answer is not in the dump (it was deleted), its question isn't
+// known, so the close is matched by time with an unmatched start on any
+// question, preferring one with the same amount, after all other closes are
+// matched (see Bounty.QuestionInferred). Starts without close are expired if their period ended before
+// asOf (usually date of the dump), otherwise open. Bounties are sorted by
+// start (or close if start is not known)
+func MatchBounties(votes []Vote, src BountySource, asOf time.Time) []*Bounty {
+	starts := map[int][]*Bounty{}
+	var closes []*Vote
+	for i := range votes {
+		v := &votes[i]
+		switch v.VoteTypeID {
+		case VoteBountyStart:
+			qid := v.PostID
+			if p := src.Post(qid); p != nil && p.PostTypeID == PostAnswer {
+				qid = p.ParentID
+			}
+			starts[qid] = append(starts[qid], &Bounty{
+				QuestionID: qid,
+				UserID:     v.UserID,
+				Amount:     v.BountyAmount,
+				Start:      v.CreationDate,
+			})
+		case VoteBountyClose:
+			closes = append(closes, v)
+		}
+	}
+	var res []*Bounty
+	for _, bs := range starts {
+		sort.SliceStable(bs, func(i, j int) bool { return bs[i].Start.Before(bs[j].Start) })
+		res = append(res, bs...)
+	}
+	// all starts sorted by time, for closes on missing posts
+	byStart := make([]*Bounty, len(res))
+	copy(byStart, res)
+	sort.SliceStable(byStart, func(i, j int) bool { return byStart[i].Start.Before(byStart[j].Start) })
+	sort.SliceStable(closes, func(i, j int) bool { return closes[i].CreationDate.Before(closes[j].CreationDate) })
+	// closes on missing posts are matched last so that they don't take
+	// starts of other closes
+	var known, missing []*Vote
+	for _, v := range closes {
+		if src.Post(v.PostID) == nil {
+			missing = append(missing, v)
+		} else {
+			known = append(known, v)
+		}
+	}
+	closes = append(known, missing...)
+
+	for _, v := range closes {
+		qid := v.PostID
+		awarded := 0
+		p := src.Post(v.PostID)
+		var b *Bounty
+		if p == nil {
+			// awarded answer was deleted
+			qid = 0
+			awarded = v.PostID
+			b = matchByTime(byStart, v)
+			if b != nil {
+				b.QuestionInferred = true
+			}
+		} else {
+			if p.PostTypeID == PostAnswer {
+				qid = p.ParentID
+				awarded = v.PostID
+			}
+			for _, s := range starts[qid] {
+				if s.State != BountyOpen || s.Start.After(v.CreationDate) {
+					continue
+				}
+				if v.CreationDate.Sub(s.Start) <= bountyWindow {
+					b = s
+					break
+				}
+			}
+		}
+		if b == nil {
+			b = &Bounty{
+				QuestionID: qid,
+				Amount:     v.BountyAmount,
+			}
+			res = append(res, b)
+		}
+		b.Close = v.CreationDate
+		if awarded == 0 && v.BountyAmount == 0 {
+			b.State = BountyExpired
+			continue
+		}
+		b.State = BountyAwarded
+		b.AwardedAmount = v.BountyAmount
+		b.AwardedPostID = awarded
+		if awarded == 0 {
+			b.AwardedPostID = inferAward(src, b)
+			b.AwardInferred = b.AwardedPostID != 0
+		}
+	}
+
+	for _, b := range res {
+		if b.State == BountyOpen && !b.Start.IsZero() && asOf.Sub(b.Start) > bountyWindow {
+			b.State = BountyExpired
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		di, dj := res[i].date(), res[j].date()
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return res[i].QuestionID < res[j].QuestionID
+	})
+	return res
+}
+
+// Bounties returns bounties matched from Votes (see MatchBounties), as of
+// the date of the latest vote. Needs Posts, Votes and PostHistory tables
+func (ds *Dataset) Bounties() []*Bounty {
+	var asOf time.Time
+	for i := range ds.Votes {
+		if ds.Votes[i].CreationDate.After(asOf) {
+			asOf = ds.Votes[i].CreationDate
+		}
+	}
+	return MatchBounties(ds.Votes, ds, asOf)
+}
+
+// BountyGroup is statistics of bounties in a group, e.g. with a tag or
+// started in a month
+type BountyGroup struct {
+	Key     string
+	Open    int
+	Expired int
+	Awarded int
+	// total amount of started bounties
+	Amount int
+	// total amount given to answers
+	AwardedAmount int
+}
+
+// Count returns number of bounties
+func (g *BountyGroup) Count() int {
+	return g.Open + g.Expired + g.Awarded
+}
+
+func (g *BountyGroup) add(b *Bounty) {
+	switch b.State {
+	case BountyOpen:
+		g.Open++
+	case BountyExpired:
+		g.Expired++
+	case BountyAwarded:
+		g.Awarded++
+	}
+	g.Amount += b.Amount
+	g.AwardedAmount += b.AwardedAmount
+}
+
+func groupBounties(bounties []*Bounty, keys func(b *Bounty) []string) map[string]*BountyGroup {
+	m := map[string]*BountyGroup{}
+	for _, b := range bounties {
+		for _, k := range keys(b) {
+			g := m[k]
+			if g == nil {
+				g = &BountyGroup{Key: k}
+				m[k] = g
+			}
+			g.add(b)
+		}
+	}
+	return m
+}
+
+// BountiesByMonth groups bounties by month they started in ("2006-01"),
+// sorted by month
+func BountiesByMonth(bounties []*Bounty) []*BountyGroup {
+	m := groupBounties(bounties, func(b *Bounty) []string {
+		return []string{b.Month().Format("2006-01")}
+	})
+	var res []*BountyGroup
+	for _, g := range m {
+		res = append(res, g)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res
+}
+
+// BountiesByTag groups bounties by tags of their questions, sorted by
+// number of bounties. Bounties with QuestionInferred are skipped because
+// their question is a guess. tags returns tags of a question, e.g.
+// Dataset.Post(id).Tags
+func BountiesByTag(bounties []*Bounty, tags func(questionID int) []string) []*BountyGroup {
+	m := groupBounties(bounties, func(b *Bounty) []string {
+		if b.QuestionInferred {
+			return nil
+		}
+		return tags(b.QuestionID)
+	})
+	var res []*BountyGroup
+	for _, g := range m {
+		res = append(res, g)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count() != res[j].Count() {
+			return res[i].Count() > res[j].Count()
+		}
+		return res[i].Key < res[j].Key
+	})
+	return res
+}
+
+// BountySpender is reputation a user spent on bounties
+type BountySpender struct {
+	UserID   int
+	Bounties int
+	// reputation is spent when bounty starts, even if it's not awarded
+	Spent int
+}
+
+// BountySpenders returns users who started bounties, sorted by reputation
+// spent
+func BountySpenders(bounties []*Bounty) []*BountySpender {
+	m := map[int]*BountySpender{}
+	var res []*BountySpender
+	for _, b := range bounties {
+		if b.UserID == 0 {
+			continue
+		}
+		s := m[b.UserID]
+		if s == nil {
+			s = &BountySpender{UserID: b.UserID}
+			m[b.UserID] = s
+			res = append(res, s)
+		}
+		s.Bounties++
+		s.Spent += b.Amount
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Spent != res[j].Spent {
+			return res[i].Spent > res[j].Spent
+		}
+		return res[i].UserID < res[j].UserID
+	})
+	return res
+}
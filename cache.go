@@ -0,0 +1,616 @@
+package stackoverflow
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Cache is a compact binary version of .xml file that is much faster to
+// read. It's created next to .xml file (e.g. Posts.xml.cache) by
+// OpenCached and re-created when .xml file changes.
+//
+// Records are stored in blocks of up to cacheBlockRows rows. Inside a block
+// each field is stored as a separate column:
+//	int         varint of delta from previous row
+//	time.Time   uvarint of zig-zag encoded delta in milliseconds from
+//	            previous row + 1, 0 for zero time
+//	string      uvarint length + bytes
+//	names       dictionary of strings followed by uvarint indexes
+//	[]string    dictionary of strings followed by uvarint count and indexes
+//
+// Times in .xml files have millisecond precision, which is preserved.
+//
+// File layout:
+//	magic, uvarint version, record type, source size, source modification time, schema
+//	blocks: uvarint number of rows, for each column: uvarint size, data
+//	uvarint 0 marks the end
+
+const (
+	cacheMagic     = "SOCACHE\x00"
+	cacheVersion   = 1
+	cacheExt       = ".cache"
+	cacheBlockRows = 16 * 1024
+)
+
+// ErrCacheStale is returned when cache file doesn't match its .xml file
+var ErrCacheStale = errors.New("cache is stale")
+
+// fields stored with a dictionary because values repeat a lot
+var cacheDictFields = map[string]bool{
+	"DisplayName":           true,
+	"OwnerDisplayName":      true,
+	"LastEditorDisplayName": true,
+	"UserDisplayName":       true,
+	"Location":              true,
+	"Name":                  true,
+}
+
+const (
+	colInt = iota
+	colTime
+	colString
+	colDict
+	colStrings
+)
+
+var colKindNames = []string{"int", "time", "string", "dict", "strings"}
+
+type cacheColumn struct {
+	field int
+	kind  int
+}
+
+var cacheTimeType = reflect.TypeOf(time.Time{})
+
+func cacheColumnsOf(t reflect.Type) ([]cacheColumn, string, error) {
+	var cols []cacheColumn
+	var schema []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		kind := -1
+		switch {
+		case f.Type == cacheTimeType:
+			kind = colTime
+		case f.Type.Kind() == reflect.Int:
+			kind = colInt
+		case f.Type.Kind() == reflect.String:
+			kind = colString
+			if cacheDictFields[f.Name] {
+				kind = colDict
+			}
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.String:
+			kind = colStrings
+		default:
+			return nil, "", fmt.Errorf("cache: field %s.%s has unsupported type %s", t.Name(), f.Name, f.Type)
+		}
+		cols = append(cols, cacheColumn{field: i, kind: kind})
+		schema = append(schema, f.Name+":"+colKindNames[kind])
+	}
+	return cols, strings.Join(schema, ","), nil
+}
+
+// typeFromPath returns record type of .xml file based on its name
+func typeFromPath(path string) (string, error) {
+	name := strings.ToLower(filepath.Base(path))
+	typ := strings.TrimSuffix(name, ".xml")
+	switch typ {
+	case typeBadges, typeComments, typePostHistory, typePostLinks, typePosts, typeTags, typeUsers, typeVotes:
+		return typ, nil
+	}
+	return "", fmt.Errorf("'%s' is not a recognized file name", path)
+}
+
+// cacheEncoder encodes one column of a block
+type cacheEncoder struct {
+	kind int
+	buf  []byte
+	prev int64
+	dict map[string]uint64
+	strs []string
+	tmp  [binary.MaxVarintLen64]byte
+}
+
+func (e *cacheEncoder) putUvarint(buf []byte, v uint64) []byte {
+	n := binary.PutUvarint(e.tmp[:], v)
+	return append(buf, e.tmp[:n]...)
+}
+
+func (e *cacheEncoder) dictIndex(s string) uint64 {
+	idx, ok := e.dict[s]
+	if !ok {
+		idx = uint64(len(e.strs))
+		e.dict[s] = idx
+		e.strs = append(e.strs, s)
+	}
+	return idx
+}
+
+func (e *cacheEncoder) add(v reflect.Value) {
+	switch e.kind {
+	case colInt:
+		n := v.Int()
+		k := binary.PutVarint(e.tmp[:], n-e.prev)
+		e.buf = append(e.buf, e.tmp[:k]...)
+		e.prev = n
+	case colTime:
+		t := v.Addr().Interface().(*time.Time)
+		if t.IsZero() {
+			e.buf = append(e.buf, 0)
+			return
+		}
+		ms := t.UnixNano() / int64(time.Millisecond)
+		d := ms - e.prev
+		e.prev = ms
+		e.buf = e.putUvarint(e.buf, uint64((d<<1)^(d>>63))+1)
+	case colString:
+		s := v.String()
+		e.buf = e.putUvarint(e.buf, uint64(len(s)))
+		e.buf = append(e.buf, s...)
+	case colDict:
+		e.buf = e.putUvarint(e.buf, e.dictIndex(v.String()))
+	case colStrings:
+		n := v.Len()
+		e.buf = e.putUvarint(e.buf, uint64(n))
+		for i := 0; i < n; i++ {
+			e.buf = e.putUvarint(e.buf, e.dictIndex(v.Index(i).String()))
+		}
+	}
+}
+
+// data returns encoded column. For dictionary columns, the dictionary
+// is written before indexes
+func (e *cacheEncoder) data() []byte {
+	if e.kind != colDict && e.kind != colStrings {
+		return e.buf
+	}
+	var d []byte
+	d = e.putUvarint(d, uint64(len(e.strs)))
+	for _, s := range e.strs {
+		d = e.putUvarint(d, uint64(len(s)))
+		d = append(d, s...)
+	}
+	return append(d, e.buf...)
+}
+
+func (e *cacheEncoder) reset() {
+	e.buf = e.buf[:0]
+	e.prev = 0
+	e.dict = map[string]uint64{}
+	e.strs = e.strs[:0]
+}
+
+type cacheWriter struct {
+	w    *bufio.Writer
+	cols []cacheColumn
+	encs []*cacheEncoder
+	n    int
+	tmp  [binary.MaxVarintLen64]byte
+}
+
+func (w *cacheWriter) putUvarint(v uint64) {
+	n := binary.PutUvarint(w.tmp[:], v)
+	w.w.Write(w.tmp[:n])
+}
+
+func (w *cacheWriter) putString(s string) {
+	w.putUvarint(uint64(len(s)))
+	w.w.WriteString(s)
+}
+
+func (w *cacheWriter) add(rec reflect.Value) error {
+	for i, col := range w.cols {
+		w.encs[i].add(rec.Field(col.field))
+	}
+	w.n++
+	if w.n == cacheBlockRows {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *cacheWriter) flush() error {
+	if w.n == 0 {
+		return nil
+	}
+	w.putUvarint(uint64(w.n))
+	for _, e := range w.encs {
+		d := e.data()
+		w.putUvarint(uint64(len(d)))
+		if _, err := w.w.Write(d); err != nil {
+			return err
+		}
+		e.reset()
+	}
+	w.n = 0
+	return nil
+}
+
+// WriteCache writes cache of .xml file to cachePath
+func WriteCache(xmlPath string, cachePath string) error {
+	typ, err := typeFromPath(xmlPath)
+	if err != nil {
+		return err
+	}
+	st, err := os.Stat(xmlPath)
+	if err != nil {
+		return err
+	}
+	r, err := newReaderFromFile(xmlPath, typ)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	rec := reflect.ValueOf(r.Record()).Elem()
+	cols, schema, err := cacheColumnsOf(rec.Type())
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename so that readers never see
+	// partially written cache
+	tmpPath := cachePath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	w := &cacheWriter{
+		w:    bufio.NewWriterSize(f, 1024*1024),
+		cols: cols,
+	}
+	for _, col := range cols {
+		w.encs = append(w.encs, &cacheEncoder{kind: col.kind, dict: map[string]uint64{}})
+	}
+	w.w.WriteString(cacheMagic)
+	w.putUvarint(cacheVersion)
+	w.putString(typ)
+	w.putUvarint(uint64(st.Size()))
+	w.putUvarint(uint64(st.ModTime().UnixNano()))
+	w.putString(schema)
+	for r.Next() {
+		if err = w.add(rec); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if r.Err() != nil {
+		f.Close()
+		return r.Err()
+	}
+	if err = w.flush(); err != nil {
+		f.Close()
+		return err
+	}
+	w.putUvarint(0)
+	if err = w.w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, cachePath)
+}
+
+// cacheDecoder decodes one column of a block
+type cacheDecoder struct {
+	kind int
+	d    []byte
+	prev int64
+	dict []string
+	in   *Interner
+}
+
+var errCacheCorrupted = errors.New("cache: corrupted data")
+
+func (c *cacheDecoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(c.d)
+	if n <= 0 {
+		return 0, errCacheCorrupted
+	}
+	c.d = c.d[n:]
+	return v, nil
+}
+
+func (c *cacheDecoder) bytes() ([]byte, error) {
+	n, err := c.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(c.d)) < n {
+		return nil, errCacheCorrupted
+	}
+	res := c.d[:n]
+	c.d = c.d[n:]
+	return res, nil
+}
+
+func (c *cacheDecoder) reset(d []byte) error {
+	c.d = d
+	c.prev = 0
+	c.dict = c.dict[:0]
+	if c.kind != colDict && c.kind != colStrings {
+		return nil
+	}
+	n, err := c.uvarint()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		s, err := c.bytes()
+		if err != nil {
+			return err
+		}
+		c.dict = append(c.dict, c.in.String(string(s)))
+	}
+	return nil
+}
+
+func (c *cacheDecoder) dictString() (string, error) {
+	idx, err := c.uvarint()
+	if err != nil {
+		return "", err
+	}
+	if idx >= uint64(len(c.dict)) {
+		return "", errCacheCorrupted
+	}
+	return c.dict[idx], nil
+}
+
+func (c *cacheDecoder) decode(v reflect.Value) error {
+	switch c.kind {
+	case colInt:
+		d, n := binary.Varint(c.d)
+		if n <= 0 {
+			return errCacheCorrupted
+		}
+		c.d = c.d[n:]
+		c.prev += d
+		v.SetInt(c.prev)
+	case colTime:
+		u, err := c.uvarint()
+		if err != nil {
+			return err
+		}
+		t := v.Addr().Interface().(*time.Time)
+		if u == 0 {
+			*t = time.Time{}
+			return nil
+		}
+		u--
+		d := int64(u>>1) ^ -int64(u&1)
+		c.prev += d
+		*t = time.Unix(0, c.prev*int64(time.Millisecond)).UTC()
+	case colString:
+		s, err := c.bytes()
+		if err != nil {
+			return err
+		}
+		v.SetString(string(s))
+	case colDict:
+		s, err := c.dictString()
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case colStrings:
+		n, err := c.uvarint()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if n > uint64(len(c.d)) {
+			return errCacheCorrupted
+		}
+		a := make([]string, n)
+		for i := range a {
+			if a[i], err = c.dictString(); err != nil {
+				return err
+			}
+		}
+		v.Set(reflect.ValueOf(a))
+	}
+	return nil
+}
+
+// cacheReader is used by Reader reading from cache instead of .xml
+type cacheReader struct {
+	br    *bufio.Reader
+	rec   reflect.Value
+	cols  []cacheColumn
+	decs  []*cacheDecoder
+	nLeft int
+	buf   []byte
+}
+
+func readCacheString(br *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", err
+	}
+	if n > 1024*1024 {
+		return "", errCacheCorrupted
+	}
+	d := make([]byte, n)
+	_, err = io.ReadFull(br, d)
+	return string(d), err
+}
+
+// readBlock reads next block and returns false at the end
+func (c *cacheReader) readBlock() (bool, error) {
+	n, err := binary.ReadUvarint(c.br)
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		return false, nil
+	}
+	c.nLeft = int(n)
+	// read all columns into one buffer, reused for next blocks
+	sizes := make([]int, len(c.decs))
+	total := 0
+	for i := range c.decs {
+		size, err := binary.ReadUvarint(c.br)
+		if err != nil {
+			return false, err
+		}
+		if size > 1<<31 {
+			return false, errCacheCorrupted
+		}
+		sizes[i] = int(size)
+		total += int(size)
+		if cap(c.buf) < total {
+			buf := make([]byte, total, total*2)
+			copy(buf, c.buf)
+			c.buf = buf
+		}
+		c.buf = c.buf[:total]
+		if _, err = io.ReadFull(c.br, c.buf[total-int(size):]); err != nil {
+			return false, err
+		}
+	}
+	off := 0
+	for i, dec := range c.decs {
+		if err = dec.reset(c.buf[off : off+sizes[i]]); err != nil {
+			return false, err
+		}
+		off += sizes[i]
+	}
+	return true, nil
+}
+
+func (c *cacheReader) setInterner(in *Interner) {
+	for _, dec := range c.decs {
+		dec.in = in
+	}
+}
+
+// next decodes next record into c.rec
+func (c *cacheReader) next() (bool, error) {
+	if c.nLeft == 0 {
+		ok, err := c.readBlock()
+		if !ok || err != nil {
+			return false, err
+		}
+	}
+	for i, col := range c.cols {
+		if err := c.decs[i].decode(c.rec.Field(col.field)); err != nil {
+			return false, err
+		}
+	}
+	c.nLeft--
+	return true, nil
+}
+
+// OpenCache returns a Reader reading records from cache file. If xmlPath
+// is not empty, returns ErrCacheStale if .xml file changed since
+// cache was written
+func OpenCache(cachePath string, xmlPath string) (*Reader, error) {
+	f, err := os.Open(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newCacheReader(f, xmlPath)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func newCacheReader(f *os.File, xmlPath string) (*Reader, error) {
+	br := bufio.NewReaderSize(f, 1024*1024)
+	magic := make([]byte, len(cacheMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != cacheMagic {
+		return nil, fmt.Errorf("'%s' is not a cache file", f.Name())
+	}
+	version, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if version != cacheVersion {
+		return nil, ErrCacheStale
+	}
+	typ, err := readCacheString(br)
+	if err != nil {
+		return nil, err
+	}
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	modTime, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := readCacheString(br)
+	if err != nil {
+		return nil, err
+	}
+	if xmlPath != "" {
+		st, err := os.Stat(xmlPath)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(st.Size()) != size || uint64(st.ModTime().UnixNano()) != modTime {
+			return nil, ErrCacheStale
+		}
+	}
+	r := &Reader{
+		r:   f,
+		typ: typ,
+	}
+	rec := r.Record()
+	if rec == nil {
+		return nil, fmt.Errorf("'%s' has unknown record type '%s'", f.Name(), typ)
+	}
+	c := &cacheReader{
+		br:  br,
+		rec: reflect.ValueOf(rec).Elem(),
+	}
+	var currSchema string
+	c.cols, currSchema, err = cacheColumnsOf(c.rec.Type())
+	if err != nil {
+		return nil, err
+	}
+	// record struct has changed
+	if currSchema != schema {
+		return nil, ErrCacheStale
+	}
+	for _, col := range c.cols {
+		c.decs = append(c.decs, &cacheDecoder{kind: col.kind})
+	}
+	r.cache = c
+	return r, nil
+}
+
+// OpenCached returns a Reader for .xml file that reads from its cache.
+// The cache is created if it doesn't exist or is stale. If the cache
+// can't be written (e.g. read-only directory or full disk), the Reader
+// reads .xml file
+func OpenCached(xmlPath string) (*Reader, error) {
+	cachePath := xmlPath + cacheExt
+	r, err := OpenCache(cachePath, xmlPath)
+	if err == nil {
+		return r, nil
+	}
+	typ, err := typeFromPath(xmlPath)
+	if err != nil {
+		return nil, err
+	}
+	if err = WriteCache(xmlPath, cachePath); err != nil {
+		return newReaderFromFile(xmlPath, typ)
+	}
+	return OpenCache(cachePath, xmlPath)
+}
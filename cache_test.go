@@ -0,0 +1,209 @@
+package stackoverflow
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTables has a few rows of every table, with zero (missing) times,
+// negative ids and empty tags
+var testTables = map[string][]string{
+	"Badges.xml": {
+		`<row Id="1" UserId="-1" Name="Autobiographer" Date="2008-09-15T08:55:03.923" />`,
+		`<row Id="2" UserId="3" Name="Teacher" />`,
+		`<row Id="3" Name="Student" Date="1969-12-31T23:59:59.5" />`,
+	},
+	"Comments.xml": {
+		`<row Id="5" PostId="10" Score="-2" Text="first &amp; &lt;only&gt;" CreationDate="2010-01-01T00:00:00.001" UserId="-1" />`,
+		`<row Id="4" PostId="10" Score="0" Text="" UserDisplayName="anon" />`,
+		`<row Id="6" PostId="-3" Score="7" Text="héllo ☺" CreationDate="2009-12-31T23:59:59.999" />`,
+	},
+	"PostHistory.xml": {
+		`<row Id="1" PostHistoryTypeId="2" PostId="10" RevisionGUID="d3b1b3b0-1" CreationDate="2010-01-01T00:00:00" UserId="-1" Text="body" />`,
+		`<row Id="2" PostHistoryTypeId="3" PostId="10" RevisionGUID="d3b1b3b0-1" CreationDate="2010-01-01T00:00:00" UserDisplayName="anon" Text="&lt;go&gt;" />`,
+		`<row Id="3" PostHistoryTypeId="10" PostId="10" Comment="101" />`,
+	},
+	"PostLinks.xml": {
+		`<row Id="1" CreationDate="2011-05-05T10:10:10.1" PostId="10" RelatedPostId="20" LinkTypeId="3" />`,
+		`<row Id="2" PostId="20" RelatedPostId="-1" LinkTypeId="1" />`,
+	},
+	"Tags.xml": {
+		`<row Id="1" TagName="go" Count="100" ExcerptPostId="3" WikiPostId="4" />`,
+		`<row Id="2" TagName="c++" Count="0" />`,
+	},
+	"Users.xml": {
+		`<row Id="-1" Reputation="1" CreationDate="2008-07-31T00:00:00" DisplayName="Community" LastAccessDate="2008-08-26T00:16:53.81" AboutMe="&lt;p&gt;hi&lt;/p&gt;" Views="649" UpVotes="281" DownVotes="19" AccountId="-1" />`,
+		`<row Id="2" Reputation="101" DisplayName="" Views="0" UpVotes="0" DownVotes="0" Age="33" WebsiteUrl="http://example.com" Location="Seattle" ProfileImageUrl="http://example.com/a.png" />`,
+	},
+	"Votes.xml": {
+		`<row Id="1" PostId="10" VoteTypeId="2" CreationDate="2008-07-31T00:00:00" />`,
+		`<row Id="2" PostId="10" VoteTypeId="5" UserId="-1" />`,
+		`<row Id="3" PostId="11" VoteTypeId="8" UserId="7" BountyAmount="50" CreationDate="2008-07-30T00:00:00" />`,
+	},
+}
+
+// testPostRows returns rows of Posts.xml, enough for more than one block of
+// cache
+func testPostRows() []string {
+	rows := []string{
+		`<row Id="1" PostTypeId="1" AcceptedAnswerId="2" CreationDate="2008-07-31T21:42:52.667" Score="-3" ViewCount="10" Body="&lt;p&gt;q&lt;/p&gt;" OwnerUserId="-1" LastEditorUserId="-1" LastEditDate="2008-08-01T00:00:00" LastActivityDate="2008-08-01T00:00:00" Title="title" Tags="&lt;go&gt;&lt;c++&gt;" AnswerCount="1" CommentCount="0" FavoriteCount="2" />`,
+		`<row Id="2" PostTypeId="2" ParentId="1" CreationDate="2008-07-31T21:42:52.6" Score="0" Body="" OwnerDisplayName="anon" LastEditorDisplayName="anon" CommunityOwnedDate="2009-01-01T00:00:00" />`,
+		`<row Id="3" PostTypeId="4" Score="0" Body="" Tags="" ClosedDate="2010-01-01T00:00:00" />`,
+	}
+	tags := []string{"", "&lt;go&gt;", "&lt;go&gt;&lt;goroutines&gt;", "&lt;python&gt;&lt;list&gt;&lt;sorting&gt;"}
+	start := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2*cacheBlockRows+100; i++ {
+		// ids and dates are mostly, but not always, increasing
+		id := 100 + i*3 - (i%7)*5
+		created := start.Add(time.Duration(i*7919-(i%11)*60000) * time.Millisecond).Format(TimeFormat)
+		row := fmt.Sprintf(`<row Id="%d" PostTypeId="%d" CreationDate="%s" Score="%d" Body="body %d" OwnerUserId="%d" Tags="%s" />`, id, 1+i%2, created, i%13-6, i, i%5-1, tags[i%len(tags)])
+		if i%3 == 0 {
+			row = fmt.Sprintf(`<row Id="%d" PostTypeId="2" ParentId="%d" Score="%d" Body="" OwnerDisplayName="user%d" />`, id, id-1, -i, i%10)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func writeTestXML(t testing.TB, path string, rows []string) {
+	typ, err := typeFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := `<?xml version="1.0" encoding="utf-8"?>` + "\n<" + typ + ">\n  " + strings.Join(rows, "\n  ") + "\n</" + typ + ">\n"
+	if err = ioutil.WriteFile(path, []byte(s), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readAllRecords(t *testing.T, r *Reader, err error) []interface{} {
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var res []interface{}
+	for r.Next() {
+		res = append(res, reflect.ValueOf(r.Record()).Elem().Interface())
+	}
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+	return res
+}
+
+func readXMLRecords(t *testing.T, path string) []interface{} {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, _ := typeFromPath(path)
+	r, err := newReader(f, typ)
+	return readAllRecords(t, r, err)
+}
+
+func checkSameRecords(t *testing.T, name string, got, exp []interface{}) {
+	if len(got) != len(exp) {
+		t.Fatalf("%s: got %d records from cache, expected %d", name, len(got), len(exp))
+	}
+	for i := range exp {
+		if !reflect.DeepEqual(got[i], exp[i]) {
+			t.Fatalf("%s: record %d is different\ngot:      %#v\nexpected: %#v", name, i, got[i], exp[i])
+		}
+	}
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tables := map[string][]string{"Posts.xml": testPostRows()}
+	for name, rows := range testTables {
+		tables[name] = rows
+	}
+	for name, rows := range tables {
+		path := filepath.Join(dir, name)
+		writeTestXML(t, path, rows)
+		exp := readXMLRecords(t, path)
+		if len(exp) != len(rows) {
+			t.Fatalf("%s: got %d records from .xml, expected %d", name, len(exp), len(rows))
+		}
+		r, err := OpenCached(path)
+		checkSameRecords(t, name, readAllRecords(t, r, err), exp)
+		// now read from existing cache
+		r, err = OpenCache(path+cacheExt, path)
+		checkSameRecords(t, name, readAllRecords(t, r, err), exp)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Votes.xml")
+	rows := testTables["Votes.xml"]
+	writeTestXML(t, path, rows)
+	r, err := OpenCached(path)
+	readAllRecords(t, r, err)
+
+	// touching the file makes cache stale
+	modTime := time.Now().Add(time.Hour)
+	if err = os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenCache(path+cacheExt, path); err != ErrCacheStale {
+		t.Fatalf("expected ErrCacheStale after touching .xml file, got %v", err)
+	}
+	r, err = OpenCached(path)
+	checkSameRecords(t, "touched", readAllRecords(t, r, err), readXMLRecords(t, path))
+	r, err = OpenCache(path+cacheExt, path)
+	readAllRecords(t, r, err)
+
+	// so does changing it, even with the same modification time
+	rows = append(rows[:len(rows):len(rows)], `<row Id="4" PostId="12" VoteTypeId="3" />`)
+	writeTestXML(t, path, rows)
+	if err = os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenCache(path+cacheExt, path); err != ErrCacheStale {
+		t.Fatalf("expected ErrCacheStale after changing .xml file, got %v", err)
+	}
+	r, err = OpenCached(path)
+	got := readAllRecords(t, r, err)
+	if len(got) != len(rows) {
+		t.Fatalf("got %d records after changing .xml file, expected %d", len(got), len(rows))
+	}
+	checkSameRecords(t, "changed", got, readXMLRecords(t, path))
+}
+
+func TestOpenCachedWithoutCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Comments.xml")
+	writeTestXML(t, path, testTables["Comments.xml"])
+	// a directory in place of temporary cache file makes writing cache fail
+	// (unlike a read-only directory, which doesn't stop root)
+	if err = os.Mkdir(path+cacheExt+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenCached(path)
+	checkSameRecords(t, "uncached", readAllRecords(t, r, err), readXMLRecords(t, path))
+	if _, err = os.Stat(path + cacheExt); !os.IsNotExist(err) {
+		t.Fatalf("expected no cache file, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgDir    string
+	flgOut    string
+	flgConfig string
+	flgKey    string
+)
+
+func parseFlags() {
+	flag.StringVar(&flgDir, "dir", "", "directory with .xml files of the data dump")
+	flag.StringVar(&flgOut, "out", "", "directory where anonymized .xml files will be written")
+	flag.StringVar(&flgConfig, "config", "", "optional .json file with stackoverflow.AnonymizeConfig")
+	flag.StringVar(&flgKey, "key", "", "secret key for pseudonymization, overrides Key from -config")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: anonymize -dir <dump dir> -out <out dir> [-config config.json] [-key secret]\n")
+	fmt.Printf(`config.json looks like:
+{
+  "Key": "secret",
+  "Keep": ["Location"],
+  "Synthesize": ["AboutMe", "WebsiteURL"]
+}
+`)
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func loadConfig(path string) (*stackoverflow.AnonymizeConfig, error) {
+	var config stackoverflow.AnonymizeConfig
+	if path == "" {
+		return &config, nil
+	}
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(d, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %s", path, err)
+	}
+	return &config, nil
+}
+
+type newReaderFunc func(string) (*stackoverflow.Reader, error)
+type newWriterFunc func(string) (*stackoverflow.Writer, error)
+
+// anonymizeFile reads records from file name in srcDir, anonymizes them and
+// writes them to the file with the same name in dstDir
+func anonymizeFile(srcDir, dstDir string, name string, newReader newReaderFunc, newWriter newWriterFunc, anonymize func(r *stackoverflow.Reader, w *stackoverflow.Writer) error) error {
+	srcPath := filepath.Join(srcDir, name)
+	if !u.PathExists(srcPath) {
+		fmt.Printf("skipping %s because it doesn't exist\n", srcPath)
+		return nil
+	}
+	timeStart := time.Now()
+	r, err := newReader(srcPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := newWriter(filepath.Join(dstDir, name))
+	if err != nil {
+		return err
+	}
+	n := 0
+	for r.Next() {
+		err = anonymize(r, w)
+		if err != nil {
+			w.Close()
+			return err
+		}
+		n++
+	}
+	if r.Err() != nil {
+		w.Close()
+		return r.Err()
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("anonymized %d records in %s in %s\n", n, name, time.Since(timeStart))
+	return nil
+}
+
+func anonymizeDir(a *stackoverflow.Anonymizer, srcDir, dstDir string) error {
+	err := os.MkdirAll(dstDir, 0755)
+	if err != nil {
+		return err
+	}
+	err = anonymizeFile(srcDir, dstDir, "Users.xml", stackoverflow.NewUsersReaderFromFile, stackoverflow.NewUsersWriterToFile, func(r *stackoverflow.Reader, w *stackoverflow.Writer) error {
+		a.AnonymizeUser(&r.User)
+		return w.WriteUser(&r.User)
+	})
+	if err != nil {
+		return err
+	}
+	err = anonymizeFile(srcDir, dstDir, "Posts.xml", stackoverflow.NewPostsReaderFromFile, stackoverflow.NewPostsWriterToFile, func(r *stackoverflow.Reader, w *stackoverflow.Writer) error {
+		a.AnonymizePost(&r.Post)
+		return w.WritePost(&r.Post)
+	})
+	if err != nil {
+		return err
+	}
+	err = anonymizeFile(srcDir, dstDir, "Comments.xml", stackoverflow.NewCommentsReaderFromFile, stackoverflow.NewCommentsWriterToFile, func(r *stackoverflow.Reader, w *stackoverflow.Writer) error {
+		a.AnonymizeComment(&r.Comment)
+		return w.WriteComment(&r.Comment)
+	})
+	if err != nil {
+		return err
+	}
+	err = anonymizeFile(srcDir, dstDir, "Badges.xml", stackoverflow.NewBadgesReaderFromFile, stackoverflow.NewBadgesWriterToFile, func(r *stackoverflow.Reader, w *stackoverflow.Writer) error {
+		a.AnonymizeBadge(&r.Badge)
+		return w.WriteBadge(&r.Badge)
+	})
+	if err != nil {
+		return err
+	}
+	err = anonymizeFile(srcDir, dstDir, "Votes.xml", stackoverflow.NewVotesReaderFromFile, stackoverflow.NewVotesWriterToFile, func(r *stackoverflow.Reader, w *stackoverflow.Writer) error {
+		a.AnonymizeVote(&r.Vote)
+		return w.WriteVote(&r.Vote)
+	})
+	if err != nil {
+		return err
+	}
+	err = anonymizeFile(srcDir, dstDir, "PostHistory.xml", stackoverflow.NewPostHistoryReaderFromFile, stackoverflow.NewPostHistoryWriterToFile, func(r *stackoverflow.Reader, w *stackoverflow.Writer) error {
+		a.AnonymizePostHistory(&r.PostHistory)
+		return w.WritePostHistory(&r.PostHistory)
+	})
+	if err != nil {
+		return err
+	}
+	// tags and post links don't have personal information
+	err = anonymizeFile(srcDir, dstDir, "Tags.xml", stackoverflow.NewTagsReaderFromFile, stackoverflow.NewTagsWriterToFile, func(r *stackoverflow.Reader, w *stackoverflow.Writer) error {
+		return w.WriteTag(&r.Tag)
+	})
+	if err != nil {
+		return err
+	}
+	return anonymizeFile(srcDir, dstDir, "PostLinks.xml", stackoverflow.NewPostLinksReaderFromFile, stackoverflow.NewPostLinksWriterToFile, func(r *stackoverflow.Reader, w *stackoverflow.Writer) error {
+		return w.WritePostLink(&r.PostLink)
+	})
+}
+
+func main() {
+	parseFlags()
+	if flgDir == "" || flgOut == "" {
+		usageAndExit()
+	}
+	config, err := loadConfig(u.ExpandTildeInPath(flgConfig))
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+	if flgKey != "" {
+		config.Key = flgKey
+	}
+	a, err := stackoverflow.NewAnonymizer(config)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		usageAndExit()
+	}
+	err = anonymizeDir(a, u.ExpandTildeInPath(flgDir), u.ExpandTildeInPath(flgOut))
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+}
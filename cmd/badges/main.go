@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/badges"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgBadge string
+	flgN     int
+	flgCheck bool
+)
+
+func parseFlags() {
+	flag.StringVar(&flgBadge, "badge", "", "show monthly timeline and first recipients of a badge")
+	flag.IntVar(&flgN, "n", 20, "number of badges (or mismatches with -check) to show")
+	flag.BoolVar(&flgCheck, "check", false, "recompute common badges from Posts.xml and Votes.xml and compare with Badges.xml")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: badges [flags] <directory>\n")
+	fmt.Printf("  shows statistics of badges, using Badges.xml and Users.xml in a directory\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func fmtDays(d time.Duration) string {
+	return fmt.Sprintf("%.1f", d.Hours()/24)
+}
+
+func userName(ds *stackoverflow.Dataset, id int) string {
+	if u := ds.User(id); u != nil {
+		return u.DisplayName
+	}
+	return fmt.Sprintf("%d", id)
+}
+
+func showBadge(ds *stackoverflow.Dataset, s *badges.Stats) {
+	fmt.Printf("%s: %d awards to %d users (%.4f%% of users), first on %s\n", s.Name, s.Count, s.Users, s.Rarity*100, s.First.Format("2006-01-02"))
+	fmt.Printf("days from signup: median %s, mean %s\n", fmtDays(s.MedianTimeToBadge), fmtDays(s.MeanTimeToBadge))
+	var names []string
+	for _, id := range s.FirstRecipients {
+		names = append(names, userName(ds, id))
+	}
+	fmt.Printf("first recipients: %s\n", strings.Join(names, ", "))
+	for _, m := range s.Timeline {
+		fmt.Printf("  %s %6d\n", m.Month.Format("2006-01"), m.Count)
+	}
+}
+
+func check(ds *stackoverflow.Dataset) {
+	timeStart := time.Now()
+	c := badges.NewContext(ds)
+	awards := badges.Recompute(c, badges.Rules)
+	mismatches := badges.Compare(c, badges.Rules, awards, ds.Badges)
+	fmt.Printf("\nrecomputed %d awards, %d mismatches with Badges.xml in %s\n", len(awards), len(mismatches), time.Since(timeStart))
+	byName := map[string]int{}
+	var names []string
+	for _, m := range mismatches {
+		if byName[m.Name] == 0 {
+			names = append(names, m.Name)
+		}
+		byName[m.Name]++
+	}
+	for _, name := range names {
+		fmt.Printf("  %-24s %6d\n", name, byName[name])
+	}
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("2006-01-02")
+	}
+	for i, m := range mismatches {
+		if i >= flgN {
+			break
+		}
+		fmt.Printf("%-24s user %-8d Badges.xml: %d (%s), computed: %d (%s)\n", m.Name, m.UserID, m.Expected, date(m.ExpectedDate), m.Computed, date(m.ComputedDate))
+	}
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	dir := u.ExpandTildeInPath(flag.Arg(0))
+	timeStart := time.Now()
+	tables := stackoverflow.TableBadges | stackoverflow.TableUsers
+	if flgCheck {
+		tables |= stackoverflow.TablePosts | stackoverflow.TableVotes
+	}
+	ds, err := stackoverflow.LoadDataset(dir, tables)
+	if err != nil {
+		fmt.Printf("LoadDataset() failed with %s\n", err)
+		os.Exit(1)
+	}
+	signup := func(id int) time.Time {
+		if u := ds.User(id); u != nil {
+			return u.CreationDate
+		}
+		return time.Time{}
+	}
+	stats := badges.Analyze(ds.Badges, signup, len(ds.Users), 5)
+	fmt.Printf("%d badges, %d awards in %s\n", len(stats), len(ds.Badges), time.Since(timeStart))
+
+	if flgBadge != "" {
+		for _, s := range stats {
+			if s.Name == flgBadge {
+				showBadge(ds, s)
+			}
+		}
+	} else {
+		fmt.Printf("\n%-24s %8s %8s %9s %12s %12s\n", "badge", "awards", "users", "rarity %", "first", "median days")
+		for i, s := range stats {
+			if i >= flgN {
+				break
+			}
+			fmt.Printf("%-24s %8d %8d %9.4f %12s %12s\n", s.Name, s.Count, s.Users, s.Rarity*100, s.First.Format("2006-01-02"), fmtDays(s.MedianTimeToBadge))
+		}
+	}
+
+	if flgCheck {
+		check(ds)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgTags  int
+	flgUsers int
+	flgOut   string
+)
+
+func parseFlags() {
+	flag.IntVar(&flgTags, "tags", 20, "number of tags with most bounties to show")
+	flag.IntVar(&flgUsers, "users", 10, "number of users who spent most reputation on bounties to show")
+	flag.StringVar(&flgOut, "out", "", "if given, write .csv file with all bounties")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: bounties [flags] <directory>\n")
+	fmt.Printf("  shows statistics of bounties, using Posts.xml, PostHistory.xml, Users.xml and Votes.xml in a directory\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func writeBounties(bounties []*stackoverflow.Bounty, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"QuestionId", "UserId", "Amount", "Start", "Close", "State", "AwardedPostId", "AwardedAmount", "AwardInferred", "QuestionInferred"})
+	for _, b := range bounties {
+		w.Write([]string{
+			strconv.Itoa(b.QuestionID),
+			strconv.Itoa(b.UserID),
+			strconv.Itoa(b.Amount),
+			formatDate(b.Start),
+			formatDate(b.Close),
+			b.State.String(),
+			strconv.Itoa(b.AwardedPostID),
+			strconv.Itoa(b.AwardedAmount),
+			strconv.FormatBool(b.AwardInferred),
+			strconv.FormatBool(b.QuestionInferred),
+		})
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func printGroups(title string, groups []*stackoverflow.BountyGroup, max int) {
+	fmt.Printf("\n%-24s %6s %6s %8s %8s %8s %9s\n", title, "total", "open", "expired", "awarded", "amount", "awarded")
+	for i, g := range groups {
+		if max > 0 && i >= max {
+			break
+		}
+		fmt.Printf("%-24s %6d %6d %8d %8d %8d %9d\n", g.Key, g.Count(), g.Open, g.Expired, g.Awarded, g.Amount, g.AwardedAmount)
+	}
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	dir := u.ExpandTildeInPath(flag.Arg(0))
+	timeStart := time.Now()
+	tables := stackoverflow.TablePosts | stackoverflow.TablePostHistory | stackoverflow.TableUsers | stackoverflow.TableVotes
+	ds, err := stackoverflow.LoadDataset(dir, tables)
+	if err != nil {
+		fmt.Printf("LoadDataset() failed with %s\n", err)
+		os.Exit(1)
+	}
+	bounties := ds.Bounties()
+	nInferred, nQuestionInferred := 0, 0
+	for _, b := range bounties {
+		if b.AwardInferred {
+			nInferred++
+		}
+		if b.QuestionInferred {
+			nQuestionInferred++
+		}
+	}
+	fmt.Printf("%d bounties (%d with inferred award, %d with inferred question) in %s\n", len(bounties), nInferred, nQuestionInferred, time.Since(timeStart))
+
+	printGroups("month", stackoverflow.BountiesByMonth(bounties), 0)
+	tags := func(id int) []string {
+		if p := ds.Post(id); p != nil {
+			return p.Tags
+		}
+		return nil
+	}
+	printGroups("tag", stackoverflow.BountiesByTag(bounties, tags), flgTags)
+
+	fmt.Printf("\n%-24s %8s %8s\n", "user", "bounties", "spent")
+	for i, s := range stackoverflow.BountySpenders(bounties) {
+		if i >= flgUsers {
+			break
+		}
+		name := strconv.Itoa(s.UserID)
+		if u := ds.User(s.UserID); u != nil {
+			name = u.DisplayName
+		}
+		fmt.Printf("%-24s %8d %8d\n", name, s.Bounties, s.Spent)
+	}
+
+	if flgOut != "" {
+		if err = writeBounties(bounties, flgOut); err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+}
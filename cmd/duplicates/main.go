@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/graph"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgClusters int
+	flgTags     int
+	flgOut      string
+)
+
+func parseFlags() {
+	flag.IntVar(&flgClusters, "clusters", 10, "number of largest clusters to show")
+	flag.IntVar(&flgTags, "tags", 20, "number of tags with most duplicates to show")
+	flag.StringVar(&flgOut, "out", "", "if given, write .csv file with question id and its canonical question id for all questions in clusters")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: duplicates [flags] <directory>\n")
+	fmt.Printf("  shows clusters of duplicate questions, using Posts.xml and PostLinks.xml in a directory\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func writeCanonical(g *graph.DuplicateGraph, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"QuestionId", "CanonicalId", "ClusterCanonicalId"})
+	for _, c := range g.Clusters() {
+		for _, id := range c.Questions {
+			w.Write([]string{strconv.Itoa(id), strconv.Itoa(g.Canonical(id)), strconv.Itoa(c.Canonical)})
+		}
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	dir := u.ExpandTildeInPath(flag.Arg(0))
+	timeStart := time.Now()
+	ds, err := stackoverflow.LoadDataset(dir, stackoverflow.TablePosts|stackoverflow.TablePostLinks)
+	if err != nil {
+		fmt.Printf("LoadDataset() failed with %s\n", err)
+		os.Exit(1)
+	}
+	g := graph.NewDuplicateGraph()
+	for i := range ds.PostLinks {
+		g.AddLink(&ds.PostLinks[i])
+	}
+	clusters := g.Clusters()
+	nQuestions := 0
+	for _, c := range clusters {
+		nQuestions += c.Size()
+	}
+	fmt.Printf("%d clusters with %d questions in %s\n", len(clusters), nQuestions, time.Since(timeStart))
+
+	title := func(id int) string {
+		if p := ds.Post(id); p != nil {
+			return p.Title
+		}
+		return "(unknown)"
+	}
+	for i, c := range clusters {
+		if i >= flgClusters {
+			break
+		}
+		fmt.Printf("%3d questions, %3d direct duplicates: %d %s\n", c.Size(), g.InDegree(c.Canonical), c.Canonical, title(c.Canonical))
+	}
+
+	tags := func(id int) []string {
+		if p := ds.Post(id); p != nil {
+			return p.Tags
+		}
+		return nil
+	}
+	fmt.Printf("\n%-24s %8s %10s %8s %8s\n", "tag", "clusters", "duplicates", "avg", "max")
+	for i, s := range g.TagStats(tags) {
+		if i >= flgTags {
+			break
+		}
+		fmt.Printf("%-24s %8d %10d %8.2f %8d\n", s.Tag, s.Clusters, s.Duplicates, s.AvgSize(), s.MaxSize)
+	}
+
+	if flgOut != "" {
+		if err = writeCanonical(g, flgOut); err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+}
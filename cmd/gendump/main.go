@@ -0,0 +1,870 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgOut        string
+	flgSeed       int64
+	flgUsers      int
+	flgQuestions  int
+	flgTags       int
+	flgAnswers    float64
+	flgComments   float64
+	flgStart      string
+	flgYears      int
+	flgSiteDomain string
+)
+
+func parseFlags() {
+	flag.StringVar(&flgOut, "out", "", "directory where generated .xml files will be written")
+	flag.Int64Var(&flgSeed, "seed", 1, "random seed. The same seed and sizes generate the same dump")
+	flag.IntVar(&flgUsers, "users", 1000, "number of users")
+	flag.IntVar(&flgQuestions, "questions", 5000, "number of questions")
+	flag.IntVar(&flgTags, "tags", 100, "number of tags")
+	flag.Float64Var(&flgAnswers, "answers", 1.5, "average number of answers per question")
+	flag.Float64Var(&flgComments, "comments", 1, "average number of comments per post")
+	flag.StringVar(&flgStart, "start", "2010-01-01", "date of the first question (YYYY-MM-DD)")
+	flag.IntVar(&flgYears, "years", 5, "how many years of activity to generate")
+	flag.StringVar(&flgSiteDomain, "site", "example.stackexchange.com", "domain used for links between posts")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: gendump -out <dir> [-seed 1] [-users 1000] [-questions 5000] [-tags 100] [-answers 1.5] [-comments 1]\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+const (
+	communityUserID = -1
+	day             = 24 * time.Hour
+)
+
+// genPost is a post with information about relationships to other
+// posts which are only turned into ids once all posts are generated
+type genPost struct {
+	stackoverflow.Post
+	question    *genPost
+	answers     []*genPost
+	accepted    *genPost
+	acceptDate  time.Time
+	tagName     string // for tag wiki posts
+	up          int
+	down        int
+	nFavorites  int
+	duplicateOf *genPost
+	linked      *genPost
+	closeReason int
+	editedBody  bool
+	// dates of up (+1) and down (-1) votes, sorted by date, for badges
+	scoreChanges []scoreChange
+}
+
+type scoreChange struct {
+	date  time.Time
+	delta int
+}
+
+type generator struct {
+	r     *rand.Rand
+	start time.Time
+	end   time.Time
+
+	users     []*stackoverflow.User
+	usersByID map[int]*stackoverflow.User
+	tagNames  []string
+	tagZipf   *rand.Zipf
+	posts     []*genPost
+
+	comments    []*stackoverflow.Comment
+	votes       []*stackoverflow.Vote
+	postHistory []*stackoverflow.PostHistory
+	postLinks   []*stackoverflow.PostLink
+	badges      []*stackoverflow.Badge
+	tags        []*stackoverflow.Tag
+}
+
+func newGenerator(seed int64, start time.Time, years int) *generator {
+	r := rand.New(rand.NewSource(seed))
+	return &generator{
+		r:         r,
+		start:     start,
+		end:       start.AddDate(years, 0, 0),
+		usersByID: map[int]*stackoverflow.User{},
+	}
+}
+
+// randTime returns random time between from and to
+func (g *generator) randTime(from, to time.Time) time.Time {
+	d := to.Sub(from)
+	if d <= 0 {
+		return from
+	}
+	t := from.Add(time.Duration(g.r.Int63n(int64(d))))
+	return t.Truncate(time.Millisecond)
+}
+
+// randAfter returns a time after t, on average mean later, but not after the end
+func (g *generator) randAfter(t time.Time, mean time.Duration) time.Time {
+	res := t.Add(time.Duration(g.r.ExpFloat64() * float64(mean))).Truncate(time.Millisecond)
+	if res.After(g.end) {
+		return g.randTime(t, g.end)
+	}
+	return res
+}
+
+// randCount returns a random, exponentially distributed, count with a given mean
+func (g *generator) randCount(mean float64) int {
+	return int(g.r.ExpFloat64() * mean)
+}
+
+// randUser returns a random user that existed at time t. Older users are more
+// active, which gives a long-tailed distribution of posts per user
+func (g *generator) randUser(t time.Time) *stackoverflow.User {
+	n := sort.Search(len(g.users), func(i int) bool {
+		return g.users[i].CreationDate.After(t)
+	})
+	if n <= 1 {
+		return g.users[0]
+	}
+	// users[0] is Community user which doesn't post
+	idx := 1 + int(float64(n-1)*math.Pow(g.r.Float64(), 2))
+	return g.users[idx]
+}
+
+func (g *generator) randOtherUser(t time.Time, notID int) *stackoverflow.User {
+	for i := 0; i < 5; i++ {
+		u := g.randUser(t)
+		if u.ID != notID {
+			return u
+		}
+	}
+	return g.randUser(t)
+}
+
+func (g *generator) randRevisionGUID() string {
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", g.r.Uint32(), g.r.Intn(1<<16), g.r.Intn(1<<16), g.r.Intn(1<<16), g.r.Int63n(1<<48))
+}
+
+func (g *generator) genUsers(n int) {
+	community := &stackoverflow.User{
+		ID:             communityUserID,
+		Reputation:     1,
+		CreationDate:   g.start.AddDate(0, -1, -1),
+		DisplayName:    "Community",
+		LastAccessDate: g.start.AddDate(0, -1, -1),
+		Location:       "on the server farm",
+		AboutMe:        "<p>Hi, I'm not really a person.</p>",
+		AccountID:      communityUserID,
+	}
+	g.users = append(g.users, community)
+	dates := make([]time.Time, n)
+	// the first user exists before the first question
+	dates[0] = g.start.AddDate(0, -1, 0)
+	for i := 1; i < n; i++ {
+		dates[i] = g.randTime(g.start.AddDate(0, -1, 0), g.end.Add(-day))
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	for i, d := range dates {
+		id := i + 1
+		u := &stackoverflow.User{
+			ID:           id,
+			CreationDate: d,
+			DisplayName:  genDisplayName(g.r, id),
+			AccountID:    id*3 + 1000,
+			Views:        g.randCount(20),
+		}
+		if g.r.Intn(3) == 0 {
+			u.AboutMe = genParagraph(g.r, sentence)
+		}
+		if g.r.Intn(2) == 0 {
+			u.Location = pick(g.r, []string{"Berlin, Germany", "New York, NY", "London, United Kingdom", "Bangalore, India", "São Paulo, Brazil", "Tokyo, Japan", "Toronto, Canada", "Warsaw, Poland"})
+		}
+		if g.r.Intn(5) == 0 {
+			u.WebsiteURL = fmt.Sprintf("https://blog%d.example.com", id)
+		}
+		if g.r.Intn(4) == 0 {
+			u.ProfileImageURL = fmt.Sprintf("https://i.example.com/profile/%d.png", id)
+		}
+		g.users = append(g.users, u)
+	}
+	for _, u := range g.users {
+		g.usersByID[u.ID] = u
+	}
+}
+
+func (g *generator) genTagNames(n int) {
+	g.tagNames = genTagNames(g.r, n)
+	// a few tags are very popular, most are rare
+	g.tagZipf = rand.NewZipf(g.r, 1.2, 2, uint64(n-1))
+}
+
+func (g *generator) randTags() []string {
+	n := 1 + g.r.Intn(4)
+	seen := map[string]bool{}
+	var res []string
+	for i := 0; i < n*2 && len(res) < n; i++ {
+		tag := g.tagNames[g.tagZipf.Uint64()]
+		if !seen[tag] {
+			seen[tag] = true
+			res = append(res, tag)
+		}
+	}
+	return res
+}
+
+func (g *generator) genScore(p *genPost, upMean, downMean float64) {
+	p.up = g.randCount(upMean)
+	p.down = g.randCount(downMean)
+}
+
+func (g *generator) genAnswer(q *genPost) *genPost {
+	created := g.randAfter(q.CreationDate, 2*day)
+	owner := g.randOtherUser(created, q.OwnerUserID)
+	a := &genPost{question: q}
+	a.PostTypeID = stackoverflow.PostAnswer
+	a.CreationDate = created
+	a.OwnerUserID = owner.ID
+	g.genScore(a, 3, 0.4)
+	return a
+}
+
+func (g *generator) genQuestions(n int, avgAnswers float64) {
+	dates := make([]time.Time, n)
+	for i := range dates {
+		dates[i] = g.randTime(g.start, g.end.Add(-day))
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	var questions []*genPost
+	for _, created := range dates {
+		q := &genPost{}
+		q.PostTypeID = stackoverflow.PostQuestion
+		q.CreationDate = created
+		q.OwnerUserID = g.randUser(created).ID
+		q.Tags = g.randTags()
+		q.Title = genTitle(g.r, q.Tags[0])
+		g.genScore(q, 2, 0.5)
+		q.ViewCount = 10 + q.up*25 + g.r.Intn(300)
+		q.nFavorites = g.randCount(float64(q.up) / 4)
+		nAnswers := g.randCount(avgAnswers)
+		for i := 0; i < nAnswers; i++ {
+			a := g.genAnswer(q)
+			q.answers = append(q.answers, a)
+		}
+		if len(q.answers) > 0 && g.r.Intn(10) < 6 {
+			// usually the best answer is accepted, but not always
+			best := q.answers[g.r.Intn(len(q.answers))]
+			for _, a := range q.answers {
+				if g.r.Intn(3) > 0 && a.up-a.down > best.up-best.down {
+					best = a
+				}
+			}
+			q.accepted = best
+			q.acceptDate = g.randAfter(best.CreationDate, day)
+		}
+		if len(questions) > 0 && g.r.Intn(10) == 0 {
+			q.linked = questions[g.r.Intn(len(questions))]
+		}
+		if g.r.Intn(20) == 0 {
+			q.ClosedDate = g.randAfter(created, 3*day)
+			q.closeReason = 102 + g.r.Intn(4)
+			if len(questions) > 0 && g.r.Intn(2) == 0 {
+				q.duplicateOf = questions[g.r.Intn(len(questions))]
+				q.closeReason = 101
+			}
+		}
+		questions = append(questions, q)
+		g.posts = append(g.posts, q)
+		g.posts = append(g.posts, q.answers...)
+	}
+}
+
+// genTagWikis generates tag excerpt and wiki posts for the most popular tags
+func (g *generator) genTagWikis() {
+	n := (len(g.tagNames) + 9) / 10
+	for _, tag := range g.tagNames[:n] {
+		for _, typ := range []int{stackoverflow.PostTagWikiExcerpt, stackoverflow.PostTagWiki} {
+			p := &genPost{tagName: tag}
+			p.PostTypeID = typ
+			p.CreationDate = g.randTime(g.start, g.start.AddDate(0, 1, 0))
+			p.OwnerUserID = communityUserID
+			g.posts = append(g.posts, p)
+		}
+	}
+}
+
+func (g *generator) postURL(id int) string {
+	return fmt.Sprintf("https://%s/questions/%d", flgSiteDomain, id)
+}
+
+// assignPostIDs gives posts ids in the order of creation, like in real dumps,
+// and resolves relationships between posts into ids
+func (g *generator) assignPostIDs() {
+	sort.SliceStable(g.posts, func(i, j int) bool {
+		return g.posts[i].CreationDate.Before(g.posts[j].CreationDate)
+	})
+	for i, p := range g.posts {
+		p.ID = i + 1
+	}
+	for _, p := range g.posts {
+		p.Score = p.up - p.down
+		p.LastActivitityDate = p.CreationDate
+		switch p.PostTypeID {
+		case stackoverflow.PostQuestion:
+			p.AnswerCount = len(p.answers)
+			p.FavoriteCount = p.nFavorites
+			if p.accepted != nil {
+				p.AcceptedAnswerID = p.accepted.ID
+			}
+			p.Body = genQuestionBody(g.r, p.Title)
+			if p.linked != nil {
+				p.Body += fmt.Sprintf("<p>This is related to <a href=\"%s\">%s</a>.</p>\n", g.postURL(p.linked.ID), p.linked.Title)
+			}
+			for _, a := range p.answers {
+				if a.CreationDate.After(p.LastActivitityDate) {
+					p.LastActivitityDate = a.CreationDate
+				}
+			}
+		case stackoverflow.PostAnswer:
+			p.ParentID = p.question.ID
+			p.Body = genAnswerBody(g.r)
+		case stackoverflow.PostTagWikiExcerpt:
+			p.Body = fmt.Sprintf("%s is a tag for questions about %s.", p.tagName, p.tagName)
+		case stackoverflow.PostTagWiki:
+			p.Body = fmt.Sprintf("<p>%s is a tag for questions about %s.</p>\n", p.tagName, p.tagName)
+		}
+	}
+}
+
+func (g *generator) addComment(p *genPost, created time.Time, user *stackoverflow.User, text string) {
+	c := &stackoverflow.Comment{
+		PostID:       p.ID,
+		Score:        g.randCount(0.5),
+		Text:         text,
+		CreationDate: created,
+		UserID:       user.ID,
+	}
+	g.comments = append(g.comments, c)
+	p.CommentCount++
+	if created.After(p.LastActivitityDate) {
+		p.LastActivitityDate = created
+	}
+}
+
+func (g *generator) genComments(avgComments float64) {
+	for _, p := range g.posts {
+		if p.PostTypeID != stackoverflow.PostQuestion && p.PostTypeID != stackoverflow.PostAnswer {
+			continue
+		}
+		n := g.randCount(avgComments)
+		for i := 0; i < n; i++ {
+			created := g.randAfter(p.CreationDate, 3*day)
+			user := g.randOtherUser(created, p.OwnerUserID)
+			text := genComment(g.r)
+			if g.r.Intn(3) == 0 {
+				owner := g.usersByID[p.OwnerUserID]
+				text = "@" + strings.Replace(owner.DisplayName, " ", "", -1) + " " + text
+			}
+			g.addComment(p, created, user, text)
+		}
+		if p.duplicateOf != nil {
+			created := p.ClosedDate.Add(-time.Hour)
+			if created.Before(p.CreationDate) {
+				created = p.CreationDate
+			}
+			user := g.randOtherUser(created, p.OwnerUserID)
+			g.addComment(p, created, user, fmt.Sprintf("Possible duplicate of [%s](%s)", p.duplicateOf.Title, g.postURL(p.duplicateOf.ID)))
+		}
+	}
+	sort.SliceStable(g.comments, func(i, j int) bool {
+		return g.comments[i].CreationDate.Before(g.comments[j].CreationDate)
+	})
+	for i, c := range g.comments {
+		c.ID = i + 1
+	}
+}
+
+func (g *generator) addVote(p *genPost, typ int, created time.Time, userID int, bountyAmount int) {
+	v := &stackoverflow.Vote{
+		PostID:       p.ID,
+		VoteTypeID:   typ,
+		UserID:       userID,
+		BountyAmount: bountyAmount,
+		// dumps only have dates of votes, not times
+		CreationDate: created.Truncate(day),
+	}
+	g.votes = append(g.votes, v)
+}
+
+// voter picks a user who cast an anonymous vote and updates their vote counts
+func (g *generator) voter(created time.Time, p *genPost, isUp bool) {
+	u := g.randOtherUser(created, p.OwnerUserID)
+	if isUp {
+		u.UpVotes++
+	} else {
+		u.DownVotes++
+	}
+}
+
+func (g *generator) genVotes() {
+	for _, p := range g.posts {
+		for i := 0; i < p.up+p.down; i++ {
+			isUp := i < p.up
+			created := g.randAfter(p.CreationDate, 30*day)
+			typ := stackoverflow.VoteDownMod
+			delta := -1
+			if isUp {
+				typ = stackoverflow.VoteUpMod
+				delta = 1
+			}
+			g.voter(created, p, isUp)
+			g.addVote(p, typ, created, 0, 0)
+			p.scoreChanges = append(p.scoreChanges, scoreChange{created.Truncate(day), delta})
+		}
+		sort.SliceStable(p.scoreChanges, func(i, j int) bool {
+			return p.scoreChanges[i].date.Before(p.scoreChanges[j].date)
+		})
+		if p.accepted != nil {
+			g.addVote(p.accepted, stackoverflow.VoteAcceptedByOriginator, p.acceptDate, 0, 0)
+		}
+		for i := 0; i < p.nFavorites; i++ {
+			created := g.randAfter(p.CreationDate, 60*day)
+			g.addVote(p, stackoverflow.VoteFavorite, created, g.randUser(created).ID, 0)
+		}
+		g.genBounty(p)
+	}
+	sort.SliceStable(g.votes, func(i, j int) bool {
+		return g.votes[i].CreationDate.Before(g.votes[j].CreationDate)
+	})
+	for i, v := range g.votes {
+		v.ID = i + 1
+	}
+}
+
+// genBounty occasionally starts a bounty on a question. Bounty is awarded
+// to the best answer when it closes
+func (g *generator) genBounty(q *genPost) {
+	if q.PostTypeID != stackoverflow.PostQuestion || len(q.answers) == 0 || g.r.Intn(30) != 0 {
+		return
+	}
+	start := q.CreationDate.Add(2 * day)
+	closed := start.Add(7 * day)
+	if closed.After(g.end) {
+		return
+	}
+	amount := 50 * (1 + g.r.Intn(10))
+	user := g.usersByID[q.OwnerUserID]
+	if g.r.Intn(4) == 0 {
+		user = g.randOtherUser(start, q.OwnerUserID)
+	}
+	g.addVote(q, stackoverflow.VoteBountyStart, start, user.ID, amount)
+	user.Reputation -= amount
+	var best *genPost
+	for _, a := range q.answers {
+		if a.CreationDate.Before(closed) && (best == nil || a.up > best.up) {
+			best = a
+		}
+	}
+	if best == nil || g.r.Intn(5) == 0 {
+		// expired without award
+		return
+	}
+	g.addVote(best, stackoverflow.VoteBountyClose, closed, 0, amount)
+	g.usersByID[best.OwnerUserID].Reputation += amount
+}
+
+func (g *generator) addHistory(p *genPost, typ int, guid string, created time.Time, userID int, text string, comment string) {
+	h := &stackoverflow.PostHistory{
+		PostHistoryTypeID: typ,
+		PostID:            p.ID,
+		RevisionGUID:      guid,
+		CreationDate:      created,
+		UserID:            userID,
+		Text:              text,
+		Comment:           comment,
+	}
+	g.postHistory = append(g.postHistory, h)
+}
+
+func (g *generator) genPostHistory() {
+	for _, p := range g.posts {
+		guid := g.randRevisionGUID()
+		body := p.Body
+		if p.PostTypeID == stackoverflow.PostQuestion || p.PostTypeID == stackoverflow.PostAnswer {
+			p.editedBody = g.r.Intn(5) == 0
+		}
+		if p.editedBody {
+			// initial version of the body didn't have the last paragraph
+			idx := strings.LastIndex(strings.TrimSuffix(body, "\n"), "\n")
+			if idx > 0 {
+				body = body[:idx+1]
+			}
+		}
+		if p.PostTypeID == stackoverflow.PostQuestion {
+			g.addHistory(p, stackoverflow.HistoryInitialTitle, guid, p.CreationDate, p.OwnerUserID, p.Title, "")
+		}
+		g.addHistory(p, stackoverflow.HistoryInitialBody, guid, p.CreationDate, p.OwnerUserID, body, "")
+		if p.PostTypeID == stackoverflow.PostQuestion {
+			g.addHistory(p, stackoverflow.HistoryInitialTags, guid, p.CreationDate, p.OwnerUserID, "<"+strings.Join(p.Tags, "><")+">", "")
+		}
+		if p.editedBody {
+			created := g.randAfter(p.CreationDate, 5*day)
+			editor := g.usersByID[p.OwnerUserID]
+			if g.r.Intn(2) == 0 {
+				editor = g.randUser(created)
+			}
+			g.addHistory(p, stackoverflow.HistoryEditBody, g.randRevisionGUID(), created, editor.ID, p.Body, "added details")
+			p.LastEditorUserID = editor.ID
+			p.LastEditDate = created
+			if created.After(p.LastActivitityDate) {
+				p.LastActivitityDate = created
+			}
+		}
+		if p.closeReason != 0 {
+			g.genClose(p)
+		}
+	}
+	sort.SliceStable(g.postHistory, func(i, j int) bool {
+		return g.postHistory[i].CreationDate.Before(g.postHistory[j].CreationDate)
+	})
+	for i, h := range g.postHistory {
+		h.ID = i + 1
+	}
+}
+
+func (g *generator) genClose(q *genPost) {
+	var voters []string
+	for i := 0; i < 5; i++ {
+		u := g.randOtherUser(q.ClosedDate, q.OwnerUserID)
+		voters = append(voters, fmt.Sprintf(`{"Id":%d,"DisplayName":"%s"}`, u.ID, u.DisplayName))
+	}
+	text := `{"Voters":[` + strings.Join(voters, ",") + `]}`
+	if q.duplicateOf != nil {
+		text = fmt.Sprintf(`{"OriginalQuestionIds":[%d],"Voters":[%s]}`, q.duplicateOf.ID, strings.Join(voters, ","))
+	}
+	g.addHistory(q, stackoverflow.HistoryPostClosed, g.randRevisionGUID(), q.ClosedDate, communityUserID, text, fmt.Sprintf("%d", q.closeReason))
+}
+
+func (g *generator) genPostLinks() {
+	for _, p := range g.posts {
+		if p.linked != nil {
+			l := &stackoverflow.PostLink{
+				CreationDate:  p.CreationDate,
+				PostID:        p.ID,
+				RelatedPostID: p.linked.ID,
+				LinkTypeID:    stackoverflow.LinkTypeLinked,
+			}
+			g.postLinks = append(g.postLinks, l)
+		}
+		if p.duplicateOf != nil {
+			l := &stackoverflow.PostLink{
+				CreationDate:  p.ClosedDate,
+				PostID:        p.ID,
+				RelatedPostID: p.duplicateOf.ID,
+				LinkTypeID:    stackoverflow.LinkTypeDuplicate,
+			}
+			g.postLinks = append(g.postLinks, l)
+		}
+	}
+	sort.SliceStable(g.postLinks, func(i, j int) bool {
+		return g.postLinks[i].CreationDate.Before(g.postLinks[j].CreationDate)
+	})
+	for i, l := range g.postLinks {
+		l.ID = i + 1
+	}
+}
+
+func (g *generator) addBadge(userID int, name string, date time.Time) {
+	b := &stackoverflow.Badge{
+		UserID: userID,
+		Name:   name,
+		Date:   date,
+	}
+	g.badges = append(g.badges, b)
+}
+
+// dateScoreReached returns date when the score of the post first reached
+// a given value or zero time if it never did
+func dateScoreReached(p *genPost, score int) time.Time {
+	n := 0
+	for _, c := range p.scoreChanges {
+		n += c.delta
+		if n >= score {
+			return c.date
+		}
+	}
+	return time.Time{}
+}
+
+// genBadges awards a few common badges following the rules of the site
+func (g *generator) genBadges() {
+	student := map[int]bool{}
+	teacher := map[int]bool{}
+	enlightened := map[int]bool{}
+	for _, p := range g.posts {
+		if p.OwnerUserID <= 0 {
+			continue
+		}
+		switch p.PostTypeID {
+		case stackoverflow.PostQuestion:
+			if d := dateScoreReached(p, 1); !d.IsZero() && !student[p.OwnerUserID] {
+				student[p.OwnerUserID] = true
+				g.addBadge(p.OwnerUserID, "Student", d)
+			}
+		case stackoverflow.PostAnswer:
+			if d := dateScoreReached(p, 1); !d.IsZero() && !teacher[p.OwnerUserID] {
+				teacher[p.OwnerUserID] = true
+				g.addBadge(p.OwnerUserID, "Teacher", d)
+			}
+			for _, b := range []struct {
+				name  string
+				score int
+			}{{"Nice Answer", 10}, {"Good Answer", 25}, {"Great Answer", 100}} {
+				if d := dateScoreReached(p, b.score); !d.IsZero() {
+					g.addBadge(p.OwnerUserID, b.name, d)
+				}
+			}
+			q := p.question
+			if q.accepted == p && !enlightened[p.OwnerUserID] {
+				if d := dateScoreReached(p, 10); !d.IsZero() {
+					if q.acceptDate.After(d) {
+						d = q.acceptDate.Truncate(day)
+					}
+					enlightened[p.OwnerUserID] = true
+					g.addBadge(p.OwnerUserID, "Enlightened", d)
+				}
+			}
+			if p.CreationDate.Sub(q.CreationDate) > 60*day {
+				if d := dateScoreReached(p, 5); !d.IsZero() {
+					g.addBadge(p.OwnerUserID, "Necromancer", d)
+				}
+			}
+		}
+	}
+	for _, u := range g.users[1:] {
+		if u.AboutMe != "" {
+			g.addBadge(u.ID, "Autobiographer", u.CreationDate.Add(time.Hour))
+		}
+	}
+	sort.SliceStable(g.badges, func(i, j int) bool {
+		return g.badges[i].Date.Before(g.badges[j].Date)
+	})
+	for i, b := range g.badges {
+		b.ID = i + 1
+	}
+}
+
+func (g *generator) genTags() {
+	counts := map[string]int{}
+	excerpts := map[string]int{}
+	wikis := map[string]int{}
+	for _, p := range g.posts {
+		switch p.PostTypeID {
+		case stackoverflow.PostQuestion:
+			for _, tag := range p.Tags {
+				counts[tag]++
+			}
+		case stackoverflow.PostTagWikiExcerpt:
+			excerpts[p.tagName] = p.ID
+		case stackoverflow.PostTagWiki:
+			wikis[p.tagName] = p.ID
+		}
+	}
+	for i, name := range g.tagNames {
+		t := &stackoverflow.Tag{
+			ID:            i + 1,
+			TagName:       name,
+			Count:         counts[name],
+			ExcerptPostID: excerpts[name],
+			WikiPostID:    wikis[name],
+		}
+		g.tags = append(g.tags, t)
+	}
+}
+
+// updateUsers calculates reputation and last access date of users based on
+// generated posts and votes
+func (g *generator) updateUsers() {
+	lastActivity := map[int]time.Time{}
+	activity := func(userID int, t time.Time) {
+		if t.After(lastActivity[userID]) {
+			lastActivity[userID] = t
+		}
+	}
+	for _, p := range g.posts {
+		owner := g.usersByID[p.OwnerUserID]
+		activity(owner.ID, p.CreationDate)
+		switch p.PostTypeID {
+		case stackoverflow.PostQuestion:
+			owner.Reputation += 5*p.up - 2*p.down
+			if p.accepted != nil {
+				owner.Reputation += 2
+				activity(owner.ID, p.acceptDate)
+			}
+		case stackoverflow.PostAnswer:
+			owner.Reputation += 10*p.up - 2*p.down
+			if p.question.accepted == p {
+				owner.Reputation += 15
+			}
+		}
+	}
+	for _, c := range g.comments {
+		activity(c.UserID, c.CreationDate)
+	}
+	for _, h := range g.postHistory {
+		activity(h.UserID, h.CreationDate)
+	}
+	for _, u := range g.users[1:] {
+		u.Reputation++
+		if u.Reputation < 1 {
+			u.Reputation = 1
+		}
+		u.LastAccessDate = lastActivity[u.ID]
+		if u.LastAccessDate.IsZero() {
+			u.LastAccessDate = g.randAfter(u.CreationDate, 10*day)
+		}
+	}
+}
+
+func (g *generator) generate(nUsers, nQuestions, nTags int, avgAnswers, avgComments float64) {
+	timeStart := time.Now()
+	g.genUsers(nUsers)
+	g.genTagNames(nTags)
+	g.genQuestions(nQuestions, avgAnswers)
+	g.genTagWikis()
+	g.assignPostIDs()
+	g.genComments(avgComments)
+	g.genVotes()
+	g.genPostHistory()
+	g.genPostLinks()
+	g.genBadges()
+	g.genTags()
+	g.updateUsers()
+	fmt.Printf("generated %d users, %d posts, %d comments, %d votes, %d post history entries, %d post links, %d badges, %d tags in %s\n", len(g.users), len(g.posts), len(g.comments), len(g.votes), len(g.postHistory), len(g.postLinks), len(g.badges), len(g.tags), time.Since(timeStart))
+}
+
+func (g *generator) write(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	w, err := stackoverflow.NewUsersWriterToFile(filepath.Join(dir, "Users.xml"))
+	if err != nil {
+		return err
+	}
+	for _, u := range g.users {
+		w.WriteUser(u)
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	w, err = stackoverflow.NewPostsWriterToFile(filepath.Join(dir, "Posts.xml"))
+	if err != nil {
+		return err
+	}
+	for _, p := range g.posts {
+		w.WritePost(&p.Post)
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	w, err = stackoverflow.NewCommentsWriterToFile(filepath.Join(dir, "Comments.xml"))
+	if err != nil {
+		return err
+	}
+	for _, c := range g.comments {
+		w.WriteComment(c)
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	w, err = stackoverflow.NewVotesWriterToFile(filepath.Join(dir, "Votes.xml"))
+	if err != nil {
+		return err
+	}
+	for _, v := range g.votes {
+		w.WriteVote(v)
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	w, err = stackoverflow.NewPostHistoryWriterToFile(filepath.Join(dir, "PostHistory.xml"))
+	if err != nil {
+		return err
+	}
+	for _, h := range g.postHistory {
+		w.WritePostHistory(h)
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	w, err = stackoverflow.NewPostLinksWriterToFile(filepath.Join(dir, "PostLinks.xml"))
+	if err != nil {
+		return err
+	}
+	for _, l := range g.postLinks {
+		w.WritePostLink(l)
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	w, err = stackoverflow.NewBadgesWriterToFile(filepath.Join(dir, "Badges.xml"))
+	if err != nil {
+		return err
+	}
+	for _, b := range g.badges {
+		w.WriteBadge(b)
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	w, err = stackoverflow.NewTagsWriterToFile(filepath.Join(dir, "Tags.xml"))
+	if err != nil {
+		return err
+	}
+	for _, t := range g.tags {
+		w.WriteTag(t)
+	}
+	return w.Close()
+}
+
+func main() {
+	parseFlags()
+	if flgOut == "" || flgUsers < 1 || flgQuestions < 1 || flgTags < 1 || flgYears < 1 {
+		usageAndExit()
+	}
+	start, err := time.Parse("2006-01-02", flgStart)
+	if err != nil {
+		fmt.Printf("invalid -start date '%s'\n", flgStart)
+		usageAndExit()
+	}
+	g := newGenerator(flgSeed, start, flgYears)
+	g.generate(flgUsers, flgQuestions, flgTags, flgAnswers, flgComments)
+	dir := u.ExpandTildeInPath(flgOut)
+	err = g.write(dir)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("wrote dump to %s\n", dir)
+}
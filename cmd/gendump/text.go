@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// word lists for generating fake, but plausibly looking, text
+
+var (
+	firstNames = []string{"Alice", "Bob", "Carol", "Dave", "Eve", "Frank", "Grace", "Heidi", "Ivan", "Judy", "Mallory", "Niaj", "Olivia", "Peggy", "Rupert", "Sybil", "Trent", "Victor", "Walter", "Yuki", "Zoe", "Amir", "Bea", "Chen", "Dmitri", "Elena", "Farid", "Gita", "Hugo", "Ines"}
+	lastNames  = []string{"Smith", "Jones", "Garcia", "Kowalski", "Nguyen", "Müller", "Rossi", "Tanaka", "Silva", "Novak", "Brown", "Ivanov", "Kim", "Dubois", "Jensen", "Khan", "Lopez", "Cohen", "Olsen", "Park"}
+
+	tagWords    = []string{"go", "python", "java", "javascript", "c", "rust", "sql", "linux", "windows", "http", "json", "xml", "regex", "git", "docker", "css", "html", "android", "ios", "swift", "kotlin", "ruby", "php", "bash", "nginx", "mysql", "postgresql", "sqlite", "redis", "react", "vue", "node", "django", "flask", "spring", "unicode", "csv", "performance", "testing", "security"}
+	tagSuffixes = []string{"", "", "", "-modules", "-2", "-3", "-api", "-testing", "-performance", "-client", "-server"}
+
+	verbs          = []string{"parse", "sort", "convert", "read", "write", "split", "merge", "format", "compare", "serialize", "validate", "debug", "install", "configure", "optimize", "cache", "encode", "decode", "iterate over", "deploy"}
+	nouns          = []string{"a string", "a list", "a map", "a file", "a date", "an array", "a struct", "JSON", "a database row", "a request", "a response", "a channel", "a slice", "a pointer", "a config file", "a large file", "nested objects", "a timestamp", "an interface", "a socket"}
+	sentence       = []string{"I tried the obvious approach but it doesn't work.", "This is what I have so far.", "The documentation is not clear about this.", "It works on my machine but fails in production.", "Is there a more idiomatic way?", "I get an error at runtime.", "Performance is really bad for large inputs.", "I searched but couldn't find an answer.", "Here is a minimal example.", "Any help is appreciated."}
+	answerSentence = []string{"You should use the standard library for this.", "The problem is that you're modifying it while iterating.", "Try this instead:", "This is a known issue, see the docs.", "You need to check the error.", "Use a buffered reader, it's much faster.", "The simplest way is to do it in two steps.", "Your code has a race condition."}
+	commentText    = []string{"Thanks, that worked!", "Can you show the full error message?", "What version are you using?", "This doesn't compile for me.", "Possible duplicate.", "+1, great explanation.", "Have you tried profiling it?", "Please add a minimal reproducible example."}
+	codeLines      = []string{"x := make([]int, 0, n)", "for i := range items {", "}", "err := doSomething()", "if err != nil {", "    return err", "fmt.Println(result)", "data, _ := ioutil.ReadAll(r)"}
+)
+
+func pick(r *rand.Rand, a []string) string {
+	return a[r.Intn(len(a))]
+}
+
+func genDisplayName(r *rand.Rand, id int) string {
+	switch r.Intn(4) {
+	case 0:
+		return fmt.Sprintf("user%d", id)
+	case 1:
+		return pick(r, firstNames)
+	default:
+		return pick(r, firstNames) + " " + pick(r, lastNames)
+	}
+}
+
+func genTagNames(r *rand.Rand, n int) []string {
+	seen := map[string]bool{}
+	var res []string
+	for len(res) < n {
+		name := pick(r, tagWords) + pick(r, tagSuffixes)
+		if len(seen) >= len(tagWords)*len(tagSuffixes)/2 {
+			// ran out of plausible names
+			name = fmt.Sprintf("%s-%d", pick(r, tagWords), len(res))
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		res = append(res, name)
+	}
+	return res
+}
+
+func genTitle(r *rand.Rand, tag string) string {
+	return fmt.Sprintf("How to %s %s in %s?", pick(r, verbs), pick(r, nouns), tag)
+}
+
+func genCode(r *rand.Rand) string {
+	n := 1 + r.Intn(4)
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = pick(r, codeLines)
+	}
+	return "<pre><code>" + strings.Join(lines, "\n") + "\n</code></pre>\n"
+}
+
+func genParagraph(r *rand.Rand, sentences []string) string {
+	n := 1 + r.Intn(3)
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = pick(r, sentences)
+	}
+	return "<p>" + strings.Join(parts, " ") + "</p>\n"
+}
+
+func genQuestionBody(r *rand.Rand, title string) string {
+	s := "<p>" + title + "</p>\n" + genParagraph(r, sentence)
+	if r.Intn(2) == 0 {
+		s += genCode(r)
+	}
+	return s + genParagraph(r, sentence)
+}
+
+func genAnswerBody(r *rand.Rand) string {
+	s := genParagraph(r, answerSentence)
+	if r.Intn(3) > 0 {
+		s += genCode(r)
+	}
+	return s
+}
+
+func genComment(r *rand.Rand) string {
+	return pick(r, commentText)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/index"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgDB       string
+	flgPost     int
+	flgUser     int
+	flgThread   int
+	flgRevs     int
+	flgAt       string
+	flgDiff     string
+	flgDiffHTML bool
+	flgNoAnswer bool
+)
+
+func parseFlags() {
+	flag.StringVar(&flgDB, "db", "", "path of index file")
+	flag.IntVar(&flgPost, "post", 0, "show post with this id, its comments and answers")
+	flag.IntVar(&flgUser, "user", 0, "show user with this id and their badges")
+	flag.IntVar(&flgThread, "thread", 0, "show question with this id with answers, comments, links and owners")
+	flag.IntVar(&flgRevs, "revisions", 0, "show revisions of post with this id")
+	flag.StringVar(&flgAt, "at", "", "with -revisions, only show revision current at this time e.g. 2015-03-01T10:00:00")
+	flag.StringVar(&flgDiff, "diff", "", "with -revisions, show diff between 2 revisions e.g. 1,3")
+	flag.BoolVar(&flgDiffHTML, "html", false, "with -diff, show word-level diff as html")
+	flag.BoolVar(&flgNoAnswer, "no-answers", false, "with -post, don't show answers")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage:\n")
+	fmt.Printf("  index -db so.idx <directory>: index all .xml files in a directory\n")
+	fmt.Printf("  index -db so.idx -post <id> | -user <id> | -thread <id> | -revisions <id>: show records from index\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+var newReaders = []struct {
+	name      string
+	newReader func(string) (*stackoverflow.Reader, error)
+}{
+	{"badges", stackoverflow.NewBadgesReaderFromFile},
+	{"comments", stackoverflow.NewCommentsReaderFromFile},
+	{"posthistory", stackoverflow.NewPostHistoryReaderFromFile},
+	{"postlinks", stackoverflow.NewPostLinksReaderFromFile},
+	{"posts", stackoverflow.NewPostsReaderFromFile},
+	{"tags", stackoverflow.NewTagsReaderFromFile},
+	{"users", stackoverflow.NewUsersReaderFromFile},
+	{"votes", stackoverflow.NewVotesReaderFromFile},
+}
+
+func buildIndex(dir string) error {
+	idx, err := index.Create(flgDB)
+	if err != nil {
+		return err
+	}
+	for _, nr := range newReaders {
+		path := stackoverflow.FindXMLFile(dir, nr.name+".xml")
+		if path == "" {
+			fmt.Printf("skipping %s, no .xml file in '%s'\n", nr.name, dir)
+			continue
+		}
+		timeStart := time.Now()
+		r, err := nr.newReader(path)
+		if err != nil {
+			idx.Close()
+			return err
+		}
+		n, err := idx.AddFromReader(r)
+		r.Close()
+		if err != nil {
+			idx.Close()
+			return err
+		}
+		fmt.Printf("indexed %d %s in %s\n", n, nr.name, time.Since(timeStart))
+	}
+	return idx.Close()
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func showPost(idx *index.Index, id int) error {
+	p, err := idx.Post(id)
+	if err != nil {
+		return err
+	}
+	comments, err := idx.Comments(id)
+	if err != nil {
+		return err
+	}
+	res := map[string]interface{}{
+		"post":     p,
+		"comments": comments,
+	}
+	if !flgNoAnswer && p.PostTypeID == stackoverflow.PostQuestion {
+		answers, err := idx.Answers(id)
+		if err != nil {
+			return err
+		}
+		res["answers"] = answers
+	}
+	printJSON(res)
+	return nil
+}
+
+func showUser(idx *index.Index, id int) error {
+	user, err := idx.User(id)
+	if err != nil {
+		return err
+	}
+	badges, err := idx.Badges(id)
+	if err != nil {
+		return err
+	}
+	printJSON(map[string]interface{}{
+		"user":   user,
+		"badges": badges,
+	})
+	return nil
+}
+
+func showRevisions(idx *index.Index, id int) error {
+	revs, err := idx.Revisions(id)
+	if err != nil {
+		return err
+	}
+	if flgDiff != "" {
+		return showDiff(id, revs)
+	}
+	if flgAt == "" {
+		printJSON(revs)
+		return nil
+	}
+	t, err := time.Parse(stackoverflow.TimeFormat, flgAt)
+	if err != nil {
+		return err
+	}
+	rev := stackoverflow.RevisionAt(revs, t)
+	if rev == nil {
+		return fmt.Errorf("post %d didn't exist at %s", id, flgAt)
+	}
+	printJSON(rev)
+	return nil
+}
+
+func showDiff(id int, revs []*stackoverflow.Revision) error {
+	var from, to int
+	if _, err := fmt.Sscanf(flgDiff, "%d,%d", &from, &to); err != nil {
+		return fmt.Errorf("invalid -diff '%s', should be e.g. 1,3", flgDiff)
+	}
+	if from < 1 || from > len(revs) || to < 1 || to > len(revs) {
+		return fmt.Errorf("post %d has %d revisions", id, len(revs))
+	}
+	revFrom, revTo := revs[from-1], revs[to-1]
+	d := stackoverflow.DiffRevisions(revFrom, revTo, flgDiffHTML)
+	if flgDiffHTML {
+		fmt.Printf("<h1>%s</h1>\n", stackoverflow.DiffHTML(d.Title))
+		fmt.Printf("<pre>%s</pre>\n", stackoverflow.DiffHTML(d.Body))
+		return nil
+	}
+	if revFrom.Title != revTo.Title {
+		fmt.Printf("-title: %s\n+title: %s\n", revFrom.Title, revTo.Title)
+	}
+	if len(d.TagsAdded) > 0 || len(d.TagsRemoved) > 0 {
+		fmt.Printf("tags added: %v, removed: %v\n", d.TagsAdded, d.TagsRemoved)
+	}
+	nameFrom := fmt.Sprintf("revision %d (%s)", from, revFrom.CreationDate.Format(stackoverflow.TimeFormat))
+	nameTo := fmt.Sprintf("revision %d (%s)", to, revTo.CreationDate.Format(stackoverflow.TimeFormat))
+	fmt.Print(stackoverflow.UnifiedDiff(revFrom.Body, revTo.Body, nameFrom, nameTo, 3))
+	stats := stackoverflow.GetDiffStats(stackoverflow.DiffWords(revFrom.Body, revTo.Body))
+	fmt.Printf("words: %d inserted, %d deleted, %d unchanged, %.1f%% changed\n", stats.Inserted, stats.Deleted, stats.Equal, stats.ChangeRatio()*100)
+	return nil
+}
+
+func main() {
+	parseFlags()
+	if flgDB == "" {
+		usageAndExit()
+	}
+	flgDB = u.ExpandTildeInPath(flgDB)
+	var err error
+	if flgPost == 0 && flgUser == 0 && flgThread == 0 && flgRevs == 0 {
+		if flag.NArg() != 1 {
+			usageAndExit()
+		}
+		err = buildIndex(u.ExpandTildeInPath(flag.Arg(0)))
+	} else {
+		var idx *index.Index
+		idx, err = index.Open(flgDB)
+		if err == nil {
+			if flgPost != 0 {
+				err = showPost(idx, flgPost)
+			}
+			if err == nil && flgUser != 0 {
+				err = showUser(idx, flgUser)
+			}
+			if err == nil && flgThread != 0 {
+				var t *stackoverflow.Thread
+				if t, err = idx.Thread(flgThread); err == nil {
+					printJSON(t)
+				}
+			}
+			if err == nil && flgRevs != 0 {
+				err = showRevisions(idx, flgRevs)
+			}
+			idx.Close()
+		}
+	}
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+}
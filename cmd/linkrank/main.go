@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/graph"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgOut    string
+	flgTag    string
+	flgN      int
+	flgSite   string
+	flgNoBody bool
+	flgPosts  bool
+)
+
+func parseFlags() {
+	flag.StringVar(&flgOut, "out", "", "if given, write .csv file with scores of all posts")
+	flag.StringVar(&flgTag, "tag", "", "show most referenced questions with this tag")
+	flag.IntVar(&flgN, "n", 20, "number of questions to show")
+	flag.StringVar(&flgSite, "site", "stackoverflow.com", "site of links in post bodies. Links to other sites are ignored")
+	flag.BoolVar(&flgNoBody, "no-body", false, "only use PostLinks.xml, don't extract links from post bodies")
+	flag.BoolVar(&flgPosts, "posts", false, "keep answers as separate nodes instead of merging them into their questions")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: linkrank [flags] <directory>\n")
+	fmt.Printf("  computes PageRank and other centrality scores of questions in graph of links, using Posts.xml and PostLinks.xml in a directory\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func writeScores(scores []graph.NodeScore, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"PostId", "InDegree", "OutDegree", "PageRank", "Hub", "Authority"})
+	for _, s := range scores {
+		w.Write([]string{
+			strconv.Itoa(s.ID),
+			strconv.Itoa(s.InDegree),
+			strconv.Itoa(s.OutDegree),
+			strconv.FormatFloat(s.PageRank, 'g', 6, 64),
+			strconv.FormatFloat(s.Hub, 'g', 6, 64),
+			strconv.FormatFloat(s.Authority, 'g', 6, 64),
+		})
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func hasTag(p *stackoverflow.Post, tag string) bool {
+	for _, t := range p.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func openReader(dir string, name string, open func(path string) (*stackoverflow.Reader, error)) (*stackoverflow.Reader, error) {
+	path := stackoverflow.FindXMLFile(dir, name)
+	if path == "" {
+		return nil, fmt.Errorf("no %s in '%s'", name, dir)
+	}
+	return open(path)
+}
+
+// readPosts reads Posts.xml and returns map of answer ids to ids of their
+// questions and links from bodies of posts, as pairs of post ids. Only
+// those are kept in memory, not whole posts
+func readPosts(dir string, withBody bool) (map[int]int, [][2]int, error) {
+	r, err := openReader(dir, "Posts.xml", stackoverflow.NewPostsReaderFromFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+	questionOf := map[int]int{}
+	var links [][2]int
+	for r.Next() {
+		p := &r.Post
+		if p.PostTypeID == stackoverflow.PostAnswer && p.ParentID != 0 {
+			questionOf[p.ID] = p.ParentID
+		}
+		if withBody {
+			for _, id := range graph.ExtractPostLinks(p.Body, flgSite) {
+				links = append(links, [2]int{p.ID, id})
+			}
+		}
+	}
+	return questionOf, links, r.Err()
+}
+
+func addPostLinks(g *graph.LinkGraph, dir string) error {
+	r, err := openReader(dir, "PostLinks.xml", stackoverflow.NewPostLinksReaderFromFile)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for r.Next() {
+		g.AddLink(&r.PostLink)
+	}
+	return r.Err()
+}
+
+type topQuestion struct {
+	rank  int
+	title string
+}
+
+// topQuestions returns up to n questions (with a tag, if not empty) with
+// the best rank, sorted by rank. It reads Posts.xml again to not keep
+// titles and tags of all posts in memory
+func topQuestions(dir string, rank map[int]int, tag string, n int) ([]topQuestion, error) {
+	r, err := openReader(dir, "Posts.xml", stackoverflow.NewPostsReaderFromFile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var res []topQuestion
+	byRank := func() {
+		sort.Slice(res, func(i, j int) bool { return res[i].rank < res[j].rank })
+		if len(res) > n {
+			res = res[:n]
+		}
+	}
+	for r.Next() {
+		p := &r.Post
+		if p.PostTypeID != stackoverflow.PostQuestion {
+			continue
+		}
+		i, ok := rank[p.ID]
+		if !ok || (tag != "" && !hasTag(p, tag)) {
+			continue
+		}
+		res = append(res, topQuestion{rank: i, title: p.Title})
+		if len(res) >= 2*n+1024 {
+			byRank()
+		}
+	}
+	byRank()
+	return res, r.Err()
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	dir := u.ExpandTildeInPath(flag.Arg(0))
+	timeStart := time.Now()
+	var questionOf map[int]int
+	var bodyLinks [][2]int
+	var err error
+	if !flgPosts || !flgNoBody {
+		questionOf, bodyLinks, err = readPosts(dir, !flgNoBody)
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	g := graph.NewLinkGraph()
+	if !flgPosts {
+		g.NodeOf = func(id int) int {
+			if q, ok := questionOf[id]; ok {
+				return q
+			}
+			return id
+		}
+	}
+	if err = addPostLinks(g, dir); err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+	for _, l := range bodyLinks {
+		g.AddEdge(l[0], l[1])
+	}
+	bodyLinks = nil
+	scores := g.Scores()
+	fmt.Printf("%d posts with %d links in %s\n", g.NumNodes(), g.NumEdges(), time.Since(timeStart))
+
+	if flgOut != "" {
+		if err = writeScores(scores, flgOut); err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	rank := make(map[int]int, len(scores))
+	for i, s := range scores {
+		rank[s.ID] = i
+	}
+	top, err := topQuestions(dir, rank, flgTag, flgN)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+	if flgTag != "" {
+		fmt.Printf("most referenced questions tagged %s:\n", flgTag)
+	} else {
+		fmt.Printf("most referenced questions:\n")
+	}
+	for i, q := range top {
+		s := scores[q.rank]
+		fmt.Printf("%3d. %s\n", i+1, q.title)
+		fmt.Printf("     https://%s/q/%d pagerank: %.6f, linked from: %d, authority: %.4f\n", flgSite, s.ID, s.PageRank, s.InDegree, s.Authority)
+	}
+}
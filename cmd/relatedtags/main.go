@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/graph"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgTag      string
+	flgN        int
+	flgMin      int
+	flgClusters int
+	flgOut      string
+	flgDot      string
+)
+
+func parseFlags() {
+	flag.StringVar(&flgTag, "tag", "", "show tags related to this tag")
+	flag.IntVar(&flgN, "n", 20, "number of related tags (or pairs of tags) to show")
+	flag.IntVar(&flgMin, "min", 2, "ignore pairs of tags used together on fewer questions")
+	flag.IntVar(&flgClusters, "clusters", 0, "if > 0, show this many largest topic clusters of tags")
+	flag.StringVar(&flgOut, "out", "", "if given, write .csv file with pairs of tags and their similarity")
+	flag.StringVar(&flgDot, "dot", "", "if given, write graph of tags in Graphviz .dot format")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: relatedtags [flags] <directory>\n")
+	fmt.Printf("  shows tags used together on questions, using Posts.xml in a directory\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func writeFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	dir := u.ExpandTildeInPath(flag.Arg(0))
+	timeStart := time.Now()
+	path := stackoverflow.FindXMLFile(dir, "Posts.xml")
+	if path == "" {
+		fmt.Printf("error: no Posts.xml in '%s'\n", dir)
+		os.Exit(1)
+	}
+	r, err := stackoverflow.NewPostsReaderFromFile(path)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+	g, err := graph.ReadTagGraph(r)
+	r.Close()
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d questions, %d tags, %d pairs of tags in %s\n", g.NumQuestions(), g.NumTags(), g.NumPairs(), time.Since(timeStart))
+
+	if flgTag != "" {
+		fmt.Printf("\ntags related to %s (%d questions):\n", flgTag, g.Count(flgTag))
+		fmt.Printf("%-24s %8s %8s %8s\n", "tag", "count", "jaccard", "pmi")
+		for _, rt := range g.Related(flgTag, flgN, flgMin) {
+			fmt.Printf("%-24s %8d %8.4f %8.3f\n", rt.Tag, rt.Count, rt.Jaccard, rt.PMI)
+		}
+	} else {
+		fmt.Printf("\n%-24s %-24s %8s %8s %8s\n", "tag", "tag", "count", "jaccard", "pmi")
+		for i, p := range g.Pairs(flgMin) {
+			if i >= flgN {
+				break
+			}
+			fmt.Printf("%-24s %-24s %8d %8.4f %8.3f\n", p.A, p.B, p.Count, p.Jaccard, p.PMI)
+		}
+	}
+
+	if flgClusters > 0 {
+		fmt.Printf("\ntopic clusters:\n")
+		for i, c := range g.Clusters(flgMin, 20) {
+			if i >= flgClusters {
+				break
+			}
+			tags := c.Tags
+			if len(tags) > 10 {
+				tags = tags[:10]
+			}
+			fmt.Printf("%3d tags, %6d questions: %s\n", len(c.Tags), c.Questions, strings.Join(tags, " "))
+		}
+	}
+
+	if flgOut != "" {
+		err = writeFile(flgOut, func(f *os.File) error { return g.WriteCSV(f, flgMin) })
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if flgDot != "" {
+		err = writeFile(flgDot, func(f *os.File) error { return g.WriteDOT(f, flgMin) })
+		if err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+}
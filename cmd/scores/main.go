@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgPost  int
+	flgOut   string
+	flgCheck bool
+	flgN     int
+)
+
+func parseFlags() {
+	flag.IntVar(&flgPost, "post", 0, "show score timeline of a post with this id")
+	flag.StringVar(&flgOut, "out", "", "if given, write .csv file with daily votes of all posts")
+	flag.BoolVar(&flgCheck, "check", false, "compare score from votes with Score in Posts.xml")
+	flag.IntVar(&flgN, "n", 20, "number of mismatched posts to show with -check")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: scores [flags] <directory>\n")
+	fmt.Printf("  builds daily score of posts from Votes.xml in a directory\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func writeCSV(st *stackoverflow.ScoreTimelines, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = st.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func showPost(st *stackoverflow.ScoreTimelines, id int) {
+	t := st.Post(id)
+	if t == nil {
+		fmt.Printf("post %d has no votes\n", id)
+		return
+	}
+	fmt.Printf("%-10s %5s %5s %5s %6s %5s\n", "date", "up", "down", "fav", "score", "favs")
+	for _, d := range t.Days {
+		fmt.Printf("%-10s %5d %5d %5d %6d %5d\n", d.Date.Format("2006-01-02"), d.Up, d.Down, d.Favorites, d.Score, d.TotalFavorites)
+	}
+}
+
+func checkScores(st *stackoverflow.ScoreTimelines, dir string) error {
+	path := stackoverflow.FindXMLFile(dir, "Posts.xml")
+	if path == "" {
+		return fmt.Errorf("no Posts.xml in '%s'", dir)
+	}
+	r, err := stackoverflow.NewPostsReaderFromFile(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	mismatches, err := st.CheckScores(r)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d posts with score different than score from votes\n", len(mismatches))
+	for i, m := range mismatches {
+		if i >= flgN {
+			break
+		}
+		fmt.Printf("  post %d: score %d, from votes %d\n", m.PostID, m.PostScore, m.VotesScore)
+	}
+	return nil
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	dir := u.ExpandTildeInPath(flag.Arg(0))
+	timeStart := time.Now()
+	path := stackoverflow.FindXMLFile(dir, "Votes.xml")
+	if path == "" {
+		fmt.Printf("error: no Votes.xml in '%s'\n", dir)
+		os.Exit(1)
+	}
+	r, err := stackoverflow.NewVotesReaderFromFile(path)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+	st, err := stackoverflow.ReadScoreTimelines(r)
+	r.Close()
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("votes of %d posts in %s\n", len(st.PostIDs()), time.Since(timeStart))
+
+	if flgPost != 0 {
+		showPost(st, flgPost)
+	}
+	if flgOut != "" {
+		if err = writeCSV(st, flgOut); err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if flgCheck {
+		if err = checkScores(st, dir); err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/internal/u"
+	"github.com/kjk/stackoverflow/search"
+)
+
+var (
+	flgDB    string
+	flgBuild bool
+	flgN     int
+	flgSite  string
+)
+
+func parseFlags() {
+	flag.StringVar(&flgDB, "db", "", "path of search index file")
+	flag.BoolVar(&flgBuild, "build", false, "build index from Posts.xml in a directory (or a Posts.xml file)")
+	flag.IntVar(&flgN, "n", 10, "number of results to show")
+	flag.StringVar(&flgSite, "site", "stackoverflow.com", "site for links to questions")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage:\n")
+	fmt.Printf("  search -db so.search -build <directory or Posts.xml>: build search index\n")
+	fmt.Printf("  search -db so.search <query>: search questions\n")
+	fmt.Printf("query syntax: words, \"phrase\", [tag], score:3, score:1..5, created:2020, created:2020-03..2021, is:answered, is:accepted\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func buildIndex(path string) error {
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if st.IsDir() {
+		dir := path
+		if path = stackoverflow.FindXMLFile(dir, "Posts.xml"); path == "" {
+			return fmt.Errorf("no Posts.xml in '%s'", dir)
+		}
+	}
+	timeStart := time.Now()
+	r, err := stackoverflow.NewPostsReaderFromFile(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	// the index is always built from scratch
+	if err = os.Remove(flgDB); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	idx, err := search.Create(flgDB)
+	if err != nil {
+		return err
+	}
+	n, err := idx.AddFromReader(r)
+	if err != nil {
+		idx.Close()
+		return err
+	}
+	if err = idx.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("indexed %d questions in %s\n", n, time.Since(timeStart))
+	return nil
+}
+
+func showResults(query string) error {
+	idx, err := search.Open(flgDB)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	timeStart := time.Now()
+	results, total, err := idx.Search(query, flgN)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		var tags []string
+		for _, tag := range r.Tags {
+			tags = append(tags, "["+tag+"]")
+		}
+		accepted := ""
+		if r.AcceptedAnswerID != 0 {
+			accepted = ", accepted"
+		}
+		fmt.Printf("%d. %s\n", i+1, r.Title)
+		fmt.Printf("   https://%s/q/%d\n", flgSite, r.ID)
+		fmt.Printf("   %s score: %d, answers: %d%s, %s\n", strings.Join(tags, ""), r.Score, r.AnswerCount, accepted, r.CreationDate.Format("2006-01-02"))
+	}
+	fmt.Printf("showing %d of %d results, searched in %s\n", len(results), total, time.Since(timeStart))
+	return nil
+}
+
+func main() {
+	parseFlags()
+	if flgDB == "" || flag.NArg() == 0 {
+		usageAndExit()
+	}
+	flgDB = u.ExpandTildeInPath(flgDB)
+	var err error
+	if flgBuild {
+		if flag.NArg() != 1 {
+			usageAndExit()
+		}
+		err = buildIndex(u.ExpandTildeInPath(flag.Arg(0)))
+	} else {
+		err = showResults(strings.Join(flag.Args(), " "))
+	}
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,206 +1,104 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"path/filepath"
+	"os"
 	"time"
 
 	"github.com/kjk/stackoverflow"
-	"github.com/kjk/u"
+	"github.com/kjk/stackoverflow/internal/u"
 )
 
-func readUsers(dataDir string) []stackoverflow.User {
-	dir := u.ExpandTildeInPath(dataDir)
-	fmt.Printf("readUsers: dir=%s\n", dir)
-	path := filepath.Join(dir, "Users.xml")
-	timeStart := time.Now()
-	ur, err := stackoverflow.NewUsersReaderFromFile(path)
-	if err != nil {
-		fmt.Printf("readUsers: NewUsersReader() failed with %s\n", err)
-		return nil
-	}
-	var res []stackoverflow.User
-	for ur.Next() {
-		res = append(res, ur.User)
-	}
-	if ur.Err() != nil {
-		fmt.Printf("readUsers: Next() failed with '%s'\n", ur.Err())
-	}
-	fmt.Printf("loaded %d users in %s\n", len(res), time.Since(timeStart))
-	return res
+func printCounts(ds *stackoverflow.Dataset) {
+	fmt.Printf("users:        %d\n", len(ds.Users))
+	fmt.Printf("posts:        %d\n", len(ds.Posts))
+	fmt.Printf("comments:     %d\n", len(ds.Comments))
+	fmt.Printf("tags:         %d\n", len(ds.Tags))
+	fmt.Printf("badges:       %d\n", len(ds.Badges))
+	fmt.Printf("post history: %d\n", len(ds.PostHistory))
+	fmt.Printf("post links:   %d\n", len(ds.PostLinks))
+	fmt.Printf("votes:        %d\n", len(ds.Votes))
 }
 
-func readPosts(dataDir string) []stackoverflow.Post {
-	dir := u.ExpandTildeInPath(dataDir)
-	fmt.Printf("readPosts: dir=%s\n", dir)
-	path := filepath.Join(dir, "Posts.xml")
-	timeStart := time.Now()
-	ur, err := stackoverflow.NewPostsReaderFromFile(path)
-	if err != nil {
-		fmt.Printf("readPosts: NewPostsReader() failed with %s\n", err)
-		return nil
-	}
-	var res []stackoverflow.Post
-	for ur.Next() {
-		res = append(res, ur.Post)
-	}
-	if ur.Err() != nil {
-		fmt.Printf("readPosts: Next() failed with '%s'\n", ur.Err())
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
 	}
+	return float64(n) * 100 / float64(total)
+}
+
+func printPostStats(ds *stackoverflow.Dataset) {
 	nQuestions := 0
 	nAnswers := 0
+	nAnswered := 0
+	nAccepted := 0
+	nAcceptedBySelf := 0
 	tags := map[string]int{}
-	for _, p := range res {
-		if p.PostTypeID == stackoverflow.PostQuestion {
-			nQuestions++
-		} else if p.PostTypeID == stackoverflow.PostAnswer {
+	for i := range ds.Posts {
+		p := &ds.Posts[i]
+		if p.PostTypeID == stackoverflow.PostAnswer {
 			nAnswers++
+			continue
+		}
+		if p.PostTypeID != stackoverflow.PostQuestion {
+			continue
 		}
+		nQuestions++
 		for _, tag := range p.Tags {
 			tags[tag]++
 		}
+		if len(ds.Answers(p.ID)) > 0 {
+			nAnswered++
+		}
+		if a := ds.AcceptedAnswer(p); a != nil {
+			nAccepted++
+			if a.OwnerUserID != 0 && a.OwnerUserID == p.OwnerUserID {
+				nAcceptedBySelf++
+			}
+		}
 	}
-	fmt.Printf("loaded %d posts (%d questions, %d answers, %d unique tags) in %s\n", len(res), nQuestions, nAnswers, len(tags), time.Since(timeStart))
-	return res
-}
-
-func readComments(dataDir string) []stackoverflow.Comment {
-	dir := u.ExpandTildeInPath(dataDir)
-	fmt.Printf("readComments: dir=%s\n", dir)
-	path := filepath.Join(dir, "Comments.xml")
-	timeStart := time.Now()
-	ur, err := stackoverflow.NewCommentsReaderFromFile(path)
-	if err != nil {
-		fmt.Printf("readComments: NewCommentsReader() failed with %s\n", err)
-		return nil
-	}
-	var res []stackoverflow.Comment
-	for ur.Next() {
-		res = append(res, ur.Comment)
-	}
-	if ur.Err() != nil {
-		fmt.Printf("readComments: Next() failed with '%s'\n", ur.Err())
-	}
-	fmt.Printf("loaded %d comments in %s\n", len(res), time.Since(timeStart))
-	return res
-}
-
-func readTags(dataDir string) []stackoverflow.Tag {
-	dir := u.ExpandTildeInPath(dataDir)
-	fmt.Printf("readTags: dir=%s\n", dir)
-	path := filepath.Join(dir, "Tags.xml")
-	timeStart := time.Now()
-	ur, err := stackoverflow.NewTagsReaderFromFile(path)
-	if err != nil {
-		fmt.Printf("readTags: NewTagsReader() failed with %s\n", err)
-		return nil
-	}
-	var res []stackoverflow.Tag
-	for ur.Next() {
-		res = append(res, ur.Tag)
-	}
-	if ur.Err() != nil {
-		fmt.Printf("readTags: Next() failed with '%s'\n", ur.Err())
-	}
-	fmt.Printf("loaded %d tags in %s\n", len(res), time.Since(timeStart))
-	return res
+	fmt.Printf("%d questions, %d answers, %d unique tags\n", nQuestions, nAnswers, len(tags))
+	fmt.Printf("answered: %.1f%%, with accepted answer: %.1f%%, accepted own answer: %d\n", percent(nAnswered, nQuestions), percent(nAccepted, nQuestions), nAcceptedBySelf)
 }
 
-func readBadges(dataDir string) []stackoverflow.Badge {
-	dir := u.ExpandTildeInPath(dataDir)
-	fmt.Printf("readBadges: dir=%s\n", dir)
-	path := filepath.Join(dir, "Badges.xml")
-	timeStart := time.Now()
-	ur, err := stackoverflow.NewBadgesReaderFromFile(path)
-	if err != nil {
-		fmt.Printf("readBadges: NewBadgesReader() failed with %s\n", err)
-		return nil
-	}
-	var res []stackoverflow.Badge
-	for ur.Next() {
-		res = append(res, ur.Badge)
+func printTopUser(ds *stackoverflow.Dataset) {
+	var top *stackoverflow.User
+	for i := range ds.Users {
+		if top == nil || ds.Users[i].Reputation > top.Reputation {
+			top = &ds.Users[i]
+		}
 	}
-	if ur.Err() != nil {
-		fmt.Printf("readBadges: Next() failed with '%s'\n", ur.Err())
+	if top == nil {
+		return
 	}
-	fmt.Printf("loaded %d badges in %s\n", len(res), time.Since(timeStart))
-	return res
+	fmt.Printf("top user: %s (reputation %d), %d posts, %d badges\n", top.DisplayName, top.Reputation, len(ds.UserPosts(top.ID)), len(ds.UserBadges(top.ID)))
 }
 
-func readPostHistory(dataDir string) []stackoverflow.PostHistory {
-	dir := u.ExpandTildeInPath(dataDir)
-	fmt.Printf("readPostHistory: dir=%s\n", dir)
-	path := filepath.Join(dir, "PostHistory.xml")
-	timeStart := time.Now()
-	ur, err := stackoverflow.NewPostHistoryReaderFromFile(path)
-	if err != nil {
-		fmt.Printf("readPostHistory: NewPostHistoryReader() failed with %s\n", err)
-		return nil
-	}
-	var res []stackoverflow.PostHistory
-	for ur.Next() {
-		res = append(res, ur.PostHistory)
-	}
-	if ur.Err() != nil {
-		fmt.Printf("readPostHistory: Next() failed with '%s'\n", ur.Err())
+func main() {
+	//dataDir := "~/data/academia.stackexchange.com"
+	dataDir := "~/data/serverfault.com"
+	//dataDir := "~/data/stackoverflow"
+	noCache := flag.Bool("no-cache", false, "parse .xml files instead of using binary cache")
+	flag.Parse()
+	if flag.NArg() > 0 {
+		dataDir = flag.Arg(0)
 	}
-	fmt.Printf("loaded %d post history entries in %s\n", len(res), time.Since(timeStart))
-	return res
-}
 
-func readPostLinks(dataDir string) []stackoverflow.PostLink {
 	dir := u.ExpandTildeInPath(dataDir)
-	fmt.Printf("readPostLinks: dir=%s\n", dir)
-	path := filepath.Join(dir, "PostLinks.xml")
+	fmt.Printf("loading dataset from %s\n", dir)
 	timeStart := time.Now()
-	ur, err := stackoverflow.NewPostLinksReaderFromFile(path)
-	if err != nil {
-		fmt.Printf("readPostLinks: NewPostHistoryReader() failed with %s\n", err)
-		return nil
+	load := stackoverflow.LoadDatasetCached
+	if *noCache {
+		load = stackoverflow.LoadDataset
 	}
-	var res []stackoverflow.PostLink
-	for ur.Next() {
-		res = append(res, ur.PostLink)
-	}
-	if ur.Err() != nil {
-		fmt.Printf("readPostLinks: Next() failed with '%s'\n", ur.Err())
-	}
-	fmt.Printf("loaded %d post links in %s\n", len(res), time.Since(timeStart))
-	return res
-}
-
-func readVotes(dataDir string) []stackoverflow.Vote {
-	dir := u.ExpandTildeInPath(dataDir)
-	fmt.Printf("readVotes: dir=%s\n", dir)
-	path := filepath.Join(dir, "Votes.xml")
-	timeStart := time.Now()
-	ur, err := stackoverflow.NewVotesReaderFromFile(path)
+	ds, err := load(dir, stackoverflow.AllTables)
 	if err != nil {
-		fmt.Printf("readVotes: NewPostHistoryReader() failed with %s\n", err)
-		return nil
-	}
-	var res []stackoverflow.Vote
-	for ur.Next() {
-		res = append(res, ur.Vote)
-	}
-	if ur.Err() != nil {
-		fmt.Printf("readVotes: Next() failed with '%s'\n", ur.Err())
+		fmt.Printf("LoadDataset() failed with %s\n", err)
+		os.Exit(1)
 	}
-	fmt.Printf("loaded %d post links in %s\n", len(res), time.Since(timeStart))
-	return res
-}
-
-func main() {
-	//dataDir := "~/data/academia.stackexchange.com"
-	dataDir := "~/data/serverfault.com"
-	//dataDir := "~/data/stackoverflow"
-
-	//readUsers(dataDir)
-	//readPosts(dataDir)
-	//readComments(dataDir)
-	//readTags(dataDir)
-	//readBadges(dataDir)
-	//readPostHistory(dataDir)
-	//readPostLinks(dataDir)
-	readVotes(dataDir)
+	fmt.Printf("loaded dataset in %s\n", time.Since(timeStart))
+	printCounts(ds)
+	printPostStats(ds)
+	printTopUser(ds)
 }
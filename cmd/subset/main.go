@@ -0,0 +1,549 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgDir    string
+	flgOut    string
+	flgTags   string
+	flgIDs    string
+	flgSince  string
+	flgUntil  string
+	flgSample float64
+	flgSeed   int
+)
+
+func parseFlags() {
+	flag.StringVar(&flgDir, "dir", "", "directory with .xml files of the data dump")
+	flag.StringVar(&flgOut, "out", "", "directory where subset .xml files will be written")
+	flag.StringVar(&flgTags, "tags", "", "comma-separated list of tags, selects questions that have any of them")
+	flag.StringVar(&flgIDs, "ids", "", "comma-separated list of question ids")
+	flag.StringVar(&flgSince, "since", "", "select questions created on or after this date (YYYY-MM-DD)")
+	flag.StringVar(&flgUntil, "until", "", "select questions created before this date (YYYY-MM-DD)")
+	flag.Float64Var(&flgSample, "sample", 1, "fraction of matching questions to select, between 0 and 1")
+	flag.IntVar(&flgSeed, "seed", 0, "seed for -sample, different seeds select different questions")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: subset -dir <dump dir> -out <out dir> [-tags go,channels] [-ids 1,2] [-since 2020-01-01] [-until 2021-01-01] [-sample 0.1]\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+// selection describes which questions we want in the subset
+type selection struct {
+	tags   map[string]bool
+	ids    map[int]bool
+	since  time.Time
+	until  time.Time
+	sample float64
+	seed   int
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+func newSelectionFromFlags() (*selection, error) {
+	var err error
+	sel := &selection{
+		sample: flgSample,
+		seed:   flgSeed,
+	}
+	if flgSample <= 0 || flgSample > 1 {
+		return nil, fmt.Errorf("-sample must be > 0 and <= 1, is %v", flgSample)
+	}
+	if flgTags != "" {
+		sel.tags = map[string]bool{}
+		for _, tag := range strings.Split(flgTags, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag != "" {
+				sel.tags[tag] = true
+			}
+		}
+	}
+	if flgIDs != "" {
+		sel.ids = map[int]bool{}
+		for _, s := range strings.Split(flgIDs, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("invalid question id '%s' in -ids", s)
+			}
+			sel.ids[id] = true
+		}
+	}
+	if sel.since, err = parseDate(flgSince); err != nil {
+		return nil, fmt.Errorf("invalid -since date '%s'", flgSince)
+	}
+	if sel.until, err = parseDate(flgUntil); err != nil {
+		return nil, fmt.Errorf("invalid -until date '%s'", flgUntil)
+	}
+	return sel, nil
+}
+
+// sampled deterministically decides if question with a given id is in the sample
+func (s *selection) sampled(id int) bool {
+	if s.sample >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d-%d", s.seed, id)
+	return float64(h.Sum64()%1000000) < s.sample*1000000
+}
+
+func (s *selection) matchTags(tags []string) bool {
+	if s.tags == nil {
+		return true
+	}
+	for _, tag := range tags {
+		if s.tags[strings.ToLower(tag)] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *selection) matchQuestion(p *stackoverflow.Post) bool {
+	if s.ids != nil && !s.ids[p.ID] {
+		return false
+	}
+	if !s.since.IsZero() && p.CreationDate.Before(s.since) {
+		return false
+	}
+	if !s.until.IsZero() && !p.CreationDate.Before(s.until) {
+		return false
+	}
+	return s.matchTags(p.Tags) && s.sampled(p.ID)
+}
+
+// subset accumulates selected records. It's meant for extracting a small
+// part of the dump so we keep selected records in memory
+type subset struct {
+	dir string
+	sel *selection
+
+	questionIDs map[int]bool
+	usedTags    map[string]bool
+	wikiPostIDs map[int]bool
+	postIDs     map[int]bool
+	userIDs     map[int]bool
+	// users that exist in Users.xml
+	foundUserIDs map[int]bool
+
+	posts       []stackoverflow.Post
+	tags        []stackoverflow.Tag
+	comments    []stackoverflow.Comment
+	votes       []stackoverflow.Vote
+	postHistory []stackoverflow.PostHistory
+	postLinks   []stackoverflow.PostLink
+	users       []stackoverflow.User
+	badges      []stackoverflow.Badge
+}
+
+func newSubset(dir string, sel *selection) *subset {
+	return &subset{
+		dir:          dir,
+		sel:          sel,
+		questionIDs:  map[int]bool{},
+		usedTags:     map[string]bool{},
+		wikiPostIDs:  map[int]bool{},
+		postIDs:      map[int]bool{},
+		userIDs:      map[int]bool{},
+		foundUserIDs: map[int]bool{},
+	}
+}
+
+func (s *subset) addUser(id int) {
+	if id != 0 {
+		s.userIDs[id] = true
+	}
+}
+
+// openReader returns nil reader if the file doesn't exist in the dump
+func (s *subset) openReader(name string, newReader func(string) (*stackoverflow.Reader, error)) (*stackoverflow.Reader, error) {
+	path := filepath.Join(s.dir, name)
+	if !u.PathExists(path) {
+		fmt.Printf("skipping %s because it doesn't exist\n", path)
+		return nil, nil
+	}
+	return newReader(path)
+}
+
+// selectQuestions is the first pass over Posts.xml which decides
+// which questions are in the subset
+func (s *subset) selectQuestions() error {
+	r, err := s.openReader("Posts.xml", stackoverflow.NewPostsReaderFromFile)
+	if err != nil || r == nil {
+		return err
+	}
+	for r.Next() {
+		p := &r.Post
+		if p.PostTypeID != stackoverflow.PostQuestion || !s.sel.matchQuestion(p) {
+			continue
+		}
+		s.questionIDs[p.ID] = true
+		for _, tag := range p.Tags {
+			s.usedTags[tag] = true
+		}
+	}
+	return r.Err()
+}
+
+func (s *subset) selectTags() error {
+	r, err := s.openReader("Tags.xml", stackoverflow.NewTagsReaderFromFile)
+	if err != nil || r == nil {
+		return err
+	}
+	for r.Next() {
+		t := r.Tag
+		if !s.usedTags[t.TagName] {
+			continue
+		}
+		if t.ExcerptPostID != 0 {
+			s.wikiPostIDs[t.ExcerptPostID] = true
+		}
+		if t.WikiPostID != 0 {
+			s.wikiPostIDs[t.WikiPostID] = true
+		}
+		s.tags = append(s.tags, t)
+	}
+	return r.Err()
+}
+
+// selectPosts is the second pass over Posts.xml which collects selected
+// questions, their answers and wiki posts of their tags
+func (s *subset) selectPosts() error {
+	r, err := s.openReader("Posts.xml", stackoverflow.NewPostsReaderFromFile)
+	if err != nil || r == nil {
+		return err
+	}
+	for r.Next() {
+		p := r.Post
+		isAnswer := p.PostTypeID == stackoverflow.PostAnswer
+		if !s.questionIDs[p.ID] && !s.wikiPostIDs[p.ID] && !(isAnswer && s.questionIDs[p.ParentID]) {
+			continue
+		}
+		s.postIDs[p.ID] = true
+		s.addUser(p.OwnerUserID)
+		s.addUser(p.LastEditorUserID)
+		s.posts = append(s.posts, p)
+	}
+	return r.Err()
+}
+
+func (s *subset) selectComments() error {
+	r, err := s.openReader("Comments.xml", stackoverflow.NewCommentsReaderFromFile)
+	if err != nil || r == nil {
+		return err
+	}
+	for r.Next() {
+		if s.postIDs[r.Comment.PostID] {
+			s.addUser(r.Comment.UserID)
+			s.comments = append(s.comments, r.Comment)
+		}
+	}
+	return r.Err()
+}
+
+func (s *subset) selectVotes() error {
+	r, err := s.openReader("Votes.xml", stackoverflow.NewVotesReaderFromFile)
+	if err != nil || r == nil {
+		return err
+	}
+	for r.Next() {
+		if s.postIDs[r.Vote.PostID] {
+			s.addUser(r.Vote.UserID)
+			s.votes = append(s.votes, r.Vote)
+		}
+	}
+	return r.Err()
+}
+
+func (s *subset) selectPostHistory() error {
+	r, err := s.openReader("PostHistory.xml", stackoverflow.NewPostHistoryReaderFromFile)
+	if err != nil || r == nil {
+		return err
+	}
+	for r.Next() {
+		if s.postIDs[r.PostHistory.PostID] {
+			s.addUser(r.PostHistory.UserID)
+			s.postHistory = append(s.postHistory, r.PostHistory)
+		}
+	}
+	return r.Err()
+}
+
+func (s *subset) selectPostLinks() error {
+	r, err := s.openReader("PostLinks.xml", stackoverflow.NewPostLinksReaderFromFile)
+	if err != nil || r == nil {
+		return err
+	}
+	for r.Next() {
+		l := &r.PostLink
+		// only links where both ends are in the subset, to not leave dangling references
+		if s.postIDs[l.PostID] && s.postIDs[l.RelatedPostID] {
+			s.postLinks = append(s.postLinks, *l)
+		}
+	}
+	return r.Err()
+}
+
+func (s *subset) selectUsers() error {
+	r, err := s.openReader("Users.xml", stackoverflow.NewUsersReaderFromFile)
+	if err != nil || r == nil {
+		return err
+	}
+	for r.Next() {
+		if s.userIDs[r.User.ID] {
+			s.foundUserIDs[r.User.ID] = true
+			s.users = append(s.users, r.User)
+		}
+	}
+	return r.Err()
+}
+
+func (s *subset) selectBadges() error {
+	r, err := s.openReader("Badges.xml", stackoverflow.NewBadgesReaderFromFile)
+	if err != nil || r == nil {
+		return err
+	}
+	for r.Next() {
+		if s.foundUserIDs[r.Badge.UserID] {
+			s.badges = append(s.badges, r.Badge)
+		}
+	}
+	return r.Err()
+}
+
+// userID returns 0 for users that are not in Users.xml (e.g. deleted users)
+// so that there are no dangling references in the subset
+func (s *subset) userID(id int) int {
+	if s.foundUserIDs[id] {
+		return id
+	}
+	return 0
+}
+
+// fixReferences removes references to records that are not in the subset
+// and re-calculates tag counts
+func (s *subset) fixReferences() {
+	tagCounts := map[string]int{}
+	for i := range s.posts {
+		p := &s.posts[i]
+		if !s.postIDs[p.AcceptedAnswerID] {
+			p.AcceptedAnswerID = 0
+		}
+		p.OwnerUserID = s.userID(p.OwnerUserID)
+		p.LastEditorUserID = s.userID(p.LastEditorUserID)
+		if p.PostTypeID == stackoverflow.PostQuestion {
+			for _, tag := range p.Tags {
+				tagCounts[tag]++
+			}
+		}
+	}
+	for i := range s.tags {
+		t := &s.tags[i]
+		t.Count = tagCounts[t.TagName]
+		if !s.postIDs[t.ExcerptPostID] {
+			t.ExcerptPostID = 0
+		}
+		if !s.postIDs[t.WikiPostID] {
+			t.WikiPostID = 0
+		}
+	}
+	for i := range s.comments {
+		s.comments[i].UserID = s.userID(s.comments[i].UserID)
+	}
+	for i := range s.votes {
+		s.votes[i].UserID = s.userID(s.votes[i].UserID)
+	}
+	for i := range s.postHistory {
+		s.postHistory[i].UserID = s.userID(s.postHistory[i].UserID)
+	}
+}
+
+func (s *subset) read() error {
+	timeStart := time.Now()
+	if err := s.selectQuestions(); err != nil {
+		return fmt.Errorf("selectQuestions() failed with %s", err)
+	}
+	fmt.Printf("selected %d questions in %s\n", len(s.questionIDs), time.Since(timeStart))
+	if err := s.selectTags(); err != nil {
+		return fmt.Errorf("selectTags() failed with %s", err)
+	}
+	if err := s.selectPosts(); err != nil {
+		return fmt.Errorf("selectPosts() failed with %s", err)
+	}
+	fmt.Printf("selected %d posts and %d tags in %s\n", len(s.posts), len(s.tags), time.Since(timeStart))
+	if err := s.selectComments(); err != nil {
+		return fmt.Errorf("selectComments() failed with %s", err)
+	}
+	if err := s.selectVotes(); err != nil {
+		return fmt.Errorf("selectVotes() failed with %s", err)
+	}
+	if err := s.selectPostHistory(); err != nil {
+		return fmt.Errorf("selectPostHistory() failed with %s", err)
+	}
+	if err := s.selectPostLinks(); err != nil {
+		return fmt.Errorf("selectPostLinks() failed with %s", err)
+	}
+	if err := s.selectUsers(); err != nil {
+		return fmt.Errorf("selectUsers() failed with %s", err)
+	}
+	if err := s.selectBadges(); err != nil {
+		return fmt.Errorf("selectBadges() failed with %s", err)
+	}
+	s.fixReferences()
+	fmt.Printf("selected %d comments, %d votes, %d post history entries, %d post links, %d users, %d badges in %s\n", len(s.comments), len(s.votes), len(s.postHistory), len(s.postLinks), len(s.users), len(s.badges), time.Since(timeStart))
+	return nil
+}
+
+func (s *subset) writePosts(dir string) error {
+	w, err := stackoverflow.NewPostsWriterToFile(filepath.Join(dir, "Posts.xml"))
+	if err != nil {
+		return err
+	}
+	for i := range s.posts {
+		w.WritePost(&s.posts[i])
+	}
+	return w.Close()
+}
+
+func (s *subset) writeTags(dir string) error {
+	w, err := stackoverflow.NewTagsWriterToFile(filepath.Join(dir, "Tags.xml"))
+	if err != nil {
+		return err
+	}
+	for i := range s.tags {
+		w.WriteTag(&s.tags[i])
+	}
+	return w.Close()
+}
+
+func (s *subset) writeComments(dir string) error {
+	w, err := stackoverflow.NewCommentsWriterToFile(filepath.Join(dir, "Comments.xml"))
+	if err != nil {
+		return err
+	}
+	for i := range s.comments {
+		w.WriteComment(&s.comments[i])
+	}
+	return w.Close()
+}
+
+func (s *subset) writeVotes(dir string) error {
+	w, err := stackoverflow.NewVotesWriterToFile(filepath.Join(dir, "Votes.xml"))
+	if err != nil {
+		return err
+	}
+	for i := range s.votes {
+		w.WriteVote(&s.votes[i])
+	}
+	return w.Close()
+}
+
+func (s *subset) writePostHistory(dir string) error {
+	w, err := stackoverflow.NewPostHistoryWriterToFile(filepath.Join(dir, "PostHistory.xml"))
+	if err != nil {
+		return err
+	}
+	for i := range s.postHistory {
+		w.WritePostHistory(&s.postHistory[i])
+	}
+	return w.Close()
+}
+
+func (s *subset) writePostLinks(dir string) error {
+	w, err := stackoverflow.NewPostLinksWriterToFile(filepath.Join(dir, "PostLinks.xml"))
+	if err != nil {
+		return err
+	}
+	for i := range s.postLinks {
+		w.WritePostLink(&s.postLinks[i])
+	}
+	return w.Close()
+}
+
+func (s *subset) writeUsers(dir string) error {
+	w, err := stackoverflow.NewUsersWriterToFile(filepath.Join(dir, "Users.xml"))
+	if err != nil {
+		return err
+	}
+	for i := range s.users {
+		w.WriteUser(&s.users[i])
+	}
+	return w.Close()
+}
+
+func (s *subset) writeBadges(dir string) error {
+	w, err := stackoverflow.NewBadgesWriterToFile(filepath.Join(dir, "Badges.xml"))
+	if err != nil {
+		return err
+	}
+	for i := range s.badges {
+		w.WriteBadge(&s.badges[i])
+	}
+	return w.Close()
+}
+
+func (s *subset) write(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	writers := []func(string) error{
+		s.writePosts,
+		s.writeTags,
+		s.writeComments,
+		s.writeVotes,
+		s.writePostHistory,
+		s.writePostLinks,
+		s.writeUsers,
+		s.writeBadges,
+	}
+	for _, write := range writers {
+		if err = write(dir); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("wrote subset to %s\n", dir)
+	return nil
+}
+
+func main() {
+	parseFlags()
+	if flgDir == "" || flgOut == "" {
+		usageAndExit()
+	}
+	sel, err := newSelectionFromFlags()
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		usageAndExit()
+	}
+	dir := u.ExpandTildeInPath(flgDir)
+	s := newSubset(dir, sel)
+	err = s.read()
+	if err == nil {
+		err = s.write(u.ExpandTildeInPath(flgOut))
+	}
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/arrowexport"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+const (
+	formatFile   = "file"
+	formatStream = "stream"
+)
+
+var (
+	flgOut         string
+	flgFormat      string
+	flgCompression string
+	flgBatch       int
+)
+
+func parseFlags() {
+	flag.StringVar(&flgOut, "out", "", "directory for output files. Default is directory of .xml files")
+	flag.StringVar(&flgFormat, "format", formatFile, "'file' writes Arrow IPC file format (Feather v2) as <table>.arrow, 'stream' writes IPC stream format as <table>.arrows")
+	flag.StringVar(&flgCompression, "compression", "none", "compression of record batches: 'none', 'zstd' or 'lz4'")
+	flag.IntVar(&flgBatch, "batch", arrowexport.DefaultBatchSize, "number of rows in a record batch")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: toarrow [flags] <directory or file.xml>\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+// table describes .xml file that can be converted
+type table struct {
+	// base name of .xml file e.g. "users"
+	name      string
+	newReader func(string) (*stackoverflow.Reader, error)
+}
+
+var tables = []*table{
+	{"badges", stackoverflow.NewBadgesReaderFromFile},
+	{"comments", stackoverflow.NewCommentsReaderFromFile},
+	{"posthistory", stackoverflow.NewPostHistoryReaderFromFile},
+	{"postlinks", stackoverflow.NewPostLinksReaderFromFile},
+	{"posts", stackoverflow.NewPostsReaderFromFile},
+	{"tags", stackoverflow.NewTagsReaderFromFile},
+	{"users", stackoverflow.NewUsersReaderFromFile},
+	{"votes", stackoverflow.NewVotesReaderFromFile},
+}
+
+func findTable(name string) *table {
+	for _, t := range tables {
+		if t.name+".xml" == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// recordWriter is implemented by ipc.Writer and ipc.FileWriter
+type recordWriter interface {
+	Write(rec array.Record) error
+	Close() error
+}
+
+func toArrow(path string, t *table, out string, opts []ipc.Option) (err error) {
+	timeStart := time.Now()
+	n := 0
+	r, err := t.newReader(path)
+	if err != nil {
+		return fmt.Errorf("toArrow: newReader() failed with %s\n", err)
+	}
+	defer r.Close()
+
+	mem := memory.NewGoAllocator()
+	rr, err := arrowexport.NewRecordReader(r, flgBatch, mem)
+	if err != nil {
+		return err
+	}
+	defer rr.Release()
+
+	if out == "" {
+		out = filepath.Dir(path)
+	}
+	ext := ".arrow"
+	if flgFormat == formatStream {
+		ext = ".arrows"
+	}
+	outPath := filepath.Join(out, t.name+ext)
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err2 := f.Close()
+		if err == nil {
+			err = err2
+		}
+	}()
+	opts = append(opts, ipc.WithSchema(rr.Schema()), ipc.WithAllocator(mem))
+	var w recordWriter
+	if flgFormat == formatStream {
+		w = ipc.NewWriter(f, opts...)
+	} else {
+		w, err = ipc.NewFileWriter(f, opts...)
+		if err != nil {
+			return err
+		}
+	}
+	for rr.Next() {
+		rec := rr.Record()
+		if err = w.Write(rec); err != nil {
+			w.Close()
+			return err
+		}
+		n += int(rec.NumRows())
+	}
+	if rr.Err() != nil {
+		w.Close()
+		return rr.Err()
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("converted %d %s to %s in %s\n", n, t.name, outPath, time.Since(timeStart))
+	return nil
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	if flgFormat != formatFile && flgFormat != formatStream {
+		fmt.Printf("invalid -format '%s'\n", flgFormat)
+		usageAndExit()
+	}
+	var opts []ipc.Option
+	switch flgCompression {
+	case "none":
+		// no option
+	case "zstd":
+		opts = append(opts, ipc.WithZstd())
+	case "lz4":
+		opts = append(opts, ipc.WithLZ4())
+	default:
+		fmt.Printf("invalid -compression '%s'\n", flgCompression)
+		usageAndExit()
+	}
+	out := ""
+	if flgOut != "" {
+		out = u.ExpandTildeInPath(flgOut)
+		if err := os.MkdirAll(out, 0755); err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	path := u.ExpandTildeInPath(flag.Arg(0))
+	st, err := os.Stat(path)
+	if err != nil {
+		fmt.Printf("'%s' doesn't exist\n", path)
+		usageAndExit()
+	}
+	if !st.IsDir() {
+		t := findTable(strings.ToLower(filepath.Base(path)))
+		if t == nil {
+			fmt.Printf("'%s' is not a recognized file name\n", path)
+			usageAndExit()
+		}
+		if err = toArrow(path, t, out, opts); err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	for _, t := range tables {
+		xmlPath := stackoverflow.FindXMLFile(path, t.name+".xml")
+		if xmlPath == "" {
+			fmt.Printf("skipping %s, no .xml file in '%s'\n", t.name, path)
+			continue
+		}
+		if err = toArrow(xmlPath, t, out, opts); err != nil {
+			fmt.Printf("error: converting '%s' failed with %s\n", xmlPath, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// quoting styles of csvWriter
+const (
+	// quote only fields that need it, like encoding/csv
+	quoteMinimal = iota
+	// quote all fields
+	quoteAll
+	// never quote, escape delimiter, newlines and backslash with backslash
+	// instead. That's how PostgreSQL and ClickHouse read TSV files
+	quoteNone
+)
+
+// csvWriter writes csv or tsv records. Unlike encoding/csv it supports
+// different quoting styles
+type csvWriter struct {
+	w     *bufio.Writer
+	comma byte
+	quote int
+}
+
+func newCsvWriter(w io.Writer, comma byte, quote int) *csvWriter {
+	return &csvWriter{
+		w:     bufio.NewWriter(w),
+		comma: comma,
+		quote: quote,
+	}
+}
+
+func (w *csvWriter) fieldNeedsQuotes(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s[0] == ' ' || s[0] == '\t' {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == w.comma || c == '"' || c == '\n' || c == '\r' {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *csvWriter) writeEscaped(s string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\':
+			w.w.WriteString(`\\`)
+		case '\n':
+			w.w.WriteString(`\n`)
+		case '\r':
+			w.w.WriteString(`\r`)
+		case '\t':
+			w.w.WriteString(`\t`)
+		default:
+			if c == w.comma {
+				w.w.WriteByte('\\')
+			}
+			w.w.WriteByte(c)
+		}
+	}
+}
+
+func (w *csvWriter) writeQuoted(s string) {
+	w.w.WriteByte('"')
+	w.w.WriteString(strings.Replace(s, `"`, `""`, -1))
+	w.w.WriteByte('"')
+}
+
+// Write writes a single record
+func (w *csvWriter) Write(rec []string) error {
+	for i, s := range rec {
+		if i > 0 {
+			w.w.WriteByte(w.comma)
+		}
+		switch {
+		case w.quote == quoteNone:
+			w.writeEscaped(s)
+		case w.quote == quoteAll || w.fieldNeedsQuotes(s):
+			w.writeQuoted(s)
+		default:
+			w.w.WriteString(s)
+		}
+	}
+	return w.w.WriteByte('\n')
+}
+
+// Flush writes buffered data to underlying writer
+func (w *csvWriter) Flush() error {
+	return w.w.Flush()
+}
@@ -1,8 +1,9 @@
 package main
 
 import (
-	"encoding/csv"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -10,57 +11,240 @@ import (
 	"time"
 
 	"github.com/kjk/stackoverflow"
-	"github.com/kjk/u"
+	"github.com/kjk/stackoverflow/blobstore"
+	"github.com/kjk/stackoverflow/internal/u"
 )
 
+const (
+	timeFormatDump    = "dump"
+	timeFormatRFC3339 = "rfc3339"
+	timeFormatUnix    = "unix"
+
+	textSidecar = "sidecar"
+	textInline  = "inline"
+)
+
+var (
+	flgOut        string
+	flgDelimiter  string
+	flgQuote      string
+	flgHeader     bool
+	flgColumns    string
+	flgTimeFormat string
+	flgText       string
+	flgCompress   bool
+)
+
+func parseFlags() {
+	flag.StringVar(&flgOut, "out", "", "path of .csv file, '-' for stdout. Default is <table>.csv next to .xml file")
+	flag.StringVar(&flgDelimiter, "delim", ",", "field delimiter, 'tab' for tsv")
+	flag.StringVar(&flgQuote, "quote", "minimal", "quoting style: 'minimal', 'all' or 'none' (escape with backslash)")
+	flag.BoolVar(&flgHeader, "header", true, "write header row")
+	flag.StringVar(&flgColumns, "columns", "", "comma-separated list of columns to write, in order. Default is all columns")
+	flag.StringVar(&flgTimeFormat, "time", timeFormatDump, "time format: 'dump', 'rfc3339' or 'unix'")
+	flag.StringVar(&flgText, "text", textSidecar, "large text fields (Body, Text, AboutMe): 'sidecar' writes them to .blob file (see blobstore package) and reference to .csv, 'inline' writes them to .csv")
+	flag.BoolVar(&flgCompress, "compress", false, "compress .blob file")
+	flag.Parse()
+}
+
 func usageAndExit() {
-	fmt.Printf("usage: tocvs file.xml\n")
+	fmt.Printf("usage: tocsv [flags] file.xml\n")
+	flag.PrintDefaults()
 	os.Exit(1)
 }
 
-type textWriter struct {
-	f   *os.File
-	pos int
+// options tells how to format .csv file
+type options struct {
+	out       string
+	comma     byte
+	quote     int
+	header    bool
+	columns   []string
+	inlineTxt bool
+	compress  bool
+}
+
+func parseOptions() (*options, error) {
+	opts := &options{
+		out:       flgOut,
+		header:    flgHeader,
+		inlineTxt: flgText == textInline,
+		compress:  flgCompress,
+	}
+	switch flgDelimiter {
+	case "tab", "\\t", "\t":
+		opts.comma = '\t'
+	default:
+		if len(flgDelimiter) != 1 || flgDelimiter[0] == '"' || flgDelimiter[0] == '\n' {
+			return nil, fmt.Errorf("invalid -delim '%s'", flgDelimiter)
+		}
+		opts.comma = flgDelimiter[0]
+	}
+	switch flgQuote {
+	case "minimal":
+		opts.quote = quoteMinimal
+	case "all":
+		opts.quote = quoteAll
+	case "none":
+		opts.quote = quoteNone
+	default:
+		return nil, fmt.Errorf("invalid -quote '%s'", flgQuote)
+	}
+	switch flgTimeFormat {
+	case timeFormatDump, timeFormatRFC3339, timeFormatUnix:
+		// formatTime() uses flgTimeFormat directly
+	default:
+		return nil, fmt.Errorf("invalid -time '%s'", flgTimeFormat)
+	}
+	if flgText != textSidecar && flgText != textInline {
+		return nil, fmt.Errorf("invalid -text '%s'", flgText)
+	}
+	if flgColumns != "" {
+		for _, col := range strings.Split(flgColumns, ",") {
+			opts.columns = append(opts.columns, strings.TrimSpace(col))
+		}
+	}
+	return opts, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	switch flgTimeFormat {
+	case timeFormatRFC3339:
+		return t.UTC().Format(time.RFC3339Nano)
+	case timeFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	return t.Format(stackoverflow.TimeFormat)
 }
 
-func newTextWriter(path string) (*textWriter, error) {
-	f, err := os.Create(path)
+// writeText writes s to blob store and returns reference to it.
+// If w is nil, text is written inline in .csv file
+func writeText(w *blobstore.Writer, s string) (string, error) {
+	if w == nil {
+		return s, nil
+	}
+	ref, err := w.Add(s)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return &textWriter{
-		f: f,
-	}, nil
+	return ref.String(), nil
 }
 
-func (w *textWriter) Write(s string) (int, int, error) {
-	_, err := w.f.WriteString(s + "\n\n")
+var badgeCsvHeader = []string{"Id", "UserId", "Name", "Date"}
+
+func badgeToCsvRecord(b *stackoverflow.Badge, w *blobstore.Writer, rec []string) error {
+	rec[0] = strconv.Itoa(b.ID)
+	rec[1] = strconv.Itoa(b.UserID)
+	rec[2] = b.Name
+	rec[3] = formatTime(b.Date)
+	return nil
+}
+
+var commentCsvHeader = []string{"Id", "PostId", "Score", "Text", "CreationDate", "UserId", "UserDisplayName"}
+
+func commentToCsvRecord(c *stackoverflow.Comment, w *blobstore.Writer, rec []string) error {
+	text, err := writeText(w, c.Text)
 	if err != nil {
-		return 0, 0, err
+		return err
 	}
-	pos := w.pos
-	w.pos += len(s) + 2
-	return pos, len(s), nil
+	rec[0] = strconv.Itoa(c.ID)
+	rec[1] = strconv.Itoa(c.PostID)
+	rec[2] = strconv.Itoa(c.Score)
+	rec[3] = text
+	rec[4] = formatTime(c.CreationDate)
+	rec[5] = strconv.Itoa(c.UserID)
+	rec[6] = c.UserDisplayName
+	return nil
 }
 
-func (w *textWriter) Close() {
-	if w.f != nil {
-		w.f.Close()
-		w.f = nil
+var postHistoryCsvHeader = []string{"Id", "PostHistoryTypeId", "PostId", "RevisionGUID", "CreationDate", "UserId", "UserDisplayName", "Comment", "Text"}
+
+func postHistoryToCsvRecord(h *stackoverflow.PostHistory, w *blobstore.Writer, rec []string) error {
+	text, err := writeText(w, h.Text)
+	if err != nil {
+		return err
 	}
+	rec[0] = strconv.Itoa(h.ID)
+	rec[1] = strconv.Itoa(h.PostHistoryTypeID)
+	rec[2] = strconv.Itoa(h.PostID)
+	rec[3] = h.RevisionGUID
+	rec[4] = formatTime(h.CreationDate)
+	rec[5] = strconv.Itoa(h.UserID)
+	rec[6] = h.UserDisplayName
+	rec[7] = h.Comment
+	rec[8] = text
+	return nil
 }
 
-func userToCsvRecord(u *stackoverflow.User, w *textWriter, rec []string) error {
-	aboutPos, aboutLen, err := w.Write(u.AboutMe)
+var postLinkCsvHeader = []string{"Id", "CreationDate", "PostId", "RelatedPostId", "LinkTypeId"}
+
+func postLinkToCsvRecord(l *stackoverflow.PostLink, w *blobstore.Writer, rec []string) error {
+	rec[0] = strconv.Itoa(l.ID)
+	rec[1] = formatTime(l.CreationDate)
+	rec[2] = strconv.Itoa(l.PostID)
+	rec[3] = strconv.Itoa(l.RelatedPostID)
+	rec[4] = strconv.Itoa(l.LinkTypeID)
+	return nil
+}
+
+var postCsvHeader = []string{"Id", "PostTypeId", "ParentId", "AcceptedAnswerId", "CreationDate", "Score", "ViewCount", "Body", "OwnerUserId", "OwnerDisplayName", "LastEditorUserId", "LastEditorDisplayName", "LastEditDate", "LastActivityDate", "Title", "Tags", "AnswerCount", "CommentCount", "FavoriteCount", "CommunityOwnedDate", "ClosedDate"}
+
+func postToCsvRecord(p *stackoverflow.Post, w *blobstore.Writer, rec []string) error {
+	body, err := writeText(w, p.Body)
+	if err != nil {
+		return err
+	}
+	rec[0] = strconv.Itoa(p.ID)
+	rec[1] = strconv.Itoa(p.PostTypeID)
+	rec[2] = strconv.Itoa(p.ParentID)
+	rec[3] = strconv.Itoa(p.AcceptedAnswerID)
+	rec[4] = formatTime(p.CreationDate)
+	rec[5] = strconv.Itoa(p.Score)
+	rec[6] = strconv.Itoa(p.ViewCount)
+	rec[7] = body
+	rec[8] = strconv.Itoa(p.OwnerUserID)
+	rec[9] = p.OwnerDisplayName
+	rec[10] = strconv.Itoa(p.LastEditorUserID)
+	rec[11] = p.LastEditorDisplayName
+	rec[12] = formatTime(p.LastEditDate)
+	rec[13] = formatTime(p.LastActivitityDate)
+	rec[14] = p.Title
+	// tags can't have spaces in them
+	rec[15] = strings.Join(p.Tags, " ")
+	rec[16] = strconv.Itoa(p.AnswerCount)
+	rec[17] = strconv.Itoa(p.CommentCount)
+	rec[18] = strconv.Itoa(p.FavoriteCount)
+	rec[19] = formatTime(p.CommunityOwnedDate)
+	rec[20] = formatTime(p.ClosedDate)
+	return nil
+}
+
+var tagCsvHeader = []string{"Id", "TagName", "Count", "ExcerptPostId", "WikiPostId"}
+
+func tagToCsvRecord(t *stackoverflow.Tag, w *blobstore.Writer, rec []string) error {
+	rec[0] = strconv.Itoa(t.ID)
+	rec[1] = t.TagName
+	rec[2] = strconv.Itoa(t.Count)
+	rec[3] = strconv.Itoa(t.ExcerptPostID)
+	rec[4] = strconv.Itoa(t.WikiPostID)
+	return nil
+}
+
+var userCsvHeader = []string{"Id", "Reputation", "CreationDate", "DisplayName", "LastAccessDate", "WebsiteUrl", "Location", "AboutMe", "Views", "UpVotes", "DownVotes", "Age", "AccountId", "ProfileImageUrl"}
+
+func userToCsvRecord(u *stackoverflow.User, w *blobstore.Writer, rec []string) error {
+	about, err := writeText(w, u.AboutMe)
 	if err != nil {
 		return err
 	}
-	about := fmt.Sprintf("%d-%d", aboutPos, aboutLen)
 	rec[0] = strconv.Itoa(u.ID)
 	rec[1] = strconv.Itoa(u.Reputation)
-	rec[2] = u.CreationDate.Format(stackoverflow.TimeFormat)
+	rec[2] = formatTime(u.CreationDate)
 	rec[3] = u.DisplayName
-	rec[4] = u.LastAccessDate.Format(stackoverflow.TimeFormat)
+	rec[4] = formatTime(u.LastAccessDate)
 	rec[5] = u.WebsiteURL
 	rec[6] = u.Location
 	rec[7] = about
@@ -73,51 +257,193 @@ func userToCsvRecord(u *stackoverflow.User, w *textWriter, rec []string) error {
 	return nil
 }
 
-func usersToCsv(path string) error {
+var voteCsvHeader = []string{"Id", "PostId", "VoteTypeId", "UserId", "BountyAmount", "CreationDate"}
+
+func voteToCsvRecord(v *stackoverflow.Vote, w *blobstore.Writer, rec []string) error {
+	rec[0] = strconv.Itoa(v.ID)
+	rec[1] = strconv.Itoa(v.PostID)
+	rec[2] = strconv.Itoa(v.VoteTypeID)
+	rec[3] = strconv.Itoa(v.UserID)
+	rec[4] = strconv.Itoa(v.BountyAmount)
+	rec[5] = formatTime(v.CreationDate)
+	return nil
+}
+
+// table describes how to convert one .xml file to .csv
+type table struct {
+	// base name of .xml, .csv and .blob files e.g. "users"
+	name      string
+	header    []string
+	newReader func(string) (*stackoverflow.Reader, error)
+	// toRecord converts current record of the reader to csv record. Large
+	// text fields are written to blob store
+	toRecord func(r *stackoverflow.Reader, w *blobstore.Writer, rec []string) error
+}
+
+var tables = []*table{
+	{"badges", badgeCsvHeader, stackoverflow.NewBadgesReaderFromFile, func(r *stackoverflow.Reader, w *blobstore.Writer, rec []string) error {
+		return badgeToCsvRecord(&r.Badge, w, rec)
+	}},
+	{"comments", commentCsvHeader, stackoverflow.NewCommentsReaderFromFile, func(r *stackoverflow.Reader, w *blobstore.Writer, rec []string) error {
+		return commentToCsvRecord(&r.Comment, w, rec)
+	}},
+	{"posthistory", postHistoryCsvHeader, stackoverflow.NewPostHistoryReaderFromFile, func(r *stackoverflow.Reader, w *blobstore.Writer, rec []string) error {
+		return postHistoryToCsvRecord(&r.PostHistory, w, rec)
+	}},
+	{"postlinks", postLinkCsvHeader, stackoverflow.NewPostLinksReaderFromFile, func(r *stackoverflow.Reader, w *blobstore.Writer, rec []string) error {
+		return postLinkToCsvRecord(&r.PostLink, w, rec)
+	}},
+	{"posts", postCsvHeader, stackoverflow.NewPostsReaderFromFile, func(r *stackoverflow.Reader, w *blobstore.Writer, rec []string) error {
+		return postToCsvRecord(&r.Post, w, rec)
+	}},
+	{"tags", tagCsvHeader, stackoverflow.NewTagsReaderFromFile, func(r *stackoverflow.Reader, w *blobstore.Writer, rec []string) error {
+		return tagToCsvRecord(&r.Tag, w, rec)
+	}},
+	{"users", userCsvHeader, stackoverflow.NewUsersReaderFromFile, func(r *stackoverflow.Reader, w *blobstore.Writer, rec []string) error {
+		return userToCsvRecord(&r.User, w, rec)
+	}},
+	{"votes", voteCsvHeader, stackoverflow.NewVotesReaderFromFile, func(r *stackoverflow.Reader, w *blobstore.Writer, rec []string) error {
+		return voteToCsvRecord(&r.Vote, w, rec)
+	}},
+}
+
+func findTable(name string) *table {
+	for _, t := range tables {
+		if t.name+".xml" == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// columnIndexes returns indexes of columns in header, in the order of columns
+func columnIndexes(header []string, columns []string) ([]int, error) {
+	var res []int
+	for _, col := range columns {
+		idx := -1
+		for i, name := range header {
+			if strings.EqualFold(name, col) {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return nil, fmt.Errorf("unknown column '%s', valid columns are: %s", col, strings.Join(header, ", "))
+		}
+		res = append(res, idx)
+	}
+	return res, nil
+}
+
+// project returns values of rec at given indexes
+func project(rec []string, idxs []int, res []string) []string {
+	if idxs == nil {
+		return rec
+	}
+	for i, idx := range idxs {
+		res[i] = rec[idx]
+	}
+	return res
+}
+
+// toCsv converts .xml file to .csv file. By default .csv file has the same
+// base name and is in the same directory. Large text fields are written to
+// .blob file next to .csv file, unless they're inlined
+func toCsv(path string, t *table, opts *options) error {
 	timeStart := time.Now()
 	n := 0
-	r, err := stackoverflow.NewUsersReaderFromFile(path)
+	var idxs []int
+	var err error
+	if opts.columns != nil {
+		idxs, err = columnIndexes(t.header, opts.columns)
+		if err != nil {
+			return err
+		}
+	}
+	r, err := t.newReader(path)
 	if err != nil {
-		return fmt.Errorf("usersToCsv: NewUsersReader() failed with %s\n", err)
+		return fmt.Errorf("toCsv: newReader() failed with %s\n", err)
 	}
+	defer r.Close()
 
-	dir := filepath.Dir(path)
-	csvPath := filepath.Join(dir, "users.csv")
-	f, err := os.Create(csvPath)
-	if err != nil {
-		return err
+	csvPath := opts.out
+	if csvPath == "" {
+		csvPath = filepath.Join(filepath.Dir(path), t.name+".csv")
 	}
-	defer f.Close()
-	textPath := filepath.Join(dir, "users.txt")
-	textWriter, err := newTextWriter(textPath)
-	if err != nil {
-		return err
+	var out io.Writer = os.Stdout
+	var f *os.File
+	if csvPath != "-" {
+		f, err = os.Create(csvPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	var blobs *blobstore.Writer
+	if !opts.inlineTxt {
+		blobPath := filepath.Join(filepath.Dir(path), t.name+".blob")
+		if csvPath != "-" {
+			blobPath = strings.TrimSuffix(csvPath, filepath.Ext(csvPath)) + ".blob"
+		}
+		blobs, err = blobstore.Create(blobPath, &blobstore.Options{Compress: opts.compress})
+		if err != nil {
+			return err
+		}
+		defer blobs.Close()
+	}
+	w := newCsvWriter(out, opts.comma, opts.quote)
+	projected := make([]string, len(idxs))
+	if opts.header {
+		w.Write(project(t.header, idxs, projected))
 	}
-	defer textWriter.Close()
-	w := csv.NewWriter(f)
-	defer w.Flush()
-	var rec [14]string
+	rec := make([]string, len(t.header))
 	for r.Next() {
-		u := &r.User
-		err = userToCsvRecord(u, textWriter, rec[:])
+		err = t.toRecord(r, blobs, rec)
+		if err != nil {
+			return err
+		}
+		err = w.Write(project(rec, idxs, projected))
 		if err != nil {
 			return err
 		}
-		w.Write(rec[:])
 		n++
 	}
 	if r.Err() != nil {
 		return r.Err()
 	}
-	fmt.Printf("converted %d users in %s\n", n, time.Since(timeStart))
+	if blobs != nil {
+		if err = blobs.Close(); err != nil {
+			return err
+		}
+	}
+	// a failed write of buffered data or close means .csv file is truncated
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if f != nil {
+		if err = f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("converted %d %s in %s\n", n, t.name, time.Since(timeStart))
+	}
 	return nil
 }
 
 func main() {
-	if len(os.Args) != 2 {
+	parseFlags()
+	if flag.NArg() != 1 {
 		usageAndExit()
 	}
-	path := u.ExpandTildeInPath(os.Args[1])
+	opts, err := parseOptions()
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		usageAndExit()
+	}
+	if opts.out != "" && opts.out != "-" {
+		opts.out = u.ExpandTildeInPath(opts.out)
+	}
+	path := u.ExpandTildeInPath(flag.Arg(0))
 	if !u.PathExists(path) {
 		fmt.Printf("file '%s' doesn't exist\n", path)
 		usageAndExit()
@@ -128,14 +454,14 @@ func main() {
 		usageAndExit()
 	}
 	name := strings.ToLower(filepath.Base(path))
-	var err error
-	switch name {
-	case "users.xml":
-		err = usersToCsv(path)
-	default:
+	t := findTable(name)
+	if t != nil {
+		err = toCsv(path, t, opts)
+	} else {
 		err = fmt.Errorf("'%s' is not a recognized file name\n", path)
 	}
 	if err != nil {
 		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
 	}
 }
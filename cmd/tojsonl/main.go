@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+var (
+	flgOut   string
+	flgGzip  bool
+	flgShard int
+)
+
+func parseFlags() {
+	flag.StringVar(&flgOut, "out", "", "directory for .jsonl files, '-' for stdout. Default is directory of .xml files")
+	flag.BoolVar(&flgGzip, "gzip", false, "compress with gzip and write .jsonl.gz files")
+	flag.IntVar(&flgShard, "shard", 0, "if > 0, write at most this many records per file, as <table>-00000.jsonl, <table>-00001.jsonl etc.")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Fprintf(os.Stderr, "usage: tojsonl [flags] <directory or file.xml>\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+// table describes how to convert one .xml file to .jsonl
+type table struct {
+	// base name of .xml and .jsonl files e.g. "users"
+	name      string
+	newReader func(string) (*stackoverflow.Reader, error)
+}
+
+var tables = []*table{
+	{"badges", stackoverflow.NewBadgesReaderFromFile},
+	{"comments", stackoverflow.NewCommentsReaderFromFile},
+	{"posthistory", stackoverflow.NewPostHistoryReaderFromFile},
+	{"postlinks", stackoverflow.NewPostLinksReaderFromFile},
+	{"posts", stackoverflow.NewPostsReaderFromFile},
+	{"tags", stackoverflow.NewTagsReaderFromFile},
+	{"users", stackoverflow.NewUsersReaderFromFile},
+	{"votes", stackoverflow.NewVotesReaderFromFile},
+}
+
+func findTable(name string) *table {
+	for _, t := range tables {
+		if t.name+".xml" == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// shardWriter writes records as json lines to one or more files, starting
+// a new file after maxRecords records
+type shardWriter struct {
+	dir        string
+	name       string
+	compress   bool
+	maxRecords int
+
+	nRecords int
+	nShards  int
+	f        io.WriteCloser
+	gz       *gzip.Writer
+	bw       *bufio.Writer
+	enc      *json.Encoder
+}
+
+func (w *shardWriter) path() string {
+	name := w.name
+	if w.maxRecords > 0 {
+		name = fmt.Sprintf("%s-%05d", name, w.nShards)
+	}
+	name += ".jsonl"
+	if w.compress {
+		name += ".gz"
+	}
+	return filepath.Join(w.dir, name)
+}
+
+func (w *shardWriter) open() error {
+	if w.dir == "-" {
+		w.f = os.Stdout
+	} else {
+		f, err := os.Create(w.path())
+		if err != nil {
+			return err
+		}
+		w.f = f
+	}
+	var out io.Writer = w.f
+	if w.compress {
+		w.gz = gzip.NewWriter(w.f)
+		out = w.gz
+	}
+	w.bw = bufio.NewWriterSize(out, 256*1024)
+	w.enc = json.NewEncoder(w.bw)
+	w.enc.SetEscapeHTML(false)
+	w.nShards++
+	return nil
+}
+
+// Write writes a record as a single line
+func (w *shardWriter) Write(v interface{}) error {
+	if w.enc != nil && w.maxRecords > 0 && w.nRecords == w.maxRecords {
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+	if w.enc == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+		w.nRecords = 0
+	}
+	w.nRecords++
+	// Encode terminates each value with a newline
+	return w.enc.Encode(v)
+}
+
+// Close flushes and closes current file
+func (w *shardWriter) Close() error {
+	if w.enc == nil {
+		return nil
+	}
+	err := w.bw.Flush()
+	if w.gz != nil {
+		if err2 := w.gz.Close(); err == nil {
+			err = err2
+		}
+		w.gz = nil
+	}
+	if w.f != os.Stdout {
+		if err2 := w.f.Close(); err == nil {
+			err = err2
+		}
+	}
+	w.f, w.bw, w.enc = nil, nil, nil
+	return err
+}
+
+func toJsonl(path string, t *table, out string) error {
+	timeStart := time.Now()
+	n := 0
+	r, err := t.newReader(path)
+	if err != nil {
+		return fmt.Errorf("toJsonl: newReader() failed with %s\n", err)
+	}
+	defer r.Close()
+
+	if out == "" {
+		out = filepath.Dir(path)
+	}
+	w := &shardWriter{
+		dir:        out,
+		name:       t.name,
+		compress:   flgGzip,
+		maxRecords: flgShard,
+	}
+	defer w.Close()
+	for r.Next() {
+		if err = w.Write(r.Record()); err != nil {
+			return err
+		}
+		n++
+	}
+	if r.Err() != nil {
+		return r.Err()
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "converted %d %s to %d file(s) in %s\n", n, t.name, w.nShards, time.Since(timeStart))
+	return nil
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 || flgShard < 0 {
+		usageAndExit()
+	}
+	out := flgOut
+	if out != "" && out != "-" {
+		out = u.ExpandTildeInPath(out)
+		if err := os.MkdirAll(out, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if out == "-" && flgShard > 0 {
+		fmt.Fprintf(os.Stderr, "can't use -shard when writing to stdout\n")
+		usageAndExit()
+	}
+	path := u.ExpandTildeInPath(flag.Arg(0))
+	st, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "'%s' doesn't exist\n", path)
+		usageAndExit()
+	}
+	if !st.IsDir() {
+		t := findTable(strings.ToLower(filepath.Base(path)))
+		if t == nil {
+			fmt.Fprintf(os.Stderr, "'%s' is not a recognized file name\n", path)
+			usageAndExit()
+		}
+		if err = toJsonl(path, t, out); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	for _, t := range tables {
+		xmlPath := stackoverflow.FindXMLFile(path, t.name+".xml")
+		if xmlPath == "" {
+			fmt.Fprintf(os.Stderr, "skipping %s, no .xml file in '%s'\n", t.name, path)
+			continue
+		}
+		if err = toJsonl(xmlPath, t, out); err != nil {
+			fmt.Fprintf(os.Stderr, "error: converting '%s' failed with %s\n", xmlPath, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/internal/u"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+var (
+	flgOut         string
+	flgRowGroupMB  int
+	flgPageKB      int
+	flgCompression string
+	flgParallel    int
+)
+
+func parseFlags() {
+	flag.StringVar(&flgOut, "out", "", "directory for .parquet files. Default is directory of .xml files")
+	flag.IntVar(&flgRowGroupMB, "rowgroup", 128, "approximate size of row group, in megabytes. Rows of a group are kept in memory until it's written")
+	flag.IntVar(&flgPageKB, "page", 64, "approximate size of a page, in kilobytes")
+	flag.StringVar(&flgCompression, "compression", "snappy", "compression: 'snappy', 'gzip', 'zstd', 'lz4' or 'none'")
+	flag.IntVar(&flgParallel, "parallel", 4, "number of goroutines encoding columns")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: toparquet [flags] <directory or file.xml>\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func parseCompression(s string) (parquet.CompressionCodec, error) {
+	switch strings.ToLower(s) {
+	case "snappy":
+		return parquet.CompressionCodec_SNAPPY, nil
+	case "gzip":
+		return parquet.CompressionCodec_GZIP, nil
+	case "zstd":
+		return parquet.CompressionCodec_ZSTD, nil
+	case "lz4":
+		return parquet.CompressionCodec_LZ4, nil
+	case "none":
+		return parquet.CompressionCodec_UNCOMPRESSED, nil
+	}
+	return 0, fmt.Errorf("invalid -compression '%s'", s)
+}
+
+// options tells how to write .parquet files
+type options struct {
+	out          string
+	rowGroupSize int64
+	pageSize     int64
+	compression  parquet.CompressionCodec
+	parallel     int64
+}
+
+// table describes how to convert one .xml file to .parquet
+type table struct {
+	// base name of .xml and .parquet files e.g. "users"
+	name      string
+	newReader func(string) (*stackoverflow.Reader, error)
+	// schema is a row struct with parquet tags, used to build the schema
+	schema interface{}
+	// toRow converts current record of the reader to a row struct.
+	// Rows are buffered by parquet writer so it must return a new value
+	toRow func(r *stackoverflow.Reader) interface{}
+}
+
+var tables = []*table{
+	{"badges", stackoverflow.NewBadgesReaderFromFile, new(badgeRow), func(r *stackoverflow.Reader) interface{} {
+		return badgeToRow(&r.Badge)
+	}},
+	{"comments", stackoverflow.NewCommentsReaderFromFile, new(commentRow), func(r *stackoverflow.Reader) interface{} {
+		return commentToRow(&r.Comment)
+	}},
+	{"posthistory", stackoverflow.NewPostHistoryReaderFromFile, new(postHistoryRow), func(r *stackoverflow.Reader) interface{} {
+		return postHistoryToRow(&r.PostHistory)
+	}},
+	{"postlinks", stackoverflow.NewPostLinksReaderFromFile, new(postLinkRow), func(r *stackoverflow.Reader) interface{} {
+		return postLinkToRow(&r.PostLink)
+	}},
+	{"posts", stackoverflow.NewPostsReaderFromFile, new(postRow), func(r *stackoverflow.Reader) interface{} {
+		return postToRow(&r.Post)
+	}},
+	{"tags", stackoverflow.NewTagsReaderFromFile, new(tagRow), func(r *stackoverflow.Reader) interface{} {
+		return tagToRow(&r.Tag)
+	}},
+	{"users", stackoverflow.NewUsersReaderFromFile, new(userRow), func(r *stackoverflow.Reader) interface{} {
+		return userToRow(&r.User)
+	}},
+	{"votes", stackoverflow.NewVotesReaderFromFile, new(voteRow), func(r *stackoverflow.Reader) interface{} {
+		return voteToRow(&r.Vote)
+	}},
+}
+
+func findTable(name string) *table {
+	for _, t := range tables {
+		if t.name+".xml" == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// toParquet converts .xml file at path to <table>.parquet. Rows are
+// streamed to parquet writer which keeps at most one row group in memory
+func toParquet(path string, t *table, opts *options) (err error) {
+	timeStart := time.Now()
+	n := 0
+	r, err := t.newReader(path)
+	if err != nil {
+		return fmt.Errorf("toParquet: newReader() failed with %s\n", err)
+	}
+	defer r.Close()
+
+	dir := opts.out
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+	parquetPath := filepath.Join(dir, t.name+".parquet")
+	f, err := os.Create(parquetPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err2 := f.Close()
+		if err == nil {
+			err = err2
+		}
+	}()
+	pw, err := writer.NewParquetWriterFromWriter(f, t.schema, opts.parallel)
+	if err != nil {
+		return err
+	}
+	pw.RowGroupSize = opts.rowGroupSize
+	pw.PageSize = opts.pageSize
+	pw.CompressionType = opts.compression
+	for r.Next() {
+		err = pw.Write(t.toRow(r))
+		if err != nil {
+			return err
+		}
+		n++
+	}
+	if r.Err() != nil {
+		return r.Err()
+	}
+	if err = pw.WriteStop(); err != nil {
+		return err
+	}
+	fmt.Printf("converted %d %s to %s in %s\n", n, t.name, parquetPath, time.Since(timeStart))
+	return nil
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	compression, err := parseCompression(flgCompression)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+		usageAndExit()
+	}
+	if flgRowGroupMB <= 0 || flgPageKB <= 0 || flgParallel <= 0 {
+		fmt.Printf("error: -rowgroup, -page and -parallel must be positive\n")
+		usageAndExit()
+	}
+	opts := &options{
+		rowGroupSize: int64(flgRowGroupMB) * 1024 * 1024,
+		pageSize:     int64(flgPageKB) * 1024,
+		compression:  compression,
+		parallel:     int64(flgParallel),
+	}
+	if flgOut != "" {
+		opts.out = u.ExpandTildeInPath(flgOut)
+		if err = os.MkdirAll(opts.out, 0755); err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	path := u.ExpandTildeInPath(flag.Arg(0))
+	st, err := os.Stat(path)
+	if err != nil {
+		fmt.Printf("'%s' doesn't exist\n", path)
+		usageAndExit()
+	}
+	if !st.IsDir() {
+		t := findTable(strings.ToLower(filepath.Base(path)))
+		if t == nil {
+			fmt.Printf("'%s' is not a recognized file name\n", path)
+			usageAndExit()
+		}
+		if err = toParquet(path, t, opts); err != nil {
+			fmt.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	for _, t := range tables {
+		xmlPath := stackoverflow.FindXMLFile(path, t.name+".xml")
+		if xmlPath == "" {
+			fmt.Printf("skipping %s, no .xml file in '%s'\n", t.name, path)
+			continue
+		}
+		if err = toParquet(xmlPath, t, opts); err != nil {
+			fmt.Printf("error: converting '%s' failed with %s\n", xmlPath, err)
+			os.Exit(1)
+		}
+	}
+}
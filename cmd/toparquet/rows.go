@@ -0,0 +1,241 @@
+package main
+
+import (
+	"time"
+
+	"github.com/kjk/stackoverflow"
+)
+
+// Parquet rows for each table. Column names are the same as in .xml files.
+// Ids that refer to other records and optional values are nullable. Times
+// are stored as milliseconds since epoch, in UTC
+
+type badgeRow struct {
+	ID     int32  `parquet:"name=Id, type=INT32"`
+	UserID *int32 `parquet:"name=UserId, type=INT32"`
+	Name   string `parquet:"name=Name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Date   *int64 `parquet:"name=Date, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+}
+
+func badgeToRow(b *stackoverflow.Badge) *badgeRow {
+	return &badgeRow{
+		ID:     int32(b.ID),
+		UserID: optInt(b.UserID),
+		Name:   b.Name,
+		Date:   optTime(b.Date),
+	}
+}
+
+type commentRow struct {
+	ID              int32   `parquet:"name=Id, type=INT32"`
+	PostID          int32   `parquet:"name=PostId, type=INT32"`
+	Score           int32   `parquet:"name=Score, type=INT32"`
+	Text            string  `parquet:"name=Text, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CreationDate    *int64  `parquet:"name=CreationDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	UserID          *int32  `parquet:"name=UserId, type=INT32"`
+	UserDisplayName *string `parquet:"name=UserDisplayName, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+func commentToRow(c *stackoverflow.Comment) *commentRow {
+	return &commentRow{
+		ID:              int32(c.ID),
+		PostID:          int32(c.PostID),
+		Score:           int32(c.Score),
+		Text:            c.Text,
+		CreationDate:    optTime(c.CreationDate),
+		UserID:          optInt(c.UserID),
+		UserDisplayName: optString(c.UserDisplayName),
+	}
+}
+
+type postHistoryRow struct {
+	ID                int32   `parquet:"name=Id, type=INT32"`
+	PostHistoryTypeID int32   `parquet:"name=PostHistoryTypeId, type=INT32"`
+	PostID            int32   `parquet:"name=PostId, type=INT32"`
+	RevisionGUID      *string `parquet:"name=RevisionGUID, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CreationDate      *int64  `parquet:"name=CreationDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	UserID            *int32  `parquet:"name=UserId, type=INT32"`
+	UserDisplayName   *string `parquet:"name=UserDisplayName, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Comment           *string `parquet:"name=Comment, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Text              *string `parquet:"name=Text, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+func postHistoryToRow(h *stackoverflow.PostHistory) *postHistoryRow {
+	return &postHistoryRow{
+		ID:                int32(h.ID),
+		PostHistoryTypeID: int32(h.PostHistoryTypeID),
+		PostID:            int32(h.PostID),
+		RevisionGUID:      optString(h.RevisionGUID),
+		CreationDate:      optTime(h.CreationDate),
+		UserID:            optInt(h.UserID),
+		UserDisplayName:   optString(h.UserDisplayName),
+		Comment:           optString(h.Comment),
+		Text:              optString(h.Text),
+	}
+}
+
+type postLinkRow struct {
+	ID            int32  `parquet:"name=Id, type=INT32"`
+	CreationDate  *int64 `parquet:"name=CreationDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	PostID        int32  `parquet:"name=PostId, type=INT32"`
+	RelatedPostID int32  `parquet:"name=RelatedPostId, type=INT32"`
+	LinkTypeID    int32  `parquet:"name=LinkTypeId, type=INT32"`
+}
+
+func postLinkToRow(l *stackoverflow.PostLink) *postLinkRow {
+	return &postLinkRow{
+		ID:            int32(l.ID),
+		CreationDate:  optTime(l.CreationDate),
+		PostID:        int32(l.PostID),
+		RelatedPostID: int32(l.RelatedPostID),
+		LinkTypeID:    int32(l.LinkTypeID),
+	}
+}
+
+type postRow struct {
+	ID                    int32    `parquet:"name=Id, type=INT32"`
+	PostTypeID            int32    `parquet:"name=PostTypeId, type=INT32"`
+	ParentID              *int32   `parquet:"name=ParentId, type=INT32"`
+	AcceptedAnswerID      *int32   `parquet:"name=AcceptedAnswerId, type=INT32"`
+	CreationDate          *int64   `parquet:"name=CreationDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	Score                 int32    `parquet:"name=Score, type=INT32"`
+	ViewCount             *int32   `parquet:"name=ViewCount, type=INT32"`
+	Body                  string   `parquet:"name=Body, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OwnerUserID           *int32   `parquet:"name=OwnerUserId, type=INT32"`
+	OwnerDisplayName      *string  `parquet:"name=OwnerDisplayName, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LastEditorUserID      *int32   `parquet:"name=LastEditorUserId, type=INT32"`
+	LastEditorDisplayName *string  `parquet:"name=LastEditorDisplayName, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LastEditDate          *int64   `parquet:"name=LastEditDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	LastActivityDate      *int64   `parquet:"name=LastActivityDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	Title                 *string  `parquet:"name=Title, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Tags                  []string `parquet:"name=Tags, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	AnswerCount           int32    `parquet:"name=AnswerCount, type=INT32"`
+	CommentCount          int32    `parquet:"name=CommentCount, type=INT32"`
+	FavoriteCount         int32    `parquet:"name=FavoriteCount, type=INT32"`
+	CommunityOwnedDate    *int64   `parquet:"name=CommunityOwnedDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	ClosedDate            *int64   `parquet:"name=ClosedDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+}
+
+func postToRow(p *stackoverflow.Post) *postRow {
+	return &postRow{
+		ID:                    int32(p.ID),
+		PostTypeID:            int32(p.PostTypeID),
+		ParentID:              optInt(p.ParentID),
+		AcceptedAnswerID:      optInt(p.AcceptedAnswerID),
+		CreationDate:          optTime(p.CreationDate),
+		Score:                 int32(p.Score),
+		ViewCount:             optInt(p.ViewCount),
+		Body:                  p.Body,
+		OwnerUserID:           optInt(p.OwnerUserID),
+		OwnerDisplayName:      optString(p.OwnerDisplayName),
+		LastEditorUserID:      optInt(p.LastEditorUserID),
+		LastEditorDisplayName: optString(p.LastEditorDisplayName),
+		LastEditDate:          optTime(p.LastEditDate),
+		LastActivityDate:      optTime(p.LastActivitityDate),
+		Title:                 optString(p.Title),
+		Tags:                  p.Tags,
+		AnswerCount:           int32(p.AnswerCount),
+		CommentCount:          int32(p.CommentCount),
+		FavoriteCount:         int32(p.FavoriteCount),
+		CommunityOwnedDate:    optTime(p.CommunityOwnedDate),
+		ClosedDate:            optTime(p.ClosedDate),
+	}
+}
+
+type tagRow struct {
+	ID            int32  `parquet:"name=Id, type=INT32"`
+	TagName       string `parquet:"name=TagName, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Count         int32  `parquet:"name=Count, type=INT32"`
+	ExcerptPostID *int32 `parquet:"name=ExcerptPostId, type=INT32"`
+	WikiPostID    *int32 `parquet:"name=WikiPostId, type=INT32"`
+}
+
+func tagToRow(t *stackoverflow.Tag) *tagRow {
+	return &tagRow{
+		ID:            int32(t.ID),
+		TagName:       t.TagName,
+		Count:         int32(t.Count),
+		ExcerptPostID: optInt(t.ExcerptPostID),
+		WikiPostID:    optInt(t.WikiPostID),
+	}
+}
+
+type userRow struct {
+	ID              int32   `parquet:"name=Id, type=INT32"`
+	Reputation      int32   `parquet:"name=Reputation, type=INT32"`
+	CreationDate    *int64  `parquet:"name=CreationDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	DisplayName     string  `parquet:"name=DisplayName, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LastAccessDate  *int64  `parquet:"name=LastAccessDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+	WebsiteURL      *string `parquet:"name=WebsiteUrl, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Location        *string `parquet:"name=Location, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AboutMe         *string `parquet:"name=AboutMe, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Views           int32   `parquet:"name=Views, type=INT32"`
+	UpVotes         int32   `parquet:"name=UpVotes, type=INT32"`
+	DownVotes       int32   `parquet:"name=DownVotes, type=INT32"`
+	Age             *int32  `parquet:"name=Age, type=INT32"`
+	AccountID       *int32  `parquet:"name=AccountId, type=INT32"`
+	ProfileImageURL *string `parquet:"name=ProfileImageUrl, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+func userToRow(u *stackoverflow.User) *userRow {
+	return &userRow{
+		ID:              int32(u.ID),
+		Reputation:      int32(u.Reputation),
+		CreationDate:    optTime(u.CreationDate),
+		DisplayName:     u.DisplayName,
+		LastAccessDate:  optTime(u.LastAccessDate),
+		WebsiteURL:      optString(u.WebsiteURL),
+		Location:        optString(u.Location),
+		AboutMe:         optString(u.AboutMe),
+		Views:           int32(u.Views),
+		UpVotes:         int32(u.UpVotes),
+		DownVotes:       int32(u.DownVotes),
+		Age:             optInt(u.Age),
+		AccountID:       optInt(u.AccountID),
+		ProfileImageURL: optString(u.ProfileImageURL),
+	}
+}
+
+type voteRow struct {
+	ID           int32  `parquet:"name=Id, type=INT32"`
+	PostID       int32  `parquet:"name=PostId, type=INT32"`
+	VoteTypeID   int32  `parquet:"name=VoteTypeId, type=INT32"`
+	UserID       *int32 `parquet:"name=UserId, type=INT32"`
+	BountyAmount *int32 `parquet:"name=BountyAmount, type=INT32"`
+	CreationDate *int64 `parquet:"name=CreationDate, type=INT64, convertedtype=TIMESTAMP_MILLIS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MILLIS"`
+}
+
+func voteToRow(v *stackoverflow.Vote) *voteRow {
+	return &voteRow{
+		ID:           int32(v.ID),
+		PostID:       int32(v.PostID),
+		VoteTypeID:   int32(v.VoteTypeID),
+		UserID:       optInt(v.UserID),
+		BountyAmount: optInt(v.BountyAmount),
+		CreationDate: optTime(v.CreationDate),
+	}
+}
+
+// optInt returns nil for 0, which is how missing values are decoded
+func optInt(v int) *int32 {
+	if v == 0 {
+		return nil
+	}
+	n := int32(v)
+	return &n
+}
+
+func optString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func optTime(t time.Time) *int64 {
+	if t.IsZero() {
+		return nil
+	}
+	ms := t.UnixNano() / int64(time.Millisecond)
+	return &ms
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/internal/u"
+)
+
+// topostgres writes a .sql file that can be loaded into PostgreSQL with:
+// psql -d <db> -f so.sql
+// Data is in COPY ... FROM STDIN text format so no connection to the
+// database is needed to generate it
+
+const (
+	timeFormatSQL = "2006-01-02 15:04:05.000"
+
+	// nullValue is how NULL is represented in COPY text format. It can't be
+	// confused with a string because backslashes in strings are escaped
+	nullValue = `\N`
+)
+
+var (
+	flgOut     string
+	flgDrop    bool
+	flgSchema  bool
+	flgData    bool
+	flgIndexes bool
+)
+
+func parseFlags() {
+	flag.StringVar(&flgOut, "out", "-", "path of .sql file, '-' for stdout")
+	flag.BoolVar(&flgDrop, "drop", false, "drop tables before creating them")
+	flag.BoolVar(&flgSchema, "schema", true, "write CREATE TABLE statements")
+	flag.BoolVar(&flgData, "data", true, "write COPY statements with data")
+	flag.BoolVar(&flgIndexes, "indexes", true, "write primary keys and indexes, created after data is loaded")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Fprintf(os.Stderr, "usage: topostgres [flags] <directory or file.xml>\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+// escapeCopy escapes s for COPY text format
+func escapeCopy(s string) string {
+	if !strings.ContainsAny(s, "\\\t\n\r") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// helpers that convert values to COPY text format. Values that are missing
+// from .xml files are decoded as zero values and become NULL
+
+func copyText(s string) string {
+	return escapeCopy(s)
+}
+
+func copyOptText(s string) string {
+	if s == "" {
+		return nullValue
+	}
+	return escapeCopy(s)
+}
+
+func copyInt(v int) string {
+	return strconv.Itoa(v)
+}
+
+func copyOptInt(v int) string {
+	if v == 0 {
+		return nullValue
+	}
+	return strconv.Itoa(v)
+}
+
+func copyTime(t time.Time) string {
+	if t.IsZero() {
+		return nullValue
+	}
+	return t.UTC().Format(timeFormatSQL)
+}
+
+// copyTags encodes tags as text[] literal e.g. {"go","http"}
+func copyTags(tags []string) string {
+	if len(tags) == 0 {
+		return nullValue
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, tag := range tags {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('"')
+		for j := 0; j < len(tag); j++ {
+			if tag[j] == '"' || tag[j] == '\\' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(tag[j])
+		}
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return escapeCopy(sb.String())
+}
+
+type column struct {
+	name string
+	typ  string
+}
+
+// table describes how to convert one .xml file to sql table
+type table struct {
+	// base name of .xml file e.g. "posthistory"
+	name      string
+	sqlName   string
+	newReader func(string) (*stackoverflow.Reader, error)
+	columns   []column
+	// indexes are created after loading data, in addition to primary key on Id
+	indexes []string
+	// toRecord converts current record of the reader to COPY values
+	toRecord func(r *stackoverflow.Reader, rec []string)
+}
+
+var tables = []*table{
+	{
+		name:      "badges",
+		sqlName:   "Badges",
+		newReader: stackoverflow.NewBadgesReaderFromFile,
+		columns:   []column{{"Id", "integer NOT NULL"}, {"UserId", "integer"}, {"Name", "text NOT NULL"}, {"Date", "timestamp"}},
+		indexes:   []string{"UserId"},
+		toRecord: func(r *stackoverflow.Reader, rec []string) {
+			b := &r.Badge
+			rec[0] = copyInt(b.ID)
+			rec[1] = copyOptInt(b.UserID)
+			rec[2] = copyText(b.Name)
+			rec[3] = copyTime(b.Date)
+		},
+	},
+	{
+		name:      "comments",
+		sqlName:   "Comments",
+		newReader: stackoverflow.NewCommentsReaderFromFile,
+		columns:   []column{{"Id", "integer NOT NULL"}, {"PostId", "integer NOT NULL"}, {"Score", "integer NOT NULL"}, {"Text", "text"}, {"CreationDate", "timestamp"}, {"UserDisplayName", "text"}, {"UserId", "integer"}},
+		indexes:   []string{"PostId", "UserId"},
+		toRecord: func(r *stackoverflow.Reader, rec []string) {
+			c := &r.Comment
+			rec[0] = copyInt(c.ID)
+			rec[1] = copyInt(c.PostID)
+			rec[2] = copyInt(c.Score)
+			rec[3] = copyText(c.Text)
+			rec[4] = copyTime(c.CreationDate)
+			rec[5] = copyOptText(c.UserDisplayName)
+			rec[6] = copyOptInt(c.UserID)
+		},
+	},
+	{
+		name:      "posthistory",
+		sqlName:   "PostHistory",
+		newReader: stackoverflow.NewPostHistoryReaderFromFile,
+		columns:   []column{{"Id", "integer NOT NULL"}, {"PostHistoryTypeId", "smallint NOT NULL"}, {"PostId", "integer NOT NULL"}, {"RevisionGUID", "uuid"}, {"CreationDate", "timestamp"}, {"UserId", "integer"}, {"UserDisplayName", "text"}, {"Comment", "text"}, {"Text", "text"}},
+		indexes:   []string{"PostId", "UserId"},
+		toRecord: func(r *stackoverflow.Reader, rec []string) {
+			h := &r.PostHistory
+			rec[0] = copyInt(h.ID)
+			rec[1] = copyInt(h.PostHistoryTypeID)
+			rec[2] = copyInt(h.PostID)
+			rec[3] = copyOptText(h.RevisionGUID)
+			rec[4] = copyTime(h.CreationDate)
+			rec[5] = copyOptInt(h.UserID)
+			rec[6] = copyOptText(h.UserDisplayName)
+			rec[7] = copyOptText(h.Comment)
+			rec[8] = copyOptText(h.Text)
+		},
+	},
+	{
+		name:      "postlinks",
+		sqlName:   "PostLinks",
+		newReader: stackoverflow.NewPostLinksReaderFromFile,
+		columns:   []column{{"Id", "integer NOT NULL"}, {"CreationDate", "timestamp"}, {"PostId", "integer NOT NULL"}, {"RelatedPostId", "integer NOT NULL"}, {"LinkTypeId", "smallint NOT NULL"}},
+		indexes:   []string{"PostId", "RelatedPostId"},
+		toRecord: func(r *stackoverflow.Reader, rec []string) {
+			l := &r.PostLink
+			rec[0] = copyInt(l.ID)
+			rec[1] = copyTime(l.CreationDate)
+			rec[2] = copyInt(l.PostID)
+			rec[3] = copyInt(l.RelatedPostID)
+			rec[4] = copyInt(l.LinkTypeID)
+		},
+	},
+	{
+		name:      "posts",
+		sqlName:   "Posts",
+		newReader: stackoverflow.NewPostsReaderFromFile,
+		columns: []column{{"Id", "integer NOT NULL"}, {"PostTypeId", "smallint NOT NULL"}, {"AcceptedAnswerId", "integer"}, {"ParentId", "integer"}, {"CreationDate", "timestamp"}, {"Score", "integer NOT NULL"}, {"ViewCount", "integer"}, {"Body", "text"},
+			{"OwnerUserId", "integer"}, {"OwnerDisplayName", "text"}, {"LastEditorUserId", "integer"}, {"LastEditorDisplayName", "text"}, {"LastEditDate", "timestamp"}, {"LastActivityDate", "timestamp"}, {"Title", "text"}, {"Tags", "text[]"},
+			{"AnswerCount", "integer"}, {"CommentCount", "integer"}, {"FavoriteCount", "integer"}, {"ClosedDate", "timestamp"}, {"CommunityOwnedDate", "timestamp"}},
+		indexes: []string{"ParentId", "OwnerUserId", "PostTypeId"},
+		toRecord: func(r *stackoverflow.Reader, rec []string) {
+			p := &r.Post
+			rec[0] = copyInt(p.ID)
+			rec[1] = copyInt(p.PostTypeID)
+			rec[2] = copyOptInt(p.AcceptedAnswerID)
+			rec[3] = copyOptInt(p.ParentID)
+			rec[4] = copyTime(p.CreationDate)
+			rec[5] = copyInt(p.Score)
+			rec[6] = copyOptInt(p.ViewCount)
+			rec[7] = copyText(p.Body)
+			rec[8] = copyOptInt(p.OwnerUserID)
+			rec[9] = copyOptText(p.OwnerDisplayName)
+			rec[10] = copyOptInt(p.LastEditorUserID)
+			rec[11] = copyOptText(p.LastEditorDisplayName)
+			rec[12] = copyTime(p.LastEditDate)
+			rec[13] = copyTime(p.LastActivitityDate)
+			rec[14] = copyOptText(p.Title)
+			rec[15] = copyTags(p.Tags)
+			rec[16] = copyInt(p.AnswerCount)
+			rec[17] = copyInt(p.CommentCount)
+			rec[18] = copyInt(p.FavoriteCount)
+			rec[19] = copyTime(p.ClosedDate)
+			rec[20] = copyTime(p.CommunityOwnedDate)
+		},
+	},
+	{
+		name:      "tags",
+		sqlName:   "Tags",
+		newReader: stackoverflow.NewTagsReaderFromFile,
+		columns:   []column{{"Id", "integer NOT NULL"}, {"TagName", "text NOT NULL"}, {"Count", "integer NOT NULL"}, {"ExcerptPostId", "integer"}, {"WikiPostId", "integer"}},
+		indexes:   []string{"TagName"},
+		toRecord: func(r *stackoverflow.Reader, rec []string) {
+			t := &r.Tag
+			rec[0] = copyInt(t.ID)
+			rec[1] = copyText(t.TagName)
+			rec[2] = copyInt(t.Count)
+			rec[3] = copyOptInt(t.ExcerptPostID)
+			rec[4] = copyOptInt(t.WikiPostID)
+		},
+	},
+	{
+		name:      "users",
+		sqlName:   "Users",
+		newReader: stackoverflow.NewUsersReaderFromFile,
+		columns: []column{{"Id", "integer NOT NULL"}, {"Reputation", "integer NOT NULL"}, {"CreationDate", "timestamp"}, {"DisplayName", "text"}, {"LastAccessDate", "timestamp"}, {"WebsiteUrl", "text"}, {"Location", "text"},
+			{"AboutMe", "text"}, {"Views", "integer NOT NULL"}, {"UpVotes", "integer NOT NULL"}, {"DownVotes", "integer NOT NULL"}, {"ProfileImageUrl", "text"}, {"Age", "integer"}, {"AccountId", "integer"}},
+		indexes: []string{"AccountId"},
+		toRecord: func(r *stackoverflow.Reader, rec []string) {
+			u := &r.User
+			rec[0] = copyInt(u.ID)
+			rec[1] = copyInt(u.Reputation)
+			rec[2] = copyTime(u.CreationDate)
+			rec[3] = copyText(u.DisplayName)
+			rec[4] = copyTime(u.LastAccessDate)
+			rec[5] = copyOptText(u.WebsiteURL)
+			rec[6] = copyOptText(u.Location)
+			rec[7] = copyOptText(u.AboutMe)
+			rec[8] = copyInt(u.Views)
+			rec[9] = copyInt(u.UpVotes)
+			rec[10] = copyInt(u.DownVotes)
+			rec[11] = copyOptText(u.ProfileImageURL)
+			rec[12] = copyOptInt(u.Age)
+			rec[13] = copyOptInt(u.AccountID)
+		},
+	},
+	{
+		name:      "votes",
+		sqlName:   "Votes",
+		newReader: stackoverflow.NewVotesReaderFromFile,
+		columns:   []column{{"Id", "integer NOT NULL"}, {"PostId", "integer NOT NULL"}, {"VoteTypeId", "smallint NOT NULL"}, {"UserId", "integer"}, {"CreationDate", "timestamp"}, {"BountyAmount", "integer"}},
+		indexes:   []string{"PostId", "UserId"},
+		toRecord: func(r *stackoverflow.Reader, rec []string) {
+			v := &r.Vote
+			rec[0] = copyInt(v.ID)
+			rec[1] = copyInt(v.PostID)
+			rec[2] = copyInt(v.VoteTypeID)
+			rec[3] = copyOptInt(v.UserID)
+			rec[4] = copyTime(v.CreationDate)
+			rec[5] = copyOptInt(v.BountyAmount)
+		},
+	},
+}
+
+func findTable(name string) *table {
+	for _, t := range tables {
+		if t.name+".xml" == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func columnNames(t *table) string {
+	var names []string
+	for _, c := range t.columns {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func writeSchema(w io.Writer, t *table) {
+	if flgDrop {
+		fmt.Fprintf(w, "DROP TABLE IF EXISTS %s;\n", t.sqlName)
+	}
+	fmt.Fprintf(w, "CREATE TABLE %s (\n", t.sqlName)
+	for i, c := range t.columns {
+		sep := ","
+		if i == len(t.columns)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(w, "    %s %s%s\n", c.name, c.typ, sep)
+	}
+	fmt.Fprintf(w, ");\n\n")
+}
+
+// writeData writes records from .xml file as COPY ... FROM STDIN statement
+func writeData(w *bufio.Writer, path string, t *table) error {
+	timeStart := time.Now()
+	n := 0
+	r, err := t.newReader(path)
+	if err != nil {
+		return fmt.Errorf("writeData: newReader() failed with %s\n", err)
+	}
+	defer r.Close()
+
+	fmt.Fprintf(w, "COPY %s (%s) FROM STDIN;\n", t.sqlName, columnNames(t))
+	rec := make([]string, len(t.columns))
+	for r.Next() {
+		t.toRecord(r, rec)
+		for i, s := range rec {
+			if i > 0 {
+				w.WriteByte('\t')
+			}
+			w.WriteString(s)
+		}
+		w.WriteByte('\n')
+		n++
+	}
+	if r.Err() != nil {
+		return r.Err()
+	}
+	_, err = w.WriteString("\\.\n\n")
+	fmt.Fprintf(os.Stderr, "wrote %d %s in %s\n", n, t.name, time.Since(timeStart))
+	return err
+}
+
+func writeIndexes(w io.Writer, t *table) {
+	fmt.Fprintf(w, "ALTER TABLE %s ADD PRIMARY KEY (Id);\n", t.sqlName)
+	for _, col := range t.indexes {
+		fmt.Fprintf(w, "CREATE INDEX %s_%s ON %s (%s);\n", strings.ToLower(t.sqlName), strings.ToLower(col), t.sqlName, col)
+	}
+	if t.sqlName == "Posts" {
+		fmt.Fprintf(w, "CREATE INDEX posts_tags ON Posts USING gin (Tags);\n")
+	}
+	fmt.Fprintf(w, "ANALYZE %s;\n\n", t.sqlName)
+}
+
+// file is .xml file to convert and its table
+type file struct {
+	path string
+	t    *table
+}
+
+func writeSQL(w *bufio.Writer, files []file) error {
+	fmt.Fprintf(w, "SET client_encoding = 'UTF8';\n\n")
+	if flgSchema {
+		for _, f := range files {
+			writeSchema(w, f.t)
+		}
+	}
+	if flgData {
+		for _, f := range files {
+			if err := writeData(w, f.path, f.t); err != nil {
+				return err
+			}
+		}
+	}
+	if flgIndexes {
+		for _, f := range files {
+			writeIndexes(w, f.t)
+		}
+	}
+	return w.Flush()
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	path := u.ExpandTildeInPath(flag.Arg(0))
+	st, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "'%s' doesn't exist\n", path)
+		usageAndExit()
+	}
+	var files []file
+	if st.IsDir() {
+		for _, t := range tables {
+			xmlPath := stackoverflow.FindXMLFile(path, t.name+".xml")
+			if xmlPath == "" {
+				fmt.Fprintf(os.Stderr, "skipping %s, no .xml file in '%s'\n", t.name, path)
+				continue
+			}
+			files = append(files, file{xmlPath, t})
+		}
+	} else {
+		t := findTable(strings.ToLower(filepath.Base(path)))
+		if t == nil {
+			fmt.Fprintf(os.Stderr, "'%s' is not a recognized file name\n", path)
+			usageAndExit()
+		}
+		files = append(files, file{path, t})
+	}
+
+	var out io.Writer = os.Stdout
+	var f *os.File
+	if flgOut != "-" {
+		f, err = os.Create(u.ExpandTildeInPath(flgOut))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		out = f
+	}
+	err = writeSQL(bufio.NewWriterSize(out, 1024*1024), files)
+	if f != nil {
+		// error of writeSQL is more important than error of Close
+		if errClose := f.Close(); err == nil {
+			err = errClose
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	"github.com/kjk/stackoverflow/internal/u"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// FTS5 is not compiled into go-sqlite3 by default, build with:
+// go build -tags sqlite_fts5 ./cmd/tosqlite
+
+const timeFormatSQL = "2006-01-02 15:04:05.000"
+
+var (
+	flgOut string
+	flgFts bool
+)
+
+// hasFts5 returns true if sqlite was built with FTS5
+func hasFts5() bool {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return false
+	}
+	defer db.Close()
+	_, err = db.Exec("CREATE VIRTUAL TABLE fts_test USING fts5(a)")
+	return err == nil
+}
+
+func parseFlags() {
+	flag.StringVar(&flgOut, "out", "", "path of .sqlite file. Default is <directory>/so.sqlite")
+	flag.BoolVar(&flgFts, "fts", hasFts5(), "create full-text index of posts (requires building with -tags sqlite_fts5, on by default if built with it)")
+	flag.Parse()
+}
+
+func usageAndExit() {
+	fmt.Printf("usage: tosqlite [flags] <directory>\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+// helpers for values that are missing from .xml files, which are decoded
+// as zero values and stored as NULL
+
+func nullInt(v int) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(timeFormatSQL)
+}
+
+// encodeTags encodes tags the way they are in SEDE i.e. "<go><http>"
+func encodeTags(tags []string) interface{} {
+	if len(tags) == 0 {
+		return nil
+	}
+	return "<" + strings.Join(tags, "><") + ">"
+}
+
+// table describes how to load one .xml file into sql table
+type table struct {
+	// base name of .xml file e.g. "posthistory"
+	name      string
+	sqlName   string
+	newReader func(string) (*stackoverflow.Reader, error)
+	columns   []string
+	// values returns values of columns for current record of the reader
+	values func(r *stackoverflow.Reader) []interface{}
+	// extraSQL is an optional statement passed to extra
+	extraSQL string
+	// extra is optionally called for each record after inserting it
+	extra func(r *stackoverflow.Reader, stmt *sql.Stmt) error
+}
+
+// tagIDs maps tag name to its id. It's filled when loading Tags, which must
+// be loaded before Posts
+var tagIDs = map[string]int{}
+
+// number of post tags that are not in Tags table
+var nMissingTags int
+
+// tables in the order of loading
+var tables = []*table{
+	{
+		name:      "tags",
+		sqlName:   "Tags",
+		newReader: stackoverflow.NewTagsReaderFromFile,
+		columns:   []string{"Id", "TagName", "Count", "ExcerptPostId", "WikiPostId"},
+		values: func(r *stackoverflow.Reader) []interface{} {
+			t := &r.Tag
+			return []interface{}{t.ID, t.TagName, t.Count, nullInt(t.ExcerptPostID), nullInt(t.WikiPostID)}
+		},
+		extra: func(r *stackoverflow.Reader, stmt *sql.Stmt) error {
+			tagIDs[r.Tag.TagName] = r.Tag.ID
+			return nil
+		},
+	},
+	{
+		name:      "posts",
+		sqlName:   "Posts",
+		newReader: stackoverflow.NewPostsReaderFromFile,
+		columns:   []string{"Id", "PostTypeId", "AcceptedAnswerId", "ParentId", "CreationDate", "Score", "ViewCount", "Body", "OwnerUserId", "OwnerDisplayName", "LastEditorUserId", "LastEditorDisplayName", "LastEditDate", "LastActivityDate", "Title", "Tags", "AnswerCount", "CommentCount", "FavoriteCount", "ClosedDate", "CommunityOwnedDate"},
+		values: func(r *stackoverflow.Reader) []interface{} {
+			p := &r.Post
+			return []interface{}{p.ID, p.PostTypeID, nullInt(p.AcceptedAnswerID), nullInt(p.ParentID), nullTime(p.CreationDate), p.Score, nullInt(p.ViewCount), p.Body, nullInt(p.OwnerUserID), nullString(p.OwnerDisplayName), nullInt(p.LastEditorUserID), nullString(p.LastEditorDisplayName), nullTime(p.LastEditDate), nullTime(p.LastActivitityDate), nullString(p.Title), encodeTags(p.Tags), p.AnswerCount, p.CommentCount, p.FavoriteCount, nullTime(p.ClosedDate), nullTime(p.CommunityOwnedDate)}
+		},
+		extraSQL: `INSERT OR IGNORE INTO PostTags (PostId, TagId) VALUES (?, ?)`,
+		extra: func(r *stackoverflow.Reader, stmt *sql.Stmt) error {
+			for _, tag := range r.Post.Tags {
+				id, ok := tagIDs[tag]
+				if !ok {
+					nMissingTags++
+					continue
+				}
+				if _, err := stmt.Exec(r.Post.ID, id); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name:      "users",
+		sqlName:   "Users",
+		newReader: stackoverflow.NewUsersReaderFromFile,
+		columns:   []string{"Id", "Reputation", "CreationDate", "DisplayName", "LastAccessDate", "WebsiteUrl", "Location", "AboutMe", "Views", "UpVotes", "DownVotes", "ProfileImageUrl", "Age", "AccountId"},
+		values: func(r *stackoverflow.Reader) []interface{} {
+			u := &r.User
+			return []interface{}{u.ID, u.Reputation, nullTime(u.CreationDate), u.DisplayName, nullTime(u.LastAccessDate), nullString(u.WebsiteURL), nullString(u.Location), nullString(u.AboutMe), u.Views, u.UpVotes, u.DownVotes, nullString(u.ProfileImageURL), nullInt(u.Age), nullInt(u.AccountID)}
+		},
+	},
+	{
+		name:      "comments",
+		sqlName:   "Comments",
+		newReader: stackoverflow.NewCommentsReaderFromFile,
+		columns:   []string{"Id", "PostId", "Score", "Text", "CreationDate", "UserDisplayName", "UserId"},
+		values: func(r *stackoverflow.Reader) []interface{} {
+			c := &r.Comment
+			return []interface{}{c.ID, c.PostID, c.Score, c.Text, nullTime(c.CreationDate), nullString(c.UserDisplayName), nullInt(c.UserID)}
+		},
+	},
+	{
+		name:      "badges",
+		sqlName:   "Badges",
+		newReader: stackoverflow.NewBadgesReaderFromFile,
+		columns:   []string{"Id", "UserId", "Name", "Date"},
+		values: func(r *stackoverflow.Reader) []interface{} {
+			b := &r.Badge
+			return []interface{}{b.ID, nullInt(b.UserID), b.Name, nullTime(b.Date)}
+		},
+	},
+	{
+		name:      "posthistory",
+		sqlName:   "PostHistory",
+		newReader: stackoverflow.NewPostHistoryReaderFromFile,
+		columns:   []string{"Id", "PostHistoryTypeId", "PostId", "RevisionGUID", "CreationDate", "UserId", "UserDisplayName", "Comment", "Text"},
+		values: func(r *stackoverflow.Reader) []interface{} {
+			h := &r.PostHistory
+			return []interface{}{h.ID, h.PostHistoryTypeID, h.PostID, nullString(h.RevisionGUID), nullTime(h.CreationDate), nullInt(h.UserID), nullString(h.UserDisplayName), nullString(h.Comment), nullString(h.Text)}
+		},
+	},
+	{
+		name:      "postlinks",
+		sqlName:   "PostLinks",
+		newReader: stackoverflow.NewPostLinksReaderFromFile,
+		columns:   []string{"Id", "CreationDate", "PostId", "RelatedPostId", "LinkTypeId"},
+		values: func(r *stackoverflow.Reader) []interface{} {
+			l := &r.PostLink
+			return []interface{}{l.ID, nullTime(l.CreationDate), l.PostID, l.RelatedPostID, l.LinkTypeID}
+		},
+	},
+	{
+		name:      "votes",
+		sqlName:   "Votes",
+		newReader: stackoverflow.NewVotesReaderFromFile,
+		columns:   []string{"Id", "PostId", "VoteTypeId", "UserId", "CreationDate", "BountyAmount"},
+		values: func(r *stackoverflow.Reader) []interface{} {
+			v := &r.Vote
+			return []interface{}{v.ID, v.PostID, v.VoteTypeID, nullInt(v.UserID), nullTime(v.CreationDate), nullInt(v.BountyAmount)}
+		},
+	},
+}
+
+func insertSQL(t *table) string {
+	params := strings.Repeat("?, ", len(t.columns))
+	params = params[:len(params)-2]
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.sqlName, strings.Join(t.columns, ", "), params)
+}
+
+// loadTable inserts all records from .xml file in a single transaction
+func loadTable(db *sql.DB, path string, t *table) error {
+	timeStart := time.Now()
+	n := 0
+	r, err := t.newReader(path)
+	if err != nil {
+		return fmt.Errorf("loadTable: newReader() failed with %s\n", err)
+	}
+	defer r.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(insertSQL(t))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	var extraStmt *sql.Stmt
+	if t.extraSQL != "" {
+		extraStmt, err = tx.Prepare(t.extraSQL)
+		if err != nil {
+			return err
+		}
+		defer extraStmt.Close()
+	}
+	for r.Next() {
+		if _, err = stmt.Exec(t.values(r)...); err != nil {
+			return fmt.Errorf("inserting into %s failed with %s", t.sqlName, err)
+		}
+		if t.extra != nil {
+			if err = t.extra(r, extraStmt); err != nil {
+				return err
+			}
+		}
+		n++
+	}
+	if r.Err() != nil {
+		return r.Err()
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Printf("loaded %d %s in %s\n", n, t.name, time.Since(timeStart))
+	return nil
+}
+
+func insertNames(db *sql.DB, sqlName string, names map[int]string) error {
+	for id, name := range names {
+		_, err := db.Exec(fmt.Sprintf("INSERT INTO %s (Id, Name) VALUES (?, ?)", sqlName), id, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func execAll(db *sql.DB, stmts []string) error {
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			return fmt.Errorf("'%s' failed with %s", s, err)
+		}
+	}
+	return nil
+}
+
+func toSqlite(dir string, dbPath string) error {
+	if u.PathExists(dbPath) {
+		return fmt.Errorf("'%s' already exists", dbPath)
+	}
+	// check before spending time on loading tables
+	if flgFts && !hasFts5() {
+		return fmt.Errorf("full-text index needs FTS5, build with -tags sqlite_fts5 or use -fts=false")
+	}
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	// the database is created from scratch so we don't need to protect
+	// against crashes and can trade safety for speed
+	err = execAll(db, []string{"PRAGMA journal_mode = OFF", "PRAGMA synchronous = OFF", "PRAGMA cache_size = -262144"})
+	if err != nil {
+		return err
+	}
+	if err = execAll(db, createTablesSQL); err != nil {
+		return err
+	}
+	if err = insertNames(db, "PostTypes", postTypeNames); err != nil {
+		return err
+	}
+	if err = insertNames(db, "PostHistoryTypes", postHistoryTypeNames); err != nil {
+		return err
+	}
+	if err = insertNames(db, "VoteTypes", voteTypeNames); err != nil {
+		return err
+	}
+	for _, t := range tables {
+		path := stackoverflow.FindXMLFile(dir, t.name+".xml")
+		if path == "" {
+			fmt.Printf("skipping %s, no .xml file in '%s'\n", t.name, dir)
+			continue
+		}
+		if err = loadTable(db, path, t); err != nil {
+			return err
+		}
+	}
+	if nMissingTags > 0 {
+		fmt.Printf("%d post tags are not in Tags table and were not added to PostTags\n", nMissingTags)
+	}
+	timeStart := time.Now()
+	if err = execAll(db, createIndexesSQL); err != nil {
+		return err
+	}
+	fmt.Printf("created indexes in %s\n", time.Since(timeStart))
+	if flgFts {
+		timeStart = time.Now()
+		if err = execAll(db, createFtsSQL); err != nil {
+			return fmt.Errorf("creating full-text index failed: %s", err)
+		}
+		fmt.Printf("created full-text index in %s\n", time.Since(timeStart))
+	}
+	_, err = db.Exec("ANALYZE")
+	return err
+}
+
+func main() {
+	parseFlags()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	dir := u.ExpandTildeInPath(flag.Arg(0))
+	st, err := os.Stat(dir)
+	if err != nil || !st.IsDir() {
+		fmt.Printf("'%s' is not a directory\n", dir)
+		usageAndExit()
+	}
+	dbPath := filepath.Join(dir, "so.sqlite")
+	if flgOut != "" {
+		dbPath = u.ExpandTildeInPath(flgOut)
+	}
+	if err = toSqlite(dir, dbPath); err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+}
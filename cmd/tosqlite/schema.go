@@ -0,0 +1,203 @@
+package main
+
+// Tables and columns follow Stack Exchange Data Explorer schema
+// (https://data.stackexchange.com/stackoverflow/query/new) so that SEDE
+// queries run unchanged. Columns that are not in the dump (e.g.
+// Posts.DeletionDate, Badges.Class) are left out. Times are stored as
+// 'YYYY-MM-DD HH:MM:SS.SSS' text in UTC, which SQLite date functions understand
+
+var createTablesSQL = []string{
+	`CREATE TABLE Badges (
+	Id INTEGER PRIMARY KEY,
+	UserId INTEGER,
+	Name TEXT NOT NULL,
+	Date TEXT
+)`,
+	`CREATE TABLE Comments (
+	Id INTEGER PRIMARY KEY,
+	PostId INTEGER NOT NULL,
+	Score INTEGER NOT NULL DEFAULT 0,
+	Text TEXT,
+	CreationDate TEXT,
+	UserDisplayName TEXT,
+	UserId INTEGER
+)`,
+	`CREATE TABLE PostHistory (
+	Id INTEGER PRIMARY KEY,
+	PostHistoryTypeId INTEGER NOT NULL,
+	PostId INTEGER NOT NULL,
+	RevisionGUID TEXT,
+	CreationDate TEXT,
+	UserId INTEGER,
+	UserDisplayName TEXT,
+	Comment TEXT,
+	Text TEXT
+)`,
+	`CREATE TABLE PostLinks (
+	Id INTEGER PRIMARY KEY,
+	CreationDate TEXT,
+	PostId INTEGER NOT NULL,
+	RelatedPostId INTEGER NOT NULL,
+	LinkTypeId INTEGER NOT NULL
+)`,
+	`CREATE TABLE Posts (
+	Id INTEGER PRIMARY KEY,
+	PostTypeId INTEGER NOT NULL,
+	AcceptedAnswerId INTEGER,
+	ParentId INTEGER,
+	CreationDate TEXT,
+	Score INTEGER NOT NULL DEFAULT 0,
+	ViewCount INTEGER,
+	Body TEXT,
+	OwnerUserId INTEGER,
+	OwnerDisplayName TEXT,
+	LastEditorUserId INTEGER,
+	LastEditorDisplayName TEXT,
+	LastEditDate TEXT,
+	LastActivityDate TEXT,
+	Title TEXT,
+	Tags TEXT,
+	AnswerCount INTEGER,
+	CommentCount INTEGER,
+	FavoriteCount INTEGER,
+	ClosedDate TEXT,
+	CommunityOwnedDate TEXT
+)`,
+	`CREATE TABLE PostTags (
+	PostId INTEGER NOT NULL,
+	TagId INTEGER NOT NULL,
+	PRIMARY KEY (PostId, TagId)
+) WITHOUT ROWID`,
+	`CREATE TABLE Tags (
+	Id INTEGER PRIMARY KEY,
+	TagName TEXT NOT NULL,
+	Count INTEGER NOT NULL DEFAULT 0,
+	ExcerptPostId INTEGER,
+	WikiPostId INTEGER
+)`,
+	`CREATE TABLE Users (
+	Id INTEGER PRIMARY KEY,
+	Reputation INTEGER NOT NULL DEFAULT 0,
+	CreationDate TEXT,
+	DisplayName TEXT,
+	LastAccessDate TEXT,
+	WebsiteUrl TEXT,
+	Location TEXT,
+	AboutMe TEXT,
+	Views INTEGER NOT NULL DEFAULT 0,
+	UpVotes INTEGER NOT NULL DEFAULT 0,
+	DownVotes INTEGER NOT NULL DEFAULT 0,
+	ProfileImageUrl TEXT,
+	Age INTEGER,
+	AccountId INTEGER
+)`,
+	`CREATE TABLE Votes (
+	Id INTEGER PRIMARY KEY,
+	PostId INTEGER NOT NULL,
+	VoteTypeId INTEGER NOT NULL,
+	UserId INTEGER,
+	CreationDate TEXT,
+	BountyAmount INTEGER
+)`,
+	`CREATE TABLE PostTypes (
+	Id INTEGER PRIMARY KEY,
+	Name TEXT NOT NULL
+)`,
+	`CREATE TABLE PostHistoryTypes (
+	Id INTEGER PRIMARY KEY,
+	Name TEXT NOT NULL
+)`,
+	`CREATE TABLE VoteTypes (
+	Id INTEGER PRIMARY KEY,
+	Name TEXT NOT NULL
+)`,
+}
+
+// indexes are created after loading data, which is much faster than
+// updating them on every insert
+var createIndexesSQL = []string{
+	`CREATE INDEX Badges_UserId ON Badges (UserId)`,
+	`CREATE INDEX Comments_PostId ON Comments (PostId)`,
+	`CREATE INDEX Comments_UserId ON Comments (UserId)`,
+	`CREATE INDEX PostHistory_PostId ON PostHistory (PostId)`,
+	`CREATE INDEX PostHistory_UserId ON PostHistory (UserId)`,
+	`CREATE INDEX PostLinks_PostId ON PostLinks (PostId)`,
+	`CREATE INDEX PostLinks_RelatedPostId ON PostLinks (RelatedPostId)`,
+	`CREATE INDEX Posts_ParentId ON Posts (ParentId)`,
+	`CREATE INDEX Posts_OwnerUserId ON Posts (OwnerUserId)`,
+	`CREATE INDEX Posts_PostTypeId ON Posts (PostTypeId)`,
+	`CREATE INDEX PostTags_TagId ON PostTags (TagId)`,
+	`CREATE UNIQUE INDEX Tags_TagName ON Tags (TagName)`,
+	`CREATE INDEX Votes_PostId ON Votes (PostId)`,
+	`CREATE INDEX Votes_UserId ON Votes (UserId)`,
+}
+
+// PostsFts is an external content FTS5 table i.e. it only stores the
+// index and reads text from Posts. Use it like:
+// SELECT Id, Title FROM Posts WHERE Id IN (SELECT rowid FROM PostsFts WHERE PostsFts MATCH 'goroutine')
+var createFtsSQL = []string{
+	`CREATE VIRTUAL TABLE PostsFts USING fts5(Title, Body, content='Posts', content_rowid='Id')`,
+	`INSERT INTO PostsFts(PostsFts) VALUES('rebuild')`,
+}
+
+var postTypeNames = map[int]string{
+	1: "Question",
+	2: "Answer",
+	3: "Orphaned tag wiki",
+	4: "Tag wiki excerpt",
+	5: "Tag wiki",
+	6: "Moderator nomination",
+	7: "Wiki placeholder",
+	8: "Privilege wiki",
+}
+
+var postHistoryTypeNames = map[int]string{
+	1:  "Initial Title",
+	2:  "Initial Body",
+	3:  "Initial Tags",
+	4:  "Edit Title",
+	5:  "Edit Body",
+	6:  "Edit Tags",
+	7:  "Rollback Title",
+	8:  "Rollback Body",
+	9:  "Rollback Tags",
+	10: "Post Closed",
+	11: "Post Reopened",
+	12: "Post Deleted",
+	13: "Post Undeleted",
+	14: "Post Locked",
+	15: "Post Unlocked",
+	16: "Community Owned",
+	17: "Post Migrated",
+	18: "Question Merged",
+	19: "Question Protected",
+	20: "Question Unprotected",
+	21: "Post Disassociated",
+	22: "Question Unmerged",
+	24: "Suggested Edit Applied",
+	25: "Post Tweeted",
+	26: "Comment discussion moved to chat",
+	33: "Post notice added",
+	34: "Post notice removed",
+	35: "Post migrated away",
+	36: "Post migrated here",
+	37: "Post merge source",
+	38: "Post merge destination",
+}
+
+var voteTypeNames = map[int]string{
+	1:  "AcceptedByOriginator",
+	2:  "UpMod",
+	3:  "DownMod",
+	4:  "Offensive",
+	5:  "Favorite",
+	6:  "Close",
+	7:  "Reopen",
+	8:  "BountyStart",
+	9:  "BountyClose",
+	10: "Deletion",
+	11: "Undeletion",
+	12: "Spam",
+	15: "ModeratorReview",
+	16: "ApproveEditSuggestion",
+}
@@ -10,16 +10,16 @@ import (
 
 // Comment describes a comment
 type Comment struct {
-	ID              int
-	PostID          int
-	Score           int
-	Text            string
-	CreationDate    time.Time
-	UserID          int
-	UserDisplayName string
+	ID              int       `json:"comment_id"`
+	PostID          int       `json:"post_id"`
+	Score           int       `json:"score"`
+	Text            string    `json:"body"`
+	CreationDate    time.Time `json:"creation_date"`
+	UserID          int       `json:"user_id,omitempty"`
+	UserDisplayName string    `json:"user_display_name,omitempty"`
 }
 
-func decodeCommentAttr(attr xml.Attr, c *Comment) error {
+func decodeCommentAttr(attr xml.Attr, c *Comment, in *Interner) error {
 	var err error
 	name := strings.ToLower(attr.Name.Local)
 	v := attr.Value
@@ -37,22 +37,32 @@ func decodeCommentAttr(attr xml.Attr, c *Comment) error {
 	case "userid":
 		c.UserID, err = strconv.Atoi(v)
 	case "userdisplayname":
-		c.UserDisplayName = v
+		c.UserDisplayName = in.String(v)
 	default:
 		err = fmt.Errorf("unknown comment field: '%s'", name)
 	}
 	return err
 }
 
-func decodeCommentRow(t xml.Token, c *Comment) error {
+func decodeCommentRow(t xml.Token, c *Comment, in *Interner) error {
 	// have been checked before that this is "row" element
 	*c = Comment{}
 	e, _ := t.(xml.StartElement)
 	for _, attr := range e.Attr {
-		err := decodeCommentAttr(attr, c)
+		err := decodeCommentAttr(attr, c, in)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+func encodeCommentRow(rw *rowWriter, c *Comment) {
+	rw.int("Id", c.ID)
+	rw.int("PostId", c.PostID)
+	rw.int("Score", c.Score)
+	rw.str("Text", c.Text)
+	rw.timeOpt("CreationDate", c.CreationDate)
+	rw.intOpt("UserId", c.UserID)
+	rw.strOpt("UserDisplayName", c.UserDisplayName)
+}
@@ -0,0 +1,334 @@
+package stackoverflow
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Table is a bit set of tables to load into Dataset
+type Table int
+
+// tables that can be loaded into Dataset
+const (
+	TableBadges Table = 1 << iota
+	TableComments
+	TablePostHistory
+	TablePostLinks
+	TablePosts
+	TableTags
+	TableUsers
+	TableVotes
+
+	AllTables = TableBadges | TableComments | TablePostHistory | TablePostLinks | TablePosts | TableTags | TableUsers | TableVotes
+)
+
+// relation is a one-to-many relationship between records in two slices
+// e.g. a question and its answers. Children of parent at index i are
+// children[offsets[i]:offsets[i+1]]. It's more compact than a map of slices
+type relation struct {
+	offsets  []int32
+	children []int32
+}
+
+// buildRelation builds relation for n children. parentOf returns index of
+// the parent of i-th child or -1 if it doesn't have one
+func buildRelation(nParents int, n int, parentOf func(i int) int32) relation {
+	offsets := make([]int32, nParents+1)
+	for i := 0; i < n; i++ {
+		if p := parentOf(i); p >= 0 {
+			offsets[p+1]++
+		}
+	}
+	for i := 1; i <= nParents; i++ {
+		offsets[i] += offsets[i-1]
+	}
+	children := make([]int32, offsets[nParents])
+	pos := make([]int32, nParents)
+	copy(pos, offsets)
+	for i := 0; i < n; i++ {
+		if p := parentOf(i); p >= 0 {
+			children[pos[p]] = int32(i)
+			pos[p]++
+		}
+	}
+	return relation{offsets: offsets, children: children}
+}
+
+func (r *relation) get(parent int32) []int32 {
+	if parent < 0 || int(parent) >= len(r.offsets)-1 {
+		return nil
+	}
+	return r.children[r.offsets[parent]:r.offsets[parent+1]]
+}
+
+// Dataset holds records of a site in memory, with indexes for looking up
+// records by id and navigating relationships between them.
+// Records are stored by value in slices, in the order of .xml files.
+// Pointers returned by Dataset methods point into those slices.
+// Records are decoded with an Interner so Tags slices are shared and
+// must not be modified
+type Dataset struct {
+	Badges      []Badge
+	Comments    []Comment
+	PostHistory []PostHistory
+	PostLinks   []PostLink
+	Posts       []Post
+	Tags        []Tag
+	Users       []User
+	Votes       []Vote
+
+	// maps id to index in Posts and Users
+	postIdx map[int]int32
+	userIdx map[int]int32
+
+	answers     relation // question -> answers
+	comments    relation // post -> comments
+	postHistory relation // post -> history
+	postLinks   relation // post -> links
+	postLinksTo relation // post -> links to it
+	userPosts   relation // user -> posts
+	userBadges  relation // user -> badges
+}
+
+// LoadDataset loads selected tables from .xml files in dir
+func LoadDataset(dir string, tables Table) (*Dataset, error) {
+	return loadDataset(dir, tables, false)
+}
+
+// LoadDatasetCached is like LoadDataset but reads from binary caches of
+// .xml files, which is much faster. Caches are created on first use and
+// re-created when .xml files change (see OpenCached)
+func LoadDatasetCached(dir string, tables Table) (*Dataset, error) {
+	return loadDataset(dir, tables, true)
+}
+
+func loadDataset(dir string, tables Table, cached bool) (*Dataset, error) {
+	ds := &Dataset{}
+	// tags and names repeat a lot so we only keep one copy of each
+	in := NewInterner()
+	loaders := []struct {
+		table     Table
+		name      string
+		newReader func(string) (*Reader, error)
+		add       func(r *Reader)
+	}{
+		{TableBadges, "Badges.xml", NewBadgesReaderFromFile, func(r *Reader) { ds.Badges = append(ds.Badges, r.Badge) }},
+		{TableComments, "Comments.xml", NewCommentsReaderFromFile, func(r *Reader) { ds.Comments = append(ds.Comments, r.Comment) }},
+		{TablePostHistory, "PostHistory.xml", NewPostHistoryReaderFromFile, func(r *Reader) { ds.PostHistory = append(ds.PostHistory, r.PostHistory) }},
+		{TablePostLinks, "PostLinks.xml", NewPostLinksReaderFromFile, func(r *Reader) { ds.PostLinks = append(ds.PostLinks, r.PostLink) }},
+		{TablePosts, "Posts.xml", NewPostsReaderFromFile, func(r *Reader) { ds.Posts = append(ds.Posts, r.Post) }},
+		{TableTags, "Tags.xml", NewTagsReaderFromFile, func(r *Reader) { ds.Tags = append(ds.Tags, r.Tag) }},
+		{TableUsers, "Users.xml", NewUsersReaderFromFile, func(r *Reader) { ds.Users = append(ds.Users, r.User) }},
+		{TableVotes, "Votes.xml", NewVotesReaderFromFile, func(r *Reader) { ds.Votes = append(ds.Votes, r.Vote) }},
+	}
+	for _, l := range loaders {
+		if tables&l.table == 0 {
+			continue
+		}
+		path := FindXMLFile(dir, l.name)
+		if path == "" {
+			// for the error message of a missing file
+			path = filepath.Join(dir, l.name)
+		}
+		var r *Reader
+		var err error
+		if cached {
+			r, err = OpenCached(path)
+		} else {
+			r, err = l.newReader(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.SetInterner(in)
+		for r.Next() {
+			l.add(r)
+		}
+		r.Close()
+		if r.Err() != nil {
+			return nil, fmt.Errorf("LoadDataset: reading %s failed with %s", l.name, r.Err())
+		}
+	}
+	ds.buildIndexes()
+	return ds, nil
+}
+
+func (ds *Dataset) buildIndexes() {
+	ds.postIdx = make(map[int]int32, len(ds.Posts))
+	for i := range ds.Posts {
+		ds.postIdx[ds.Posts[i].ID] = int32(i)
+	}
+	ds.userIdx = make(map[int]int32, len(ds.Users))
+	for i := range ds.Users {
+		ds.userIdx[ds.Users[i].ID] = int32(i)
+	}
+	nPosts := len(ds.Posts)
+	ds.answers = buildRelation(nPosts, nPosts, func(i int) int32 {
+		p := &ds.Posts[i]
+		if p.PostTypeID != PostAnswer {
+			return -1
+		}
+		return ds.postIndex(p.ParentID)
+	})
+	ds.comments = buildRelation(nPosts, len(ds.Comments), func(i int) int32 {
+		return ds.postIndex(ds.Comments[i].PostID)
+	})
+	ds.postHistory = buildRelation(nPosts, len(ds.PostHistory), func(i int) int32 {
+		return ds.postIndex(ds.PostHistory[i].PostID)
+	})
+	ds.postLinks = buildRelation(nPosts, len(ds.PostLinks), func(i int) int32 {
+		return ds.postIndex(ds.PostLinks[i].PostID)
+	})
+	ds.postLinksTo = buildRelation(nPosts, len(ds.PostLinks), func(i int) int32 {
+		return ds.postIndex(ds.PostLinks[i].RelatedPostID)
+	})
+	nUsers := len(ds.Users)
+	ds.userPosts = buildRelation(nUsers, nPosts, func(i int) int32 {
+		return ds.userIndex(ds.Posts[i].OwnerUserID)
+	})
+	ds.userBadges = buildRelation(nUsers, len(ds.Badges), func(i int) int32 {
+		return ds.userIndex(ds.Badges[i].UserID)
+	})
+}
+
+func (ds *Dataset) postIndex(id int) int32 {
+	if idx, ok := ds.postIdx[id]; ok {
+		return idx
+	}
+	return -1
+}
+
+func (ds *Dataset) userIndex(id int) int32 {
+	if idx, ok := ds.userIdx[id]; ok {
+		return idx
+	}
+	return -1
+}
+
+// Post returns a post with a given id or nil if not found
+func (ds *Dataset) Post(id int) *Post {
+	if idx := ds.postIndex(id); idx >= 0 {
+		return &ds.Posts[idx]
+	}
+	return nil
+}
+
+// User returns a user with a given id or nil if not found
+func (ds *Dataset) User(id int) *User {
+	if idx := ds.userIndex(id); idx >= 0 {
+		return &ds.Users[idx]
+	}
+	return nil
+}
+
+// Owner returns the author of a post or nil if not known
+func (ds *Dataset) Owner(p *Post) *User {
+	return ds.User(p.OwnerUserID)
+}
+
+// AcceptedAnswer returns accepted answer of a question or nil
+func (ds *Dataset) AcceptedAnswer(q *Post) *Post {
+	if q.AcceptedAnswerID == 0 {
+		return nil
+	}
+	return ds.Post(q.AcceptedAnswerID)
+}
+
+// Question returns the question of an answer or nil
+func (ds *Dataset) Question(a *Post) *Post {
+	if a.PostTypeID != PostAnswer {
+		return nil
+	}
+	return ds.Post(a.ParentID)
+}
+
+// Answers returns answers to a question, in the order of Posts
+func (ds *Dataset) Answers(questionID int) []*Post {
+	idxs := ds.answers.get(ds.postIndex(questionID))
+	res := make([]*Post, len(idxs))
+	for i, idx := range idxs {
+		res[i] = &ds.Posts[idx]
+	}
+	return res
+}
+
+// PostComments returns comments to a post
+func (ds *Dataset) PostComments(postID int) []*Comment {
+	idxs := ds.comments.get(ds.postIndex(postID))
+	res := make([]*Comment, len(idxs))
+	for i, idx := range idxs {
+		res[i] = &ds.Comments[idx]
+	}
+	return res
+}
+
+// PostHistoryOf returns history of a post
+func (ds *Dataset) PostHistoryOf(postID int) []*PostHistory {
+	idxs := ds.postHistory.get(ds.postIndex(postID))
+	res := make([]*PostHistory, len(idxs))
+	for i, idx := range idxs {
+		res[i] = &ds.PostHistory[idx]
+	}
+	return res
+}
+
+// Revisions returns revisions of a post rebuilt from its history
+func (ds *Dataset) Revisions(postID int) []*Revision {
+	return Revisions(ds.PostHistoryOf(postID))
+}
+
+// PostLinksOf returns links from a post
+func (ds *Dataset) PostLinksOf(postID int) []*PostLink {
+	idxs := ds.postLinks.get(ds.postIndex(postID))
+	res := make([]*PostLink, len(idxs))
+	for i, idx := range idxs {
+		res[i] = &ds.PostLinks[idx]
+	}
+	return res
+}
+
+// PostLinksTo returns links to a post
+func (ds *Dataset) PostLinksTo(postID int) []*PostLink {
+	idxs := ds.postLinksTo.get(ds.postIndex(postID))
+	res := make([]*PostLink, len(idxs))
+	for i, idx := range idxs {
+		res[i] = &ds.PostLinks[idx]
+	}
+	return res
+}
+
+// Thread returns a question with its answers, comments, links and owners
+// or nil if there's no question with this id
+func (ds *Dataset) Thread(questionID int) *Thread {
+	q := ds.Post(questionID)
+	if q == nil || q.PostTypeID != PostQuestion {
+		return nil
+	}
+	answers := ds.Answers(questionID)
+	comments := ds.PostComments(questionID)
+	for _, a := range answers {
+		comments = append(comments, ds.PostComments(a.ID)...)
+	}
+	links := append(ds.PostLinksOf(questionID), ds.PostLinksTo(questionID)...)
+	return NewThread(q, answers, comments, links, ds.User)
+}
+
+// UserPosts returns posts (questions and answers) of a user
+func (ds *Dataset) UserPosts(userID int) []*Post {
+	idxs := ds.userPosts.get(ds.userIndex(userID))
+	res := make([]*Post, len(idxs))
+	for i, idx := range idxs {
+		res[i] = &ds.Posts[idx]
+	}
+	return res
+}
+
+// UserBadges returns badges of a user
+func (ds *Dataset) UserBadges(userID int) []*Badge {
+	idxs := ds.userBadges.get(ds.userIndex(userID))
+	res := make([]*Badge, len(idxs))
+	for i, idx := range idxs {
+		res[i] = &ds.Badges[idx]
+	}
+	return res
+}
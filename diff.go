@@ -0,0 +1,484 @@
+package stackoverflow
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DiffOpKind is a kind of DiffOp
+type DiffOpKind int
+
+// kinds of DiffOp
+const (
+	DiffEqual DiffOpKind = iota
+	DiffInsert
+	DiffDelete
+)
+
+func (k DiffOpKind) String() string {
+	switch k {
+	case DiffEqual:
+		return "equal"
+	case DiffInsert:
+		return "insert"
+	case DiffDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("DiffOpKind(%d)", int(k))
+}
+
+// MarshalText marshals kind as a string in JSON
+func (k DiffOpKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// DiffOp is a part of the text that is the same in both versions,
+// inserted in new version or deleted from old version
+type DiffOp struct {
+	Kind DiffOpKind `json:"kind"`
+	Text string     `json:"text"`
+	// number of lines or words (not counting spaces) in Text
+	Count int `json:"count"`
+}
+
+// diffTokens returns the shortest edit script turning a into b, as a list
+// of kinds for every token of a and b, using Myers' algorithm. In each run
+// of changed tokens, deleted tokens are before inserted tokens
+func diffTokens(a, b []string) []DiffOpKind {
+	max := (len(a) + len(b) + 1) / 2
+	vf := make([]int, 2*max+3)
+	vb := make([]int, 2*max+3)
+	res := diffRange(nil, a, b, vf, vb)
+	// group deletes and inserts of each change
+	for i := 0; i < len(res); {
+		if res[i] == DiffEqual {
+			i++
+			continue
+		}
+		j, nDel := i, 0
+		for ; j < len(res) && res[j] != DiffEqual; j++ {
+			if res[j] == DiffDelete {
+				nDel++
+			}
+		}
+		for ; i < j; i++ {
+			res[i] = DiffInsert
+			if nDel > 0 {
+				res[i] = DiffDelete
+				nDel--
+			}
+		}
+	}
+	return res
+}
+
+// diffRange appends the edit script of a and b to res. It splits the
+// problem at the middle snake of the shortest edit script and recurses on
+// both halves, so memory is linear in length of a and b instead of
+// proportional to the number of edits times the length. vf and vb are
+// scratch space for middleSnake, large enough for the whole input
+func diffRange(res []DiffOpKind, a, b []string, vf, vb []int) []DiffOpKind {
+	// common prefix and suffix don't need the expensive part
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	for i := 0; i < pre; i++ {
+		res = append(res, DiffEqual)
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+	switch {
+	case len(a) == 0:
+		for range b {
+			res = append(res, DiffInsert)
+		}
+	case len(b) == 0:
+		for range a {
+			res = append(res, DiffDelete)
+		}
+	default:
+		// without common prefix and suffix there are at least 2 edits, so
+		// both halves are smaller problems
+		x, y, u, v := middleSnake(a, b, vf, vb)
+		res = diffRange(res, a[:x], b[:y], vf, vb)
+		for i := x; i < u; i++ {
+			res = append(res, DiffEqual)
+		}
+		res = diffRange(res, a[u:], b[v:], vf, vb)
+	}
+	for i := 0; i < suf; i++ {
+		res = append(res, DiffEqual)
+	}
+	return res
+}
+
+// middleSnake returns the middle snake, from (x, y) to (u, v), of the
+// shortest edit script of a and b, searching from the start and from the
+// end at the same time until the paths overlap. vf[off+k] is the furthest
+// x on diagonal k = x-y reached from the start and vb[off+k] the furthest
+// number of tokens from the end on diagonal k = (n-x)-(m-y) reached from
+// the end
+func middleSnake(a, b []string, vf, vb []int) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	max := (n + m + 1) / 2
+	off := max + 1
+	delta := n - m
+	odd := delta%2 != 0
+	vf[off+1] = 0
+	vb[off+1] = 0
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[u] == b[v] {
+				u++
+				v++
+			}
+			vf[off+k] = u
+			// diagonal k from the end has k = delta-k from the start
+			if kb := delta - k; odd && kb >= -(d-1) && kb <= d-1 && u+vb[off+kb] >= n {
+				return x, y, u, v
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			var xb int
+			if k == -d || (k != d && vb[off+k-1] < vb[off+k+1]) {
+				xb = vb[off+k+1]
+			} else {
+				xb = vb[off+k-1] + 1
+			}
+			yb := xb - k
+			xb0, yb0 := xb, yb
+			for xb < n && yb < m && a[n-1-xb] == b[m-1-yb] {
+				xb++
+				yb++
+			}
+			vb[off+k] = xb
+			if kf := delta - k; !odd && kf >= -d && kf <= d && vf[off+kf]+xb >= n {
+				return n - xb, m - yb, n - xb0, m - yb0
+			}
+		}
+	}
+	panic("middleSnake: paths don't overlap")
+}
+
+// diffOps diffs tokens and merges consecutive tokens of the same kind.
+// isUnit tells if a token counts towards DiffOp.Count
+func diffOps(a, b []string, isUnit func(s string) bool) []DiffOp {
+	var res []DiffOp
+	add := func(kind DiffOpKind, tok string) {
+		if len(res) == 0 || res[len(res)-1].Kind != kind {
+			res = append(res, DiffOp{Kind: kind})
+		}
+		op := &res[len(res)-1]
+		op.Text += tok
+		if isUnit(tok) {
+			op.Count++
+		}
+	}
+	i, j := 0, 0
+	for _, kind := range diffTokens(a, b) {
+		switch kind {
+		case DiffEqual:
+			add(kind, a[i])
+			i++
+			j++
+		case DiffDelete:
+			add(kind, a[i])
+			i++
+		case DiffInsert:
+			add(kind, b[j])
+			j++
+		}
+	}
+	return res
+}
+
+// splitLines splits s into lines, keeping '\n' at the end of lines
+func splitLines(s string) []string {
+	var res []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			res = append(res, s)
+			break
+		}
+		res = append(res, s[:i+1])
+		s = s[i+1:]
+	}
+	return res
+}
+
+func tokenClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 1
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 2
+	}
+	return 0
+}
+
+// splitWords splits s into words, runs of spaces, html tags and single
+// punctuation characters. Joined tokens are s
+func splitWords(s string) []string {
+	var res []string
+	for len(s) > 0 {
+		if s[0] == '<' {
+			if i := strings.IndexByte(s, '>'); i > 0 && !strings.ContainsAny(s[1:i], "<\n") {
+				res = append(res, s[:i+1])
+				s = s[i+1:]
+				continue
+			}
+		}
+		r, n := utf8.DecodeRuneInString(s)
+		class := tokenClass(r)
+		if class != 0 {
+			for n < len(s) {
+				r2, n2 := utf8.DecodeRuneInString(s[n:])
+				if tokenClass(r2) != class {
+					break
+				}
+				n += n2
+			}
+		}
+		res = append(res, s[:n])
+		s = s[n:]
+	}
+	return res
+}
+
+func isWord(s string) bool {
+	return strings.TrimSpace(s) != ""
+}
+
+func isLine(s string) bool {
+	return true
+}
+
+// DiffLines returns line-level diff of a and b
+func DiffLines(a, b string) []DiffOp {
+	return diffOps(splitLines(a), splitLines(b), isLine)
+}
+
+// DiffWords returns word-level diff of a and b. Html tags are words
+func DiffWords(a, b string) []DiffOp {
+	return diffOps(splitWords(a), splitWords(b), isWord)
+}
+
+// DiffStats summarizes a diff in lines or words
+type DiffStats struct {
+	Equal    int `json:"equal"`
+	Inserted int `json:"inserted"`
+	Deleted  int `json:"deleted"`
+}
+
+// GetDiffStats returns stats of a diff
+func GetDiffStats(ops []DiffOp) DiffStats {
+	var res DiffStats
+	for _, op := range ops {
+		switch op.Kind {
+		case DiffEqual:
+			res.Equal += op.Count
+		case DiffInsert:
+			res.Inserted += op.Count
+		case DiffDelete:
+			res.Deleted += op.Count
+		}
+	}
+	return res
+}
+
+// ChangeRatio returns how much was changed, from 0 (nothing) to 1
+// (everything was replaced)
+func (s DiffStats) ChangeRatio() float64 {
+	total := 2*s.Equal + s.Inserted + s.Deleted
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Inserted+s.Deleted) / float64(total)
+}
+
+// DiffHTML returns diff as html with inserted text in <ins> and deleted
+// text in <del>, like revisions page on the site. Text is escaped
+func DiffHTML(ops []DiffOp) string {
+	var sb strings.Builder
+	for _, op := range ops {
+		s := html.EscapeString(op.Text)
+		switch op.Kind {
+		case DiffEqual:
+			sb.WriteString(s)
+		case DiffInsert:
+			sb.WriteString("<ins>" + s + "</ins>")
+		case DiffDelete:
+			sb.WriteString("<del>" + s + "</del>")
+		}
+	}
+	return sb.String()
+}
+
+// UnifiedDiff returns line-level diff of a and b in unified diff format
+// with a given number of lines of context, like diff -u
+func UnifiedDiff(a, b string, nameA, nameB string, context int) string {
+	linesA, linesB := splitLines(a), splitLines(b)
+	kinds := diffTokens(linesA, linesB)
+	type line struct {
+		kind DiffOpKind
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for _, k := range kinds {
+		switch k {
+		case DiffEqual:
+			lines = append(lines, line{k, linesA[i]})
+			i++
+			j++
+		case DiffDelete:
+			lines = append(lines, line{k, linesA[i]})
+			i++
+		case DiffInsert:
+			lines = append(lines, line{k, linesB[j]})
+			j++
+		}
+	}
+
+	var sb strings.Builder
+	// line numbers (0-based) in a and b of lines[pos]
+	posA, posB := 0, 0
+	for pos := 0; pos < len(lines); {
+		if lines[pos].kind == DiffEqual {
+			pos++
+			posA++
+			posB++
+			continue
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		// hunk starts with context before the change and ends when there
+		// are more than 2*context equal lines
+		start := pos - context
+		if start < 0 {
+			start = 0
+		}
+		startA := posA - (pos - start)
+		startB := posB - (pos - start)
+		end := pos
+		for end < len(lines) {
+			if lines[end].kind != DiffEqual {
+				end++
+				continue
+			}
+			nEqual := 0
+			for end+nEqual < len(lines) && lines[end+nEqual].kind == DiffEqual {
+				nEqual++
+			}
+			if end+nEqual == len(lines) || nEqual > 2*context {
+				if nEqual > context {
+					nEqual = context
+				}
+				end += nEqual
+				break
+			}
+			end += nEqual
+		}
+		nA, nB := 0, 0
+		for _, l := range lines[start:end] {
+			if l.kind != DiffInsert {
+				nA++
+			}
+			if l.kind != DiffDelete {
+				nB++
+			}
+		}
+		hunkA, hunkB := startA+1, startB+1
+		if nA == 0 {
+			hunkA--
+		}
+		if nB == 0 {
+			hunkB--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", hunkA, nA, hunkB, nB)
+		for _, l := range lines[start:end] {
+			prefix := " "
+			if l.kind == DiffInsert {
+				prefix = "+"
+			} else if l.kind == DiffDelete {
+				prefix = "-"
+			}
+			sb.WriteString(prefix + l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		for _, l := range lines[pos:end] {
+			if l.kind != DiffInsert {
+				posA++
+			}
+			if l.kind != DiffDelete {
+				posB++
+			}
+		}
+		pos = end
+	}
+	return sb.String()
+}
+
+// RevisionDiff is a difference between two revisions of a post
+type RevisionDiff struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	// word-level diff of titles
+	Title []DiffOp `json:"title,omitempty"`
+	// line-level or word-level diff of bodies
+	Body        []DiffOp `json:"body,omitempty"`
+	TagsAdded   []string `json:"tags_added,omitempty"`
+	TagsRemoved []string `json:"tags_removed,omitempty"`
+}
+
+// DiffRevisions returns a diff between revisions from and to of the same
+// post. Bodies are diffed by words if byWords is true, by lines otherwise
+func DiffRevisions(from, to *Revision, byWords bool) *RevisionDiff {
+	res := &RevisionDiff{
+		From:  from.Number,
+		To:    to.Number,
+		Title: DiffWords(from.Title, to.Title),
+	}
+	if byWords {
+		res.Body = DiffWords(from.Body, to.Body)
+	} else {
+		res.Body = DiffLines(from.Body, to.Body)
+	}
+	has := func(tags []string, tag string) bool {
+		for _, t := range tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+	for _, tag := range to.Tags {
+		if !has(from.Tags, tag) {
+			res.TagsAdded = append(res.TagsAdded, tag)
+		}
+	}
+	for _, tag := range from.Tags {
+		if !has(to.Tags, tag) {
+			res.TagsRemoved = append(res.TagsRemoved, tag)
+		}
+	}
+	return res
+}
@@ -0,0 +1,128 @@
+package stackoverflow
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// joinDiff returns old (a) or new (b) text from a diff
+func joinDiff(ops []DiffOp, skip DiffOpKind) string {
+	var sb strings.Builder
+	for _, op := range ops {
+		if op.Kind != skip {
+			sb.WriteString(op.Text)
+		}
+	}
+	return sb.String()
+}
+
+func checkRoundTrip(t *testing.T, a, b string) {
+	for _, diff := range []func(a, b string) []DiffOp{DiffLines, DiffWords} {
+		ops := diff(a, b)
+		if s := joinDiff(ops, DiffInsert); s != a {
+			t.Fatalf("equal and deleted text of diff of %q and %q is %q", a, b, s)
+		}
+		if s := joinDiff(ops, DiffDelete); s != b {
+			t.Fatalf("equal and inserted text of diff of %q and %q is %q", a, b, s)
+		}
+		for i := 1; i < len(ops); i++ {
+			if ops[i].Kind == ops[i-1].Kind {
+				t.Fatalf("diff of %q and %q has consecutive ops of the same kind", a, b)
+			}
+		}
+	}
+}
+
+func randomText(rnd *rand.Rand, nWords int, nDistinct int) string {
+	var sb strings.Builder
+	seps := []string{" ", " ", " ", "\n", ", ", ". "}
+	for i := 0; i < nWords; i++ {
+		fmt.Fprintf(&sb, "w%d%s", rnd.Intn(nDistinct), seps[rnd.Intn(len(seps))])
+	}
+	return sb.String()
+}
+
+func TestDiffRoundTrip(t *testing.T) {
+	texts := []string{
+		"",
+		"a",
+		"a\n",
+		"hello world",
+		"hello, <b>big</b> world\n",
+		"<p>first line</p>\n<p>second line</p>\n",
+		"<p>first line</p>\n<p>2nd line</p>\nthird\n",
+	}
+	for _, a := range texts {
+		for _, b := range texts {
+			checkRoundTrip(t, a, b)
+		}
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		checkRoundTrip(t, randomText(rnd, rnd.Intn(30), 1+i%8), randomText(rnd, rnd.Intn(30), 1+i%8))
+	}
+	// complete rewrite of a large text
+	checkRoundTrip(t, randomText(rnd, 3000, 100000), randomText(rnd, 3000, 100000))
+}
+
+func TestDiffIsShortest(t *testing.T) {
+	ops := DiffWords("the quick brown fox jumps", "the slow brown fox walks")
+	stats := GetDiffStats(ops)
+	exp := DiffStats{Equal: 3, Inserted: 2, Deleted: 2}
+	if stats != exp {
+		t.Fatalf("got %+v, expected %+v", stats, exp)
+	}
+	// deleted words are before inserted words
+	if ops[1].Kind != DiffDelete || ops[1].Text != "quick" || ops[2].Kind != DiffInsert || ops[2].Text != "slow" {
+		t.Fatalf("unexpected diff %+v", ops)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		context int
+		exp     string
+	}{
+		{
+			a:       "same\n",
+			b:       "same\n",
+			context: 3,
+			exp:     "",
+		},
+		{
+			a:       "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n",
+			b:       "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve",
+			context: 2,
+			exp: `--- a
++++ b
+@@ -1,4 +1,4 @@
+ one
+-two
++2
+ three
+ four
+@@ -10,2 +10,3 @@
+ ten
+ eleven
++twelve
+\ No newline at end of file
+`,
+		},
+		{
+			a:       "package main\n\nfunc main() {\n\tx := 1\n\ty := 2\n\tprintln(x + y)\n}\n",
+			b:       "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tx, y := 1, 2\n\tfmt.Println(x + y)\n}\n",
+			context: 1,
+			exp: "--- a\n+++ b\n@@ -2,6 +2,7 @@\n \n+import \"fmt\"\n+\n func main() {\n" +
+				"-\tx := 1\n-\ty := 2\n-\tprintln(x + y)\n+\tx, y := 1, 2\n+\tfmt.Println(x + y)\n }\n",
+		},
+	}
+	for _, test := range tests {
+		got := UnifiedDiff(test.a, test.b, "a", "b", test.context)
+		if got != test.exp {
+			t.Fatalf("diff of %q and %q is:\n%s\nexpected:\n%s", test.a, test.b, got, test.exp)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/kjk/stackoverflow
+
+go 1.16
+
+require (
+	// the last version before Go Arrow moved to versioned module paths
+	// (github.com/apache/arrow/go/v7 and later)
+	github.com/apache/arrow/go/arrow v0.0.0-20211112161151-bc219186db40
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/xitongsys/parquet-go v1.6.2
+	go.etcd.io/bbolt v1.3.5
+)
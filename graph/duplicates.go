@@ -0,0 +1,281 @@
+// Package graph analyzes graphs of links between posts, built from
+// PostLinks.xml, and of tags used together on questions.
+package graph
+
+import (
+	"sort"
+
+	"github.com/kjk/stackoverflow"
+)
+
+// DuplicateGraph is a graph of questions closed as duplicates. There's an
+// edge from a duplicate to the question it duplicates
+type DuplicateGraph struct {
+	// duplicate -> questions it duplicates
+	targets map[int][]int
+	// number of questions marked as duplicates of a question
+	inDegree map[int]int
+	// union-find of clusters
+	parent map[int]int
+
+	clusters  []*Cluster
+	clusterOf map[int]*Cluster
+}
+
+// Cluster is a set of questions connected by duplicate links
+type Cluster struct {
+	// the most linked question in the cluster that is not a duplicate of
+	// anything (the most linked question if all are). It's the same for
+	// all questions in the cluster, unlike DuplicateGraph.Canonical
+	Canonical int
+	// all questions in the cluster, sorted by id
+	Questions []int
+}
+
+// Size returns number of questions in the cluster
+func (c *Cluster) Size() int {
+	return len(c.Questions)
+}
+
+// NewDuplicateGraph returns an empty graph
+func NewDuplicateGraph() *DuplicateGraph {
+	return &DuplicateGraph{
+		targets:  map[int][]int{},
+		inDegree: map[int]int{},
+		parent:   map[int]int{},
+	}
+}
+
+// ReadDuplicateGraph builds a graph from a reader of PostLinks.xml
+func ReadDuplicateGraph(r *stackoverflow.Reader) (*DuplicateGraph, error) {
+	g := NewDuplicateGraph()
+	for r.Next() {
+		g.AddLink(&r.PostLink)
+	}
+	return g, r.Err()
+}
+
+func (g *DuplicateGraph) find(id int) int {
+	root := id
+	for {
+		p, ok := g.parent[root]
+		if !ok || p == root {
+			break
+		}
+		root = p
+	}
+	// path compression
+	for id != root {
+		next := g.parent[id]
+		g.parent[id] = root
+		id = next
+	}
+	return root
+}
+
+func (g *DuplicateGraph) union(a, b int) {
+	ra, rb := g.find(a), g.find(b)
+	if _, ok := g.parent[ra]; !ok {
+		g.parent[ra] = ra
+	}
+	if ra != rb {
+		g.parent[rb] = ra
+	}
+}
+
+// AddLink adds a link to the graph. Links that are not duplicate links
+// are ignored
+func (g *DuplicateGraph) AddLink(l *stackoverflow.PostLink) {
+	if l.LinkTypeID != stackoverflow.LinkTypeDuplicate || l.PostID == l.RelatedPostID {
+		return
+	}
+	for _, id := range g.targets[l.PostID] {
+		if id == l.RelatedPostID {
+			return
+		}
+	}
+	g.targets[l.PostID] = append(g.targets[l.PostID], l.RelatedPostID)
+	g.inDegree[l.RelatedPostID]++
+	g.union(l.PostID, l.RelatedPostID)
+	// clusters must be re-built
+	g.clusters = nil
+	g.clusterOf = nil
+}
+
+// IsDuplicate returns true if a question is marked as a duplicate
+func (g *DuplicateGraph) IsDuplicate(id int) bool {
+	return len(g.targets[id]) > 0
+}
+
+// Targets returns questions a question was marked as a duplicate of
+func (g *DuplicateGraph) Targets(id int) []int {
+	return g.targets[id]
+}
+
+// InDegree returns number of questions marked as duplicates of a question
+func (g *DuplicateGraph) InDegree(id int) int {
+	return g.inDegree[id]
+}
+
+// moreLinked returns true if a should be preferred over b as canonical
+func (g *DuplicateGraph) moreLinked(a, b int) bool {
+	da, db := g.inDegree[a], g.inDegree[b]
+	if da != db {
+		return da > db
+	}
+	// older question
+	return a < b
+}
+
+// Canonical returns canonical question for a question. It follows
+// duplicate links, choosing the most linked target if a question is
+// a duplicate of many, until a question that is not a duplicate. In case
+// of a cycle, the most linked question in the cycle is canonical. Questions
+// that are not duplicates are their own canonical.
+//
+// It can differ from Canonical of question's cluster: a cluster can have
+// many questions that are not duplicates (e.g. when a question is
+// a duplicate of 2 questions), and Canonical returns the one reached from
+// id, not the most linked one in the whole cluster
+func (g *DuplicateGraph) Canonical(id int) int {
+	visited := map[int]bool{}
+	var path []int
+	for {
+		targets := g.targets[id]
+		if len(targets) == 0 {
+			return id
+		}
+		if visited[id] {
+			// cycle is the part of the path starting at id
+			best := id
+			for i := len(path) - 1; i >= 0 && path[i] != id; i-- {
+				if g.moreLinked(path[i], best) {
+					best = path[i]
+				}
+			}
+			return best
+		}
+		visited[id] = true
+		path = append(path, id)
+		next := targets[0]
+		for _, t := range targets[1:] {
+			if g.moreLinked(t, next) {
+				next = t
+			}
+		}
+		id = next
+	}
+}
+
+func (g *DuplicateGraph) buildClusters() {
+	byRoot := map[int]*Cluster{}
+	g.clusterOf = map[int]*Cluster{}
+	for id := range g.parent {
+		root := g.find(id)
+		c := byRoot[root]
+		if c == nil {
+			c = &Cluster{}
+			byRoot[root] = c
+			g.clusters = append(g.clusters, c)
+		}
+		c.Questions = append(c.Questions, id)
+		g.clusterOf[id] = c
+	}
+	for _, c := range g.clusters {
+		sort.Ints(c.Questions)
+		// questions that are not duplicates of anything are candidates.
+		// If there are none (a cycle), all are
+		var best, bestAny int
+		hasBest := false
+		for i, id := range c.Questions {
+			if i == 0 || g.moreLinked(id, bestAny) {
+				bestAny = id
+			}
+			if g.IsDuplicate(id) {
+				continue
+			}
+			if !hasBest || g.moreLinked(id, best) {
+				best = id
+				hasBest = true
+			}
+		}
+		if !hasBest {
+			best = bestAny
+		}
+		c.Canonical = best
+	}
+	sort.Slice(g.clusters, func(i, j int) bool {
+		a, b := g.clusters[i], g.clusters[j]
+		if len(a.Questions) != len(b.Questions) {
+			return len(a.Questions) > len(b.Questions)
+		}
+		return a.Canonical < b.Canonical
+	})
+}
+
+// Clusters returns all clusters, largest first
+func (g *DuplicateGraph) Clusters() []*Cluster {
+	if g.clusters == nil {
+		g.buildClusters()
+	}
+	return g.clusters
+}
+
+// ClusterOf returns a cluster of a question or nil if it has no duplicate
+// links
+func (g *DuplicateGraph) ClusterOf(id int) *Cluster {
+	if g.clusterOf == nil {
+		g.buildClusters()
+	}
+	return g.clusterOf[id]
+}
+
+// TagStats is statistics of duplicate clusters of questions with a tag
+type TagStats struct {
+	Tag string
+	// number of clusters whose canonical question has the tag
+	Clusters int
+	// number of questions in those clusters, other than canonical
+	Duplicates int
+	// size of the largest cluster
+	MaxSize int
+}
+
+// AvgSize returns average number of questions in a cluster
+func (s *TagStats) AvgSize() float64 {
+	if s.Clusters == 0 {
+		return 0
+	}
+	return float64(s.Duplicates+s.Clusters) / float64(s.Clusters)
+}
+
+// TagStats returns statistics of clusters for each tag, sorted by number
+// of duplicates. A cluster counts for tags of its canonical question
+// (Cluster.Canonical, not DuplicateGraph.Canonical of its questions).
+// tags returns tags of a question, e.g. Dataset.Post(id).Tags
+func (g *DuplicateGraph) TagStats(tags func(id int) []string) []*TagStats {
+	byTag := map[string]*TagStats{}
+	var res []*TagStats
+	for _, c := range g.Clusters() {
+		for _, tag := range tags(c.Canonical) {
+			s := byTag[tag]
+			if s == nil {
+				s = &TagStats{Tag: tag}
+				byTag[tag] = s
+				res = append(res, s)
+			}
+			s.Clusters++
+			s.Duplicates += len(c.Questions) - 1
+			if len(c.Questions) > s.MaxSize {
+				s.MaxSize = len(c.Questions)
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Duplicates != res[j].Duplicates {
+			return res[i].Duplicates > res[j].Duplicates
+		}
+		return res[i].Tag < res[j].Tag
+	})
+	return res
+}
@@ -0,0 +1,325 @@
+package graph
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kjk/stackoverflow"
+)
+
+// matches links to questions and answers, e.g.
+// https://stackoverflow.com/questions/123/title, /q/123, /a/456/789
+var rxPostLink = regexp.MustCompile(`(?i)(?:https?:)?(?://(?:www\.)?([a-z0-9.-]+))?/(?:questions|q|a)/(\d+)`)
+
+// ExtractPostLinks returns ids of posts linked from html body of a post.
+// If site is not empty (e.g. "stackoverflow.com"), only links to that site
+// and relative links are returned
+func ExtractPostLinks(body string, site string) []int {
+	var res []int
+	for _, m := range rxPostLink.FindAllStringSubmatch(body, -1) {
+		if site != "" && m[1] != "" && !strings.EqualFold(m[1], site) {
+			continue
+		}
+		id, err := strconv.Atoi(m[2])
+		if err != nil || id <= 0 {
+			continue
+		}
+		res = append(res, id)
+	}
+	return res
+}
+
+// LinkGraph is a directed graph of links between posts. To keep memory use
+// low, posts are numbered densely and edges are stored as arrays of int32.
+// After Build, a link takes 8 bytes and a node about 50 bytes, most of it
+// in the map from post id to node index. Before Build, links are kept as
+// a list of pairs, 8 bytes per link plus spare capacity
+type LinkGraph struct {
+	// NodeOf maps a post id to id of a node. It can be used to e.g. merge
+	// answers into their questions. If nil, each post is a node
+	NodeOf func(postID int) int
+
+	nodeIdx map[int]int32
+	ids     []int
+	// edges before Build, from and to node index. Released by Build
+	edges []int64
+
+	// after Build, edges in compressed sparse row format, by source
+	// and by destination
+	outOffsets []int32
+	outEdges   []int32
+	inOffsets  []int32
+	inEdges    []int32
+}
+
+// NodeScore is centrality of a node
+type NodeScore struct {
+	ID        int
+	InDegree  int
+	OutDegree int
+	PageRank  float64
+	Hub       float64
+	Authority float64
+}
+
+// NewLinkGraph returns an empty graph
+func NewLinkGraph() *LinkGraph {
+	return &LinkGraph{
+		nodeIdx: map[int]int32{},
+	}
+}
+
+func (g *LinkGraph) node(postID int) int32 {
+	id := postID
+	if g.NodeOf != nil {
+		id = g.NodeOf(postID)
+	}
+	idx, ok := g.nodeIdx[id]
+	if !ok {
+		idx = int32(len(g.ids))
+		g.nodeIdx[id] = idx
+		g.ids = append(g.ids, id)
+	}
+	return idx
+}
+
+// unbuild restores the list of edges from compressed sparse rows, for
+// adding links after Build
+func (g *LinkGraph) unbuild() {
+	if g.outOffsets == nil {
+		return
+	}
+	g.edges = make([]int64, 0, len(g.outEdges))
+	for from := 0; from+1 < len(g.outOffsets); from++ {
+		for _, to := range g.outEdges[g.outOffsets[from]:g.outOffsets[from+1]] {
+			g.edges = append(g.edges, int64(from)<<32|int64(to))
+		}
+	}
+	g.outOffsets, g.outEdges, g.inOffsets, g.inEdges = nil, nil, nil, nil
+}
+
+// AddEdge adds a link from post to post. Links of a node to itself are
+// ignored. Adding links after Build is slow because the list of links
+// has to be restored
+func (g *LinkGraph) AddEdge(fromPostID, toPostID int) {
+	g.unbuild()
+	from, to := g.node(fromPostID), g.node(toPostID)
+	if from == to {
+		return
+	}
+	g.edges = append(g.edges, int64(from)<<32|int64(to))
+}
+
+// AddLink adds a link from PostLinks.xml, of any type
+func (g *LinkGraph) AddLink(l *stackoverflow.PostLink) {
+	g.AddEdge(l.PostID, l.RelatedPostID)
+}
+
+// AddBodyLinks adds links from body of a post to other posts on site
+// (see ExtractPostLinks)
+func (g *LinkGraph) AddBodyLinks(p *stackoverflow.Post, site string) {
+	for _, id := range ExtractPostLinks(p.Body, site) {
+		g.AddEdge(p.ID, id)
+	}
+}
+
+func buildCSR(n int, edges []int64, from func(e int64) int32, to func(e int64) int32) ([]int32, []int32) {
+	offsets := make([]int32, n+1)
+	for _, e := range edges {
+		offsets[from(e)+1]++
+	}
+	for i := 1; i <= n; i++ {
+		offsets[i] += offsets[i-1]
+	}
+	res := make([]int32, len(edges))
+	pos := make([]int32, n)
+	copy(pos, offsets)
+	for _, e := range edges {
+		f := from(e)
+		res[pos[f]] = to(e)
+		pos[f]++
+	}
+	return offsets, res
+}
+
+// Build finalizes the graph after adding links. Multiple links between
+// the same posts count as one. It's called by methods that need it
+func (g *LinkGraph) Build() {
+	if g.outOffsets != nil {
+		return
+	}
+	sort.Slice(g.edges, func(i, j int) bool { return g.edges[i] < g.edges[j] })
+	n := 0
+	for i, e := range g.edges {
+		if i == 0 || e != g.edges[n-1] {
+			g.edges[n] = e
+			n++
+		}
+	}
+	g.edges = g.edges[:n]
+	src := func(e int64) int32 { return int32(e >> 32) }
+	dst := func(e int64) int32 { return int32(e & 0xffffffff) }
+	g.outOffsets, g.outEdges = buildCSR(len(g.ids), g.edges, src, dst)
+	g.inOffsets, g.inEdges = buildCSR(len(g.ids), g.edges, dst, src)
+	g.edges = nil
+}
+
+// NumNodes returns number of nodes
+func (g *LinkGraph) NumNodes() int {
+	return len(g.ids)
+}
+
+// NodeIDs returns ids of nodes, in the order of node indexes
+func (g *LinkGraph) NodeIDs() []int {
+	return g.ids
+}
+
+// NumEdges returns number of distinct links
+func (g *LinkGraph) NumEdges() int {
+	g.Build()
+	return len(g.outEdges)
+}
+
+// InDegree returns number of nodes linking to a node
+func (g *LinkGraph) InDegree(nodeID int) int {
+	g.Build()
+	idx, ok := g.nodeIdx[nodeID]
+	if !ok {
+		return 0
+	}
+	return int(g.inOffsets[idx+1] - g.inOffsets[idx])
+}
+
+// OutDegree returns number of nodes a node links to
+func (g *LinkGraph) OutDegree(nodeID int) int {
+	g.Build()
+	idx, ok := g.nodeIdx[nodeID]
+	if !ok {
+		return 0
+	}
+	return int(g.outOffsets[idx+1] - g.outOffsets[idx])
+}
+
+// PageRank returns PageRank of nodes, in the order of node indexes. Rank
+// of nodes without outgoing links is spread over all nodes. Sum of ranks
+// is 1. Stops after maxIter iterations or when ranks change less than tol
+func (g *LinkGraph) PageRank(damping float64, maxIter int, tol float64) []float64 {
+	g.Build()
+	n := len(g.ids)
+	if n == 0 {
+		return nil
+	}
+	rank := make([]float64, n)
+	next := make([]float64, n)
+	for i := range rank {
+		rank[i] = 1 / float64(n)
+	}
+	for iter := 0; iter < maxIter; iter++ {
+		dangling := 0.0
+		for i := 0; i < n; i++ {
+			if g.outOffsets[i+1] == g.outOffsets[i] {
+				dangling += rank[i]
+			}
+		}
+		base := (1-damping)/float64(n) + damping*dangling/float64(n)
+		diff := 0.0
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for _, from := range g.inEdges[g.inOffsets[i]:g.inOffsets[i+1]] {
+				sum += rank[from] / float64(g.outOffsets[from+1]-g.outOffsets[from])
+			}
+			next[i] = base + damping*sum
+			diff += math.Abs(next[i] - rank[i])
+		}
+		rank, next = next, rank
+		if diff < tol {
+			break
+		}
+	}
+	return rank
+}
+
+func normalize(v []float64) {
+	sum := 0.0
+	for _, x := range v {
+		sum += x * x
+	}
+	if sum == 0 {
+		return
+	}
+	norm := math.Sqrt(sum)
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// HITS returns hub and authority scores of nodes, in the order of node
+// indexes. Good hubs link to many good authorities (e.g. canonical
+// questions), good authorities are linked from many good hubs
+func (g *LinkGraph) HITS(maxIter int, tol float64) ([]float64, []float64) {
+	g.Build()
+	n := len(g.ids)
+	hubs := make([]float64, n)
+	auths := make([]float64, n)
+	for i := range hubs {
+		hubs[i] = 1
+	}
+	normalize(hubs)
+	prevAuths := make([]float64, n)
+	for iter := 0; iter < maxIter; iter++ {
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for _, from := range g.inEdges[g.inOffsets[i]:g.inOffsets[i+1]] {
+				sum += hubs[from]
+			}
+			auths[i] = sum
+		}
+		normalize(auths)
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for _, to := range g.outEdges[g.outOffsets[i]:g.outOffsets[i+1]] {
+				sum += auths[to]
+			}
+			hubs[i] = sum
+		}
+		normalize(hubs)
+		diff := 0.0
+		for i := range auths {
+			diff += math.Abs(auths[i] - prevAuths[i])
+		}
+		copy(prevAuths, auths)
+		if diff < tol {
+			break
+		}
+	}
+	return hubs, auths
+}
+
+// Scores returns degrees, PageRank (with damping 0.85) and HITS scores of
+// all nodes, sorted by PageRank
+func (g *LinkGraph) Scores() []NodeScore {
+	g.Build()
+	rank := g.PageRank(0.85, 100, 1e-10)
+	hubs, auths := g.HITS(100, 1e-10)
+	res := make([]NodeScore, len(g.ids))
+	for i, id := range g.ids {
+		res[i] = NodeScore{
+			ID:        id,
+			InDegree:  int(g.inOffsets[i+1] - g.inOffsets[i]),
+			OutDegree: int(g.outOffsets[i+1] - g.outOffsets[i]),
+			PageRank:  rank[i],
+			Hub:       hubs[i],
+			Authority: auths[i],
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].PageRank != res[j].PageRank {
+			return res[i].PageRank > res[j].PageRank
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
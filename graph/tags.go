@@ -0,0 +1,415 @@
+package graph
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/kjk/stackoverflow"
+)
+
+// TagGraph counts how often tags are used together on questions. It's a
+// sparse co-occurrence matrix, only pairs of tags used together are stored
+type TagGraph struct {
+	names  []string
+	idx    map[string]int32
+	counts []int
+	// number of questions with both tags, key is index of tags (smaller
+	// first) packed into uint64
+	pairs      map[uint64]int
+	nQuestions int
+
+	// neighbors of tags, built from pairs on demand
+	adj [][]tagEdge
+}
+
+type tagEdge struct {
+	to    int32
+	count int
+}
+
+// TagPair is co-occurrence of two tags
+type TagPair struct {
+	A, B string
+	// number of questions with both tags
+	Count   int
+	Jaccard float64
+	PMI     float64
+}
+
+// RelatedTag is a tag used together with another tag
+type RelatedTag struct {
+	Tag string
+	// number of questions with both tags
+	Count   int
+	Jaccard float64
+	PMI     float64
+}
+
+// TagCluster is a group of tags often used together
+type TagCluster struct {
+	// tags sorted by number of questions, most used first
+	Tags []string
+	// number of questions of all tags, a question counts once for
+	// each tag
+	Questions int
+}
+
+// NewTagGraph returns an empty graph
+func NewTagGraph() *TagGraph {
+	return &TagGraph{
+		idx:   map[string]int32{},
+		pairs: map[uint64]int{},
+	}
+}
+
+// ReadTagGraph builds a graph from questions in a reader of Posts.xml
+func ReadTagGraph(r *stackoverflow.Reader) (*TagGraph, error) {
+	g := NewTagGraph()
+	for r.Next() {
+		g.AddPost(&r.Post)
+	}
+	return g, r.Err()
+}
+
+func (g *TagGraph) tag(name string) int32 {
+	i, ok := g.idx[name]
+	if !ok {
+		i = int32(len(g.names))
+		g.idx[name] = i
+		g.names = append(g.names, name)
+		g.counts = append(g.counts, 0)
+	}
+	return i
+}
+
+func pairKey(a, b int32) uint64 {
+	if a > b {
+		a, b = b, a
+	}
+	return uint64(a)<<32 | uint64(b)
+}
+
+// AddTags adds tags of a single question. Repeated tags count once
+func (g *TagGraph) AddTags(tags []string) {
+	idxs := make([]int32, 0, len(tags))
+	for _, t := range tags {
+		i := g.tag(t)
+		dup := false
+		for _, j := range idxs {
+			if i == j {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			idxs = append(idxs, i)
+		}
+	}
+	for n, i := range idxs {
+		g.counts[i]++
+		for _, j := range idxs[n+1:] {
+			g.pairs[pairKey(i, j)]++
+		}
+	}
+	g.nQuestions++
+	g.adj = nil
+}
+
+// AddPost adds tags of a question. Other posts are ignored
+func (g *TagGraph) AddPost(p *stackoverflow.Post) {
+	if p.PostTypeID != stackoverflow.PostQuestion || len(p.Tags) == 0 {
+		return
+	}
+	g.AddTags(p.Tags)
+}
+
+// NumQuestions returns number of questions added
+func (g *TagGraph) NumQuestions() int {
+	return g.nQuestions
+}
+
+// NumTags returns number of distinct tags
+func (g *TagGraph) NumTags() int {
+	return len(g.names)
+}
+
+// NumPairs returns number of distinct pairs of tags used together
+func (g *TagGraph) NumPairs() int {
+	return len(g.pairs)
+}
+
+// Count returns number of questions with a tag
+func (g *TagGraph) Count(tag string) int {
+	i, ok := g.idx[tag]
+	if !ok {
+		return 0
+	}
+	return g.counts[i]
+}
+
+// CoOccurrence returns number of questions with both tags
+func (g *TagGraph) CoOccurrence(a, b string) int {
+	i, ok1 := g.idx[a]
+	j, ok2 := g.idx[b]
+	if !ok1 || !ok2 || i == j {
+		return 0
+	}
+	return g.pairs[pairKey(i, j)]
+}
+
+func (g *TagGraph) jaccard(i, j int32, count int) float64 {
+	union := g.counts[i] + g.counts[j] - count
+	if union == 0 {
+		return 0
+	}
+	return float64(count) / float64(union)
+}
+
+func (g *TagGraph) pmi(i, j int32, count int) float64 {
+	if count == 0 {
+		return math.Inf(-1)
+	}
+	n := float64(g.nQuestions)
+	return math.Log(float64(count) * n / (float64(g.counts[i]) * float64(g.counts[j])))
+}
+
+// Jaccard returns Jaccard similarity of tags: number of questions with
+// both tags divided by number of questions with either
+func (g *TagGraph) Jaccard(a, b string) float64 {
+	i, ok1 := g.idx[a]
+	j, ok2 := g.idx[b]
+	if !ok1 || !ok2 || i == j {
+		return 0
+	}
+	return g.jaccard(i, j, g.pairs[pairKey(i, j)])
+}
+
+// PMI returns pointwise mutual information of tags, log of how much more
+// often tags are used together than if they were independent. It's -Inf
+// if tags are never used together
+func (g *TagGraph) PMI(a, b string) float64 {
+	i, ok1 := g.idx[a]
+	j, ok2 := g.idx[b]
+	if !ok1 || !ok2 || i == j {
+		return math.Inf(-1)
+	}
+	return g.pmi(i, j, g.pairs[pairKey(i, j)])
+}
+
+func (g *TagGraph) build() {
+	if g.adj != nil {
+		return
+	}
+	g.adj = make([][]tagEdge, len(g.names))
+	for k, n := range g.pairs {
+		i, j := int32(k>>32), int32(k&0xffffffff)
+		g.adj[i] = append(g.adj[i], tagEdge{j, n})
+		g.adj[j] = append(g.adj[j], tagEdge{i, n})
+	}
+	for _, edges := range g.adj {
+		sort.Slice(edges, func(a, b int) bool { return edges[a].to < edges[b].to })
+	}
+}
+
+// Related returns up to n tags most often used together with a tag, like
+// "related tags" on the site, sorted by number of questions with both tags.
+// Pairs used together less than minCount times are skipped
+func (g *TagGraph) Related(tag string, n int, minCount int) []RelatedTag {
+	i, ok := g.idx[tag]
+	if !ok {
+		return nil
+	}
+	g.build()
+	var res []RelatedTag
+	for _, e := range g.adj[i] {
+		if e.count < minCount {
+			continue
+		}
+		res = append(res, RelatedTag{
+			Tag:     g.names[e.to],
+			Count:   e.count,
+			Jaccard: g.jaccard(i, e.to, e.count),
+			PMI:     g.pmi(i, e.to, e.count),
+		})
+	}
+	sort.Slice(res, func(a, b int) bool {
+		if res[a].Count != res[b].Count {
+			return res[a].Count > res[b].Count
+		}
+		return res[a].Tag < res[b].Tag
+	})
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// Pairs returns pairs of tags used together at least minCount times,
+// sorted by number of questions with both tags
+func (g *TagGraph) Pairs(minCount int) []TagPair {
+	var res []TagPair
+	for k, n := range g.pairs {
+		if n < minCount {
+			continue
+		}
+		i, j := int32(k>>32), int32(k&0xffffffff)
+		a, b := g.names[i], g.names[j]
+		if b < a {
+			a, b = b, a
+		}
+		res = append(res, TagPair{
+			A:       a,
+			B:       b,
+			Count:   n,
+			Jaccard: g.jaccard(i, j, n),
+			PMI:     g.pmi(i, j, n),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		if res[i].A != res[j].A {
+			return res[i].A < res[j].A
+		}
+		return res[i].B < res[j].B
+	})
+	return res
+}
+
+// WriteCSV writes pairs of tags used together at least minCount times as
+// .csv
+func (g *TagGraph) WriteCSV(w io.Writer, minCount int) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"TagA", "TagB", "Count", "CountA", "CountB", "Jaccard", "PMI"})
+	for _, p := range g.Pairs(minCount) {
+		cw.Write([]string{
+			p.A,
+			p.B,
+			strconv.Itoa(p.Count),
+			strconv.Itoa(g.Count(p.A)),
+			strconv.Itoa(g.Count(p.B)),
+			strconv.FormatFloat(p.Jaccard, 'g', 6, 64),
+			strconv.FormatFloat(p.PMI, 'g', 6, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteDOT writes the graph in Graphviz .dot format, with edges between
+// tags used together at least minCount times. Edges are weighted by Jaccard
+// similarity. Tags without edges are skipped
+func (g *TagGraph) WriteDOT(w io.Writer, minCount int) error {
+	pairs := g.Pairs(minCount)
+	used := map[string]bool{}
+	var tags []string
+	for _, p := range pairs {
+		for _, t := range []string{p.A, p.B} {
+			if !used[t] {
+				used[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.Strings(tags)
+	if _, err := fmt.Fprintf(w, "graph tags {\n"); err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if _, err := fmt.Fprintf(w, "  %q [count=%d];\n", t, g.Count(t)); err != nil {
+			return err
+		}
+	}
+	for _, p := range pairs {
+		if _, err := fmt.Fprintf(w, "  %q -- %q [weight=%.6f, count=%d];\n", p.A, p.B, p.Jaccard, p.Count); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "}\n")
+	return err
+}
+
+// Clusters groups tags into topics with label propagation: each tag takes
+// the label with the largest sum of Jaccard similarity among its
+// neighbors, until labels don't change or after maxIter iterations. Only
+// pairs used together at least minCount times are edges. Tags without
+// edges are not in any cluster. Clusters are sorted by size, largest first
+func (g *TagGraph) Clusters(minCount int, maxIter int) []*TagCluster {
+	g.build()
+	n := len(g.names)
+	labels := make([]int32, n)
+	for i := range labels {
+		labels[i] = int32(i)
+	}
+	// visit most used tags first so that they spread their labels
+	order := make([]int32, n)
+	for i := range order {
+		order[i] = int32(i)
+	}
+	sort.Slice(order, func(a, b int) bool {
+		ca, cb := g.counts[order[a]], g.counts[order[b]]
+		if ca != cb {
+			return ca > cb
+		}
+		return g.names[order[a]] < g.names[order[b]]
+	})
+	weights := map[int32]float64{}
+	for iter := 0; iter < maxIter; iter++ {
+		changed := false
+		for _, i := range order {
+			for k := range weights {
+				delete(weights, k)
+			}
+			for _, e := range g.adj[i] {
+				if e.count >= minCount {
+					weights[labels[e.to]] += g.jaccard(i, e.to, e.count)
+				}
+			}
+			best, bestWeight := labels[i], weights[labels[i]]
+			for l, w := range weights {
+				if w > bestWeight || (w == bestWeight && l < best) {
+					best, bestWeight = l, w
+				}
+			}
+			if best != labels[i] {
+				labels[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	byLabel := map[int32]*TagCluster{}
+	var res []*TagCluster
+	for _, i := range order {
+		hasEdge := false
+		for _, e := range g.adj[i] {
+			if e.count >= minCount {
+				hasEdge = true
+				break
+			}
+		}
+		if !hasEdge {
+			continue
+		}
+		c := byLabel[labels[i]]
+		if c == nil {
+			c = &TagCluster{}
+			byLabel[labels[i]] = c
+			res = append(res, c)
+		}
+		c.Tags = append(c.Tags, g.names[i])
+		c.Questions += g.counts[i]
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return len(res[i].Tags) > len(res[j].Tags)
+	})
+	return res
+}
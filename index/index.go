@@ -0,0 +1,445 @@
+// Package index stores records in an on-disk key-value store (bbolt) for
+// fast lookups by id, without scanning .xml files.
+//
+// Each table is a bucket keyed by record id. Records are stored as JSON.
+// Secondary indexes are buckets with keys made of parent id followed by
+// child id (e.g. question id + answer id) so that children of a parent are
+// found with a prefix scan.
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketBadges      = []byte("badges")
+	bucketComments    = []byte("comments")
+	bucketPostHistory = []byte("posthistory")
+	bucketPostLinks   = []byte("postlinks")
+	bucketPosts       = []byte("posts")
+	bucketTags        = []byte("tags")
+	bucketUsers       = []byte("users")
+	bucketVotes       = []byte("votes")
+
+	// secondary indexes
+	bucketAnswersByQuestion = []byte("answers_by_question")
+	bucketBadgesByUser      = []byte("badges_by_user")
+	bucketCommentsByPost    = []byte("comments_by_post")
+	bucketPostHistoryByPost = []byte("posthistory_by_post")
+	bucketPostLinksByPost   = []byte("postlinks_by_post")
+	bucketPostLinksToPost   = []byte("postlinks_to_post")
+	bucketVotesByPost       = []byte("votes_by_post")
+	bucketTagsByName        = []byte("tags_by_name")
+
+	allBuckets = [][]byte{
+		bucketBadges, bucketComments, bucketPostHistory, bucketPostLinks,
+		bucketPosts, bucketTags, bucketUsers, bucketVotes,
+		bucketAnswersByQuestion, bucketBadgesByUser, bucketCommentsByPost,
+		bucketPostHistoryByPost, bucketPostLinksByPost, bucketPostLinksToPost,
+		bucketVotesByPost, bucketTagsByName,
+	}
+)
+
+// number of records written in a single transaction when adding records
+const batchSize = 50000
+
+// ErrNotFound is returned when a record with a given id is not in the index
+var ErrNotFound = errors.New("index: not found")
+
+// ErrNeedsRebuild is returned when the index was built by an older version
+// (or isn't fully built) and is missing a bucket, e.g. a secondary index
+var ErrNeedsRebuild = errors.New("index: missing bucket, build the index again")
+
+// Index is an on-disk index of records
+type Index struct {
+	db *bolt.DB
+}
+
+// Create creates a new index file, or opens an existing one for adding
+// more records
+func Create(path string) (*Index, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	// the index can be re-created from .xml files so we trade safety
+	// for speed
+	db.NoSync = true
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Open opens an existing index file for reading
+func Open(path string) (*Index, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Close closes the index
+func (idx *Index) Close() error {
+	if idx.db.NoSync {
+		if err := idx.db.Sync(); err != nil {
+			idx.db.Close()
+			return err
+		}
+	}
+	return idx.db.Close()
+}
+
+// encodeID encodes id as 8 bytes that sort in the same order as ids,
+// including negative ids (e.g. -1 for Community user)
+func encodeID(id int) []byte {
+	var d [8]byte
+	binary.BigEndian.PutUint64(d[:], uint64(int64(id))^(1<<63))
+	return d[:]
+}
+
+func encodeChildKey(parentID, id int) []byte {
+	return append(encodeID(parentID), encodeID(id)...)
+}
+
+// secondaryKey is a key in secondary index bucket. Keys of indexes
+// by parent id have no value, tags by name have tag id as value
+type secondaryKey struct {
+	bucket []byte
+	key    []byte
+	value  []byte
+}
+
+// recordKeys returns bucket and id of a record, and its keys in
+// secondary indexes
+func recordKeys(rec interface{}) ([]byte, int, []secondaryKey) {
+	switch v := rec.(type) {
+	case *stackoverflow.Badge:
+		return bucketBadges, v.ID, []secondaryKey{{bucketBadgesByUser, encodeChildKey(v.UserID, v.ID), nil}}
+	case *stackoverflow.Comment:
+		return bucketComments, v.ID, []secondaryKey{{bucketCommentsByPost, encodeChildKey(v.PostID, v.ID), nil}}
+	case *stackoverflow.PostHistory:
+		return bucketPostHistory, v.ID, []secondaryKey{{bucketPostHistoryByPost, encodeChildKey(v.PostID, v.ID), nil}}
+	case *stackoverflow.PostLink:
+		return bucketPostLinks, v.ID, []secondaryKey{
+			{bucketPostLinksByPost, encodeChildKey(v.PostID, v.ID), nil},
+			{bucketPostLinksToPost, encodeChildKey(v.RelatedPostID, v.ID), nil},
+		}
+	case *stackoverflow.Post:
+		if v.PostTypeID == stackoverflow.PostAnswer && v.ParentID != 0 {
+			return bucketPosts, v.ID, []secondaryKey{{bucketAnswersByQuestion, encodeChildKey(v.ParentID, v.ID), nil}}
+		}
+		return bucketPosts, v.ID, nil
+	case *stackoverflow.Tag:
+		return bucketTags, v.ID, []secondaryKey{{bucketTagsByName, []byte(v.TagName), encodeID(v.ID)}}
+	case *stackoverflow.User:
+		return bucketUsers, v.ID, nil
+	case *stackoverflow.Vote:
+		return bucketVotes, v.ID, []secondaryKey{{bucketVotesByPost, encodeChildKey(v.PostID, v.ID), nil}}
+	}
+	return nil, 0, nil
+}
+
+func putRecord(tx *bolt.Tx, rec interface{}) error {
+	bucket, id, keys := recordKeys(rec)
+	d, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b := tx.Bucket(bucket)
+	// records in .xml files are sorted by id so buckets are appended to
+	// and can be filled fully
+	b.FillPercent = 0.9
+	key := encodeID(id)
+	if err = b.Put(key, d); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err = tx.Bucket(k.bucket).Put(k.key, k.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddFromReader adds all records from r. Returns number of added records
+func (idx *Index) AddFromReader(r *stackoverflow.Reader) (int, error) {
+	n := 0
+	for {
+		nInTx := 0
+		err := idx.db.Update(func(tx *bolt.Tx) error {
+			for nInTx < batchSize && r.Next() {
+				if err := putRecord(tx, r.Record()); err != nil {
+					return err
+				}
+				nInTx++
+			}
+			return nil
+		})
+		if err != nil {
+			return n, err
+		}
+		n += nInTx
+		if nInTx < batchSize {
+			break
+		}
+	}
+	return n, r.Err()
+}
+
+func (idx *Index) get(bucket []byte, id int, v interface{}) error {
+	return idx.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b == nil {
+			return ErrNeedsRebuild
+		}
+		d := b.Get(encodeID(id))
+		if d == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(d, v)
+	})
+}
+
+// children calls fn with data of all records in bucket whose ids are
+// in secondary index under parentID
+func (idx *Index) children(index []byte, parentID int, bucket []byte, fn func(d []byte) error) error {
+	return idx.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		bi := tx.Bucket(index)
+		if b == nil || bi == nil {
+			return ErrNeedsRebuild
+		}
+		prefix := encodeID(parentID)
+		c := bi.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			d := b.Get(k[len(prefix):])
+			if d == nil {
+				// the index has the record, the table doesn't
+				continue
+			}
+			if err := fn(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Post returns a post with a given id
+func (idx *Index) Post(id int) (*stackoverflow.Post, error) {
+	var res stackoverflow.Post
+	if err := idx.get(bucketPosts, id, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// User returns a user with a given id
+func (idx *Index) User(id int) (*stackoverflow.User, error) {
+	var res stackoverflow.User
+	if err := idx.get(bucketUsers, id, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Comment returns a comment with a given id
+func (idx *Index) Comment(id int) (*stackoverflow.Comment, error) {
+	var res stackoverflow.Comment
+	if err := idx.get(bucketComments, id, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// PostLink returns a post link with a given id
+func (idx *Index) PostLink(id int) (*stackoverflow.PostLink, error) {
+	var res stackoverflow.PostLink
+	if err := idx.get(bucketPostLinks, id, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Tag returns a tag with a given name
+func (idx *Index) Tag(name string) (*stackoverflow.Tag, error) {
+	var res stackoverflow.Tag
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketTags)
+		bi := tx.Bucket(bucketTagsByName)
+		if b == nil || bi == nil {
+			return ErrNeedsRebuild
+		}
+		id := bi.Get([]byte(name))
+		if id == nil {
+			return ErrNotFound
+		}
+		d := b.Get(id)
+		if d == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(d, &res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Answers returns answers to a question, sorted by id
+func (idx *Index) Answers(questionID int) ([]*stackoverflow.Post, error) {
+	var res []*stackoverflow.Post
+	err := idx.children(bucketAnswersByQuestion, questionID, bucketPosts, func(d []byte) error {
+		var p stackoverflow.Post
+		res = append(res, &p)
+		return json.Unmarshal(d, &p)
+	})
+	return res, err
+}
+
+// Comments returns comments to a post, sorted by id
+func (idx *Index) Comments(postID int) ([]*stackoverflow.Comment, error) {
+	var res []*stackoverflow.Comment
+	err := idx.children(bucketCommentsByPost, postID, bucketComments, func(d []byte) error {
+		var c stackoverflow.Comment
+		res = append(res, &c)
+		return json.Unmarshal(d, &c)
+	})
+	return res, err
+}
+
+// Votes returns votes for a post, sorted by id
+func (idx *Index) Votes(postID int) ([]*stackoverflow.Vote, error) {
+	var res []*stackoverflow.Vote
+	err := idx.children(bucketVotesByPost, postID, bucketVotes, func(d []byte) error {
+		var v stackoverflow.Vote
+		res = append(res, &v)
+		return json.Unmarshal(d, &v)
+	})
+	return res, err
+}
+
+// Badges returns badges of a user, sorted by id
+func (idx *Index) Badges(userID int) ([]*stackoverflow.Badge, error) {
+	var res []*stackoverflow.Badge
+	err := idx.children(bucketBadgesByUser, userID, bucketBadges, func(d []byte) error {
+		var b stackoverflow.Badge
+		res = append(res, &b)
+		return json.Unmarshal(d, &b)
+	})
+	return res, err
+}
+
+// PostHistory returns history of a post, sorted by id
+func (idx *Index) PostHistory(postID int) ([]*stackoverflow.PostHistory, error) {
+	var res []*stackoverflow.PostHistory
+	err := idx.children(bucketPostHistoryByPost, postID, bucketPostHistory, func(d []byte) error {
+		var h stackoverflow.PostHistory
+		res = append(res, &h)
+		return json.Unmarshal(d, &h)
+	})
+	return res, err
+}
+
+// PostLinks returns links from a post, sorted by id
+func (idx *Index) PostLinks(postID int) ([]*stackoverflow.PostLink, error) {
+	var res []*stackoverflow.PostLink
+	err := idx.children(bucketPostLinksByPost, postID, bucketPostLinks, func(d []byte) error {
+		var l stackoverflow.PostLink
+		res = append(res, &l)
+		return json.Unmarshal(d, &l)
+	})
+	return res, err
+}
+
+// PostLinksTo returns links to a post, sorted by id
+func (idx *Index) PostLinksTo(postID int) ([]*stackoverflow.PostLink, error) {
+	var res []*stackoverflow.PostLink
+	err := idx.children(bucketPostLinksToPost, postID, bucketPostLinks, func(d []byte) error {
+		var l stackoverflow.PostLink
+		res = append(res, &l)
+		return json.Unmarshal(d, &l)
+	})
+	return res, err
+}
+
+// Thread returns a question with its answers, comments, links and owners
+func (idx *Index) Thread(questionID int) (*stackoverflow.Thread, error) {
+	q, err := idx.Post(questionID)
+	if err != nil {
+		return nil, err
+	}
+	if q.PostTypeID != stackoverflow.PostQuestion {
+		return nil, ErrNotFound
+	}
+	answers, err := idx.Answers(questionID)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := idx.Comments(questionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range answers {
+		c, err := idx.Comments(a.ID)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c...)
+	}
+	links, err := idx.PostLinks(questionID)
+	if err != nil {
+		return nil, err
+	}
+	linksTo, err := idx.PostLinksTo(questionID)
+	if err != nil {
+		return nil, err
+	}
+	links = append(links, linksTo...)
+	users := map[int]*stackoverflow.User{}
+	var userErr error
+	user := func(id int) *stackoverflow.User {
+		if u, ok := users[id]; ok {
+			return u
+		}
+		// missing users (e.g. deleted) are not an error
+		u, err := idx.User(id)
+		if err != nil && err != ErrNotFound && userErr == nil {
+			userErr = err
+		}
+		users[id] = u
+		return u
+	}
+	t := stackoverflow.NewThread(q, answers, comments, links, user)
+	if userErr != nil {
+		return nil, userErr
+	}
+	return t, nil
+}
+
+// Revisions returns revisions of a post rebuilt from its history
+func (idx *Index) Revisions(postID int) ([]*stackoverflow.Revision, error) {
+	history, err := idx.PostHistory(postID)
+	if err != nil {
+		return nil, err
+	}
+	return stackoverflow.Revisions(history), nil
+}
@@ -0,0 +1,26 @@
+// Package u has small file system helpers shared by commands.
+package u
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandTildeInPath replaces ~ at the start of path with home directory
+func ExpandTildeInPath(s string) string {
+	if strings.HasPrefix(s, "~") {
+		h, err := os.UserHomeDir()
+		if err != nil {
+			return s
+		}
+		return filepath.Join(h, s[1:])
+	}
+	return s
+}
+
+// PathExists returns true if a file or directory exists
+func PathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,69 @@
+package stackoverflow
+
+import "sync"
+
+// Interner de-duplicates strings that repeat a lot in dumps (tags, display
+// names, badge names) so that identical values share memory. It's set on
+// a Reader with SetInterner and can be shared by many readers, also
+// from multiple goroutines.
+//
+// Records decoded with an Interner share Tags slices: posts with the same
+// tags have the same slice, so it must not be modified in place
+type Interner struct {
+	mu      sync.Mutex
+	strings map[string]string
+	tags    map[string][]string
+}
+
+// NewInterner returns a new Interner
+func NewInterner() *Interner {
+	return &Interner{
+		strings: map[string]string{},
+		tags:    map[string][]string{},
+	}
+}
+
+// String returns a shared copy of s
+func (in *Interner) String(s string) string {
+	if in == nil || s == "" {
+		return s
+	}
+	in.mu.Lock()
+	res, ok := in.strings[s]
+	if !ok {
+		in.strings[s] = s
+		res = s
+	}
+	in.mu.Unlock()
+	return res
+}
+
+// Tags decodes tags in the format of .xml files (<foo><bar>) and returns a
+// shared slice for the same tags
+func (in *Interner) Tags(s string) []string {
+	if in == nil {
+		return decodeTags(s)
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if tags, ok := in.tags[s]; ok {
+		return tags
+	}
+	tags := decodeTags(s)
+	for i, tag := range tags {
+		if shared, ok := in.strings[tag]; ok {
+			tags[i] = shared
+		} else {
+			in.strings[tag] = tag
+		}
+	}
+	in.tags[s] = tags
+	return tags
+}
+
+// Len returns number of unique strings and tag lists
+func (in *Interner) Len() (int, int) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return len(in.strings), len(in.tags)
+}
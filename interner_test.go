@@ -0,0 +1,81 @@
+package stackoverflow
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// benchPostsPath returns Posts.xml to load in benchmarks: $SO_POSTS_XML
+// or a generated file
+func benchPostsPath(b *testing.B) string {
+	if path := os.Getenv("SO_POSTS_XML"); path != "" {
+		return path
+	}
+	path := filepath.Join(b.TempDir(), "Posts.xml")
+	writeTestXML(b, path, testPostRows())
+	return path
+}
+
+// loadPosts reads all posts into a slice, like a program keeping a whole
+// table in memory would
+func loadPosts(b *testing.B, path string, in *Interner) []Post {
+	r, err := NewPostsReaderFromFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	r.SetInterner(in)
+	var posts []Post
+	for r.Next() {
+		posts = append(posts, r.Post)
+	}
+	r.Close()
+	if r.Err() != nil {
+		b.Fatal(r.Err())
+	}
+	return posts
+}
+
+func heapAlloc() int64 {
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	return int64(ms.HeapAlloc)
+}
+
+func benchmarkLoadPosts(b *testing.B, intern bool) {
+	path := benchPostsPath(b)
+	newInterner := func() *Interner {
+		if intern {
+			return NewInterner()
+		}
+		return nil
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		loadPosts(b, path, newInterner())
+	}
+	b.StopTimer()
+
+	// memory still used by loaded posts and the interner, which is kept
+	// alive e.g. by Dataset. It can be negative if GC freed more than the
+	// posts use
+	before := heapAlloc()
+	in := newInterner()
+	posts := loadPosts(b, path, in)
+	retained := heapAlloc() - before
+	runtime.KeepAlive(posts)
+	runtime.KeepAlive(in)
+	b.ReportMetric(float64(retained), "retained-B")
+	b.ReportMetric(float64(len(posts)), "posts")
+}
+
+func BenchmarkLoadPosts(b *testing.B) {
+	benchmarkLoadPosts(b, false)
+}
+
+func BenchmarkLoadPostsInterned(b *testing.B) {
+	benchmarkLoadPosts(b, true)
+}
@@ -0,0 +1,107 @@
+package stackoverflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// encoding/json doesn't omit zero time.Time with omitempty. Missing times
+// are decoded as zero time so MarshalJSON methods below omit them. Each
+// method marshals an alias type (which doesn't have MarshalJSON method)
+// with time fields shadowed by pointers
+
+// marshalJSON is like json.Marshal but doesn't escape <, > and & which
+// are common in post bodies. Callers that want them escaped (e.g.
+// json.Marshal) escape them anyway
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func optTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// MarshalJSON marshals a badge, omitting missing date
+func (b Badge) MarshalJSON() ([]byte, error) {
+	type badge Badge
+	return marshalJSON(struct {
+		badge
+		Date *time.Time `json:"date,omitempty"`
+	}{badge(b), optTime(b.Date)})
+}
+
+// MarshalJSON marshals a comment, omitting missing date
+func (c Comment) MarshalJSON() ([]byte, error) {
+	type comment Comment
+	return marshalJSON(struct {
+		comment
+		CreationDate *time.Time `json:"creation_date,omitempty"`
+	}{comment(c), optTime(c.CreationDate)})
+}
+
+// MarshalJSON marshals a post history record, omitting missing date
+func (h PostHistory) MarshalJSON() ([]byte, error) {
+	type postHistory PostHistory
+	return marshalJSON(struct {
+		postHistory
+		CreationDate *time.Time `json:"creation_date,omitempty"`
+	}{postHistory(h), optTime(h.CreationDate)})
+}
+
+// MarshalJSON marshals a post link, omitting missing date
+func (l PostLink) MarshalJSON() ([]byte, error) {
+	type postLink PostLink
+	return marshalJSON(struct {
+		postLink
+		CreationDate *time.Time `json:"creation_date,omitempty"`
+	}{postLink(l), optTime(l.CreationDate)})
+}
+
+// MarshalJSON marshals a post, omitting missing dates
+func (p Post) MarshalJSON() ([]byte, error) {
+	type post Post
+	return marshalJSON(struct {
+		post
+		CreationDate       *time.Time `json:"creation_date,omitempty"`
+		LastEditDate       *time.Time `json:"last_edit_date,omitempty"`
+		LastActivityDate   *time.Time `json:"last_activity_date,omitempty"`
+		CommunityOwnedDate *time.Time `json:"community_owned_date,omitempty"`
+		ClosedDate         *time.Time `json:"closed_date,omitempty"`
+	}{
+		post(p),
+		optTime(p.CreationDate),
+		optTime(p.LastEditDate),
+		optTime(p.LastActivitityDate),
+		optTime(p.CommunityOwnedDate),
+		optTime(p.ClosedDate),
+	})
+}
+
+// MarshalJSON marshals a user, omitting missing dates
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	return marshalJSON(struct {
+		user
+		CreationDate   *time.Time `json:"creation_date,omitempty"`
+		LastAccessDate *time.Time `json:"last_access_date,omitempty"`
+	}{user(u), optTime(u.CreationDate), optTime(u.LastAccessDate)})
+}
+
+// MarshalJSON marshals a vote, omitting missing date
+func (v Vote) MarshalJSON() ([]byte, error) {
+	type vote Vote
+	return marshalJSON(struct {
+		vote
+		CreationDate *time.Time `json:"creation_date,omitempty"`
+	}{vote(v), optTime(v.CreationDate)})
+}
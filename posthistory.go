@@ -45,23 +45,23 @@ const (
 
 // PostHistory describes history of a post
 type PostHistory struct {
-	ID                int
-	PostHistoryTypeID int
-	PostID            int
-	RevisionGUID      string
-	CreationDate      time.Time
-	UserID            int
-	UserDisplayName   string
+	ID                int       `json:"post_history_id"`
+	PostHistoryTypeID int       `json:"post_history_type_id"`
+	PostID            int       `json:"post_id"`
+	RevisionGUID      string    `json:"revision_guid,omitempty"`
+	CreationDate      time.Time `json:"creation_date"`
+	UserID            int       `json:"user_id,omitempty"`
+	UserDisplayName   string    `json:"user_display_name,omitempty"`
 	// if PostHistoryTypeID is 10, 11, 12, 13, 14, 15, this is JSON
 	// with users who voted
-	Text string
+	Text string `json:"text,omitempty"`
 	// if PostHistoryTypeID is HistoryInitialTags or HistoyrEditTags
 	// or HistoryRollbackTags, this is a decoded version of tags
-	Tags    []string
-	Comment string
+	Tags    []string `json:"tags,omitempty"`
+	Comment string   `json:"comment,omitempty"`
 }
 
-func decodePostHistoryAttr(attr xml.Attr, h *PostHistory) error {
+func decodePostHistoryAttr(attr xml.Attr, h *PostHistory, in *Interner) error {
 	var err error
 	name := strings.ToLower(attr.Name.Local)
 	v := attr.Value
@@ -79,7 +79,7 @@ func decodePostHistoryAttr(attr xml.Attr, h *PostHistory) error {
 	case "userid":
 		h.UserID, err = strconv.Atoi(v)
 	case "userdisplayname":
-		h.UserDisplayName = v
+		h.UserDisplayName = in.String(v)
 	case "text":
 		h.Text = v
 	case "comment":
@@ -89,13 +89,13 @@ func decodePostHistoryAttr(attr xml.Attr, h *PostHistory) error {
 	}
 	return err
 }
-func decodePostHistoryRow(t xml.Token, h *PostHistory) error {
+func decodePostHistoryRow(t xml.Token, h *PostHistory, in *Interner) error {
 	// have been checked before that this is "row" element
 
 	*h = PostHistory{}
 	e, _ := t.(xml.StartElement)
 	for _, attr := range e.Attr {
-		err := decodePostHistoryAttr(attr, h)
+		err := decodePostHistoryAttr(attr, h, in)
 		if err != nil {
 			return err
 		}
@@ -103,8 +103,20 @@ func decodePostHistoryRow(t xml.Token, h *PostHistory) error {
 	switch h.PostHistoryTypeID {
 	case HistoryInitialTags, HistoyrEditTags, HistoryRollbackTags:
 		if h.Text != "" {
-			h.Tags = decodeTags(h.Text)
+			h.Tags = in.Tags(h.Text)
 		}
 	}
 	return nil
 }
+
+func encodePostHistoryRow(rw *rowWriter, h *PostHistory) {
+	rw.int("Id", h.ID)
+	rw.int("PostHistoryTypeId", h.PostHistoryTypeID)
+	rw.int("PostId", h.PostID)
+	rw.strOpt("RevisionGUID", h.RevisionGUID)
+	rw.timeOpt("CreationDate", h.CreationDate)
+	rw.intOpt("UserId", h.UserID)
+	rw.strOpt("UserDisplayName", h.UserDisplayName)
+	rw.strOpt("Comment", h.Comment)
+	rw.strOpt("Text", h.Text)
+}
@@ -16,11 +16,11 @@ const (
 
 // PostLink describes links in a post
 type PostLink struct {
-	ID            int
-	CreationDate  time.Time
-	PostID        int
-	RelatedPostID int
-	LinkTypeID    int
+	ID            int       `json:"post_link_id"`
+	CreationDate  time.Time `json:"creation_date"`
+	PostID        int       `json:"post_id"`
+	RelatedPostID int       `json:"related_post_id"`
+	LinkTypeID    int       `json:"link_type_id"`
 }
 
 func decodePostLinkAttr(attr xml.Attr, l *PostLink) error {
@@ -56,3 +56,11 @@ func decodePostLinkRow(t xml.Token, l *PostLink) error {
 	}
 	return nil
 }
+
+func encodePostLinkRow(rw *rowWriter, l *PostLink) {
+	rw.int("Id", l.ID)
+	rw.timeOpt("CreationDate", l.CreationDate)
+	rw.int("PostId", l.PostID)
+	rw.int("RelatedPostId", l.RelatedPostID)
+	rw.int("LinkTypeId", l.LinkTypeID)
+}
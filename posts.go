@@ -23,27 +23,27 @@ const (
 
 // Post describes a post
 type Post struct {
-	ID                    int
-	PostTypeID            int
-	ParentID              int // for PostAnswer
-	AcceptedAnswerID      int
-	CreationDate          time.Time
-	Score                 int
-	ViewCount             int
-	Body                  string
-	OwnerUserID           int
-	OwnerDisplayName      string
-	LastEditorUserID      int
-	LastEditorDisplayName string
-	LastEditDate          time.Time
-	LastActivitityDate    time.Time
-	Title                 string
-	Tags                  []string
-	AnswerCount           int
-	CommentCount          int
-	FavoriteCount         int
-	CommunityOwnedDate    time.Time
-	ClosedDate            time.Time
+	ID                    int       `json:"post_id"`
+	PostTypeID            int       `json:"post_type_id"`
+	ParentID              int       `json:"parent_id,omitempty"` // for PostAnswer
+	AcceptedAnswerID      int       `json:"accepted_answer_id,omitempty"`
+	CreationDate          time.Time `json:"creation_date"`
+	Score                 int       `json:"score"`
+	ViewCount             int       `json:"view_count,omitempty"`
+	Body                  string    `json:"body"`
+	OwnerUserID           int       `json:"owner_user_id,omitempty"`
+	OwnerDisplayName      string    `json:"owner_display_name,omitempty"`
+	LastEditorUserID      int       `json:"last_editor_user_id,omitempty"`
+	LastEditorDisplayName string    `json:"last_editor_display_name,omitempty"`
+	LastEditDate          time.Time `json:"last_edit_date,omitempty"`
+	LastActivitityDate    time.Time `json:"last_activity_date,omitempty"`
+	Title                 string    `json:"title,omitempty"`
+	Tags                  []string  `json:"tags,omitempty"`
+	AnswerCount           int       `json:"answer_count"`
+	CommentCount          int       `json:"comment_count"`
+	FavoriteCount         int       `json:"favorite_count,omitempty"`
+	CommunityOwnedDate    time.Time `json:"community_owned_date,omitempty"`
+	ClosedDate            time.Time `json:"closed_date,omitempty"`
 }
 
 var nTagsToShow = 0
@@ -60,7 +60,7 @@ func decodeTags(s string) []string {
 	return tags
 }
 
-func decodePostAttr(attr xml.Attr, p *Post) error {
+func decodePostAttr(attr xml.Attr, p *Post, in *Interner) error {
 	var err error
 	name := strings.ToLower(attr.Name.Local)
 	v := attr.Value
@@ -84,11 +84,11 @@ func decodePostAttr(attr xml.Attr, p *Post) error {
 	case "owneruserid":
 		p.OwnerUserID, err = strconv.Atoi(v)
 	case "ownerdisplayname":
-		p.OwnerDisplayName = v
+		p.OwnerDisplayName = in.String(v)
 	case "lasteditoruserid":
 		p.LastEditorUserID, err = strconv.Atoi(v)
 	case "lasteditordisplayname":
-		p.LastEditorDisplayName = v
+		p.LastEditorDisplayName = in.String(v)
 	case "lasteditdate":
 		p.LastEditDate, err = decodeTime(v)
 	case "lastactivitydate":
@@ -96,7 +96,7 @@ func decodePostAttr(attr xml.Attr, p *Post) error {
 	case "title":
 		p.Title = v
 	case "tags":
-		p.Tags = decodeTags(v)
+		p.Tags = in.Tags(v)
 	case "answercount":
 		p.AnswerCount, err = strconv.Atoi(v)
 	case "commentcount":
@@ -119,12 +119,12 @@ func validatePost(p *Post) {
 	}
 }
 
-func decodePostRow(t xml.Token, p *Post) error {
+func decodePostRow(t xml.Token, p *Post, in *Interner) error {
 	// have been checked before that this is "row" element
 	*p = Post{}
 	e, _ := t.(xml.StartElement)
 	for _, attr := range e.Attr {
-		err := decodePostAttr(attr, p)
+		err := decodePostAttr(attr, p, in)
 		if err != nil {
 			return err
 		}
@@ -132,3 +132,27 @@ func decodePostRow(t xml.Token, p *Post) error {
 	validatePost(p)
 	return nil
 }
+
+func encodePostRow(rw *rowWriter, p *Post) {
+	rw.int("Id", p.ID)
+	rw.int("PostTypeId", p.PostTypeID)
+	rw.intOpt("ParentId", p.ParentID)
+	rw.intOpt("AcceptedAnswerId", p.AcceptedAnswerID)
+	rw.timeOpt("CreationDate", p.CreationDate)
+	rw.int("Score", p.Score)
+	rw.intOpt("ViewCount", p.ViewCount)
+	rw.str("Body", p.Body)
+	rw.intOpt("OwnerUserId", p.OwnerUserID)
+	rw.strOpt("OwnerDisplayName", p.OwnerDisplayName)
+	rw.intOpt("LastEditorUserId", p.LastEditorUserID)
+	rw.strOpt("LastEditorDisplayName", p.LastEditorDisplayName)
+	rw.timeOpt("LastEditDate", p.LastEditDate)
+	rw.timeOpt("LastActivityDate", p.LastActivitityDate)
+	rw.strOpt("Title", p.Title)
+	rw.strOpt("Tags", encodeTags(p.Tags))
+	rw.intOpt("AnswerCount", p.AnswerCount)
+	rw.intOpt("CommentCount", p.CommentCount)
+	rw.intOpt("FavoriteCount", p.FavoriteCount)
+	rw.timeOpt("CommunityOwnedDate", p.CommunityOwnedDate)
+	rw.timeOpt("ClosedDate", p.ClosedDate)
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -38,6 +40,9 @@ type Reader struct {
 	Vote        Vote
 	err         error
 	finished    bool
+	// set when reading from cache, see OpenCached
+	cache    *cacheReader
+	interner *Interner
 }
 
 // NewBadgesReaderFromFile returns a new reader for Badges.xml file
@@ -80,6 +85,23 @@ func NewVotesReaderFromFile(path string) (*Reader, error) {
 	return newReaderFromFile(path, typeVotes)
 }
 
+// FindXMLFile returns path of .xml file with a given name (e.g. "Posts.xml")
+// in dir or "" if there's no such file. File names in dumps are capitalized
+// but can be lower-case after processing, so names are compared
+// case-insensitively
+func FindXMLFile(dir string, name string) string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries {
+		if strings.EqualFold(e.Name(), name) {
+			return filepath.Join(dir, e.Name())
+		}
+	}
+	return ""
+}
+
 // NewBadgesReader returns a new reader for Badges.xml file
 func NewBadgesReader(r io.Reader) (*Reader, error) {
 	return newReader(r, typeBadges)
@@ -195,11 +217,45 @@ func newReaderFromFile(path string, typ string) (*Reader, error) {
 	return newReader(f, typ)
 }
 
+// SetInterner sets Interner used to de-duplicate tags and names of
+// decoded records. in can be shared by many readers
+func (r *Reader) SetInterner(in *Interner) {
+	r.interner = in
+	if r.cache != nil {
+		r.cache.setInterner(in)
+	}
+}
+
 // Err returns potential error
 func (r *Reader) Err() error {
 	return r.err
 }
 
+// Record returns a pointer to the record decoded by Next (e.g. *Post for
+// Posts.xml reader). It can be used before first Next to get the type
+// of records
+func (r *Reader) Record() interface{} {
+	switch r.typ {
+	case typeBadges:
+		return &r.Badge
+	case typeComments:
+		return &r.Comment
+	case typePosts:
+		return &r.Post
+	case typePostHistory:
+		return &r.PostHistory
+	case typePostLinks:
+		return &r.PostLink
+	case typeTags:
+		return &r.Tag
+	case typeUsers:
+		return &r.User
+	case typeVotes:
+		return &r.Vote
+	}
+	return nil
+}
+
 // Close closes a reader
 func (r *Reader) Close() {
 	if !r.finished && r.r != nil {
@@ -223,6 +279,18 @@ func (r *Reader) Next() bool {
 		}
 	}()
 
+	if r.cache != nil {
+		ok, err := r.cache.next()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		r.err = err
+		if !ok && err == nil {
+			r.Close()
+		}
+		return ok
+	}
+
 	// skip newlines between eleemnts
 	t, err := getTokenIgnoreCharData(r.d)
 	if err != nil {
@@ -248,13 +316,13 @@ func (r *Reader) Next() bool {
 	}
 	switch r.typ {
 	case typeBadges:
-		r.err = decodeBadgeRow(t, &r.Badge)
+		r.err = decodeBadgeRow(t, &r.Badge, r.interner)
 	case typeComments:
-		r.err = decodeCommentRow(t, &r.Comment)
+		r.err = decodeCommentRow(t, &r.Comment, r.interner)
 	case typePosts:
-		r.err = decodePostRow(t, &r.Post)
+		r.err = decodePostRow(t, &r.Post, r.interner)
 	case typePostHistory:
-		r.err = decodePostHistoryRow(t, &r.PostHistory)
+		r.err = decodePostHistoryRow(t, &r.PostHistory, r.interner)
 	case typePostLinks:
 		r.err = decodePostLinkRow(t, &r.PostLink)
 	case typeTags:
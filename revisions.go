@@ -0,0 +1,211 @@
+package stackoverflow
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revision is a version of a post, after an edit. Title, Body and Tags are
+// the full content of the post at that revision, not only what changed
+type Revision struct {
+	// 1 for the first version, like revision numbers on the site
+	Number          int       `json:"number"`
+	RevisionGUID    string    `json:"revision_guid,omitempty"`
+	CreationDate    time.Time `json:"creation_date"`
+	UserID          int       `json:"user_id,omitempty"`
+	UserDisplayName string    `json:"user_display_name,omitempty"`
+	Comment         string    `json:"comment,omitempty"`
+
+	Title string   `json:"title,omitempty"`
+	Body  string   `json:"body"`
+	Tags  []string `json:"tags,omitempty"`
+
+	// which parts were changed in this revision
+	TitleChanged bool `json:"title_changed,omitempty"`
+	BodyChanged  bool `json:"body_changed,omitempty"`
+	TagsChanged  bool `json:"tags_changed,omitempty"`
+
+	// true if this revision is a rollback. RollbackTo is the number of the
+	// revision that was restored, if known
+	Rollback   bool `json:"rollback,omitempty"`
+	RollbackTo int  `json:"rollback_to,omitempty"`
+
+	// id of suggested edit if this revision is an approved suggested edit
+	SuggestedEditID int `json:"suggested_edit_id,omitempty"`
+}
+
+func isRevisionHistory(typ int) bool {
+	return typ >= HistoryInitialTitle && typ <= HistoryRollbackTags
+}
+
+var (
+	rxRollbackNumber = regexp.MustCompile(`(?i)rollback to revision (\d+)`)
+	rxRollbackGUID   = regexp.MustCompile(`(?i)rollback to \[([0-9a-f-]+)\]`)
+)
+
+// rollbackTarget returns a revision a rollback with a given comment
+// restored, or nil
+func rollbackTarget(revs []*Revision, comment string) *Revision {
+	if m := rxRollbackNumber.FindStringSubmatch(comment); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if n >= 1 && n <= len(revs) {
+			return revs[n-1]
+		}
+		return nil
+	}
+	if m := rxRollbackGUID.FindStringSubmatch(comment); m != nil {
+		for _, r := range revs {
+			if strings.EqualFold(r.RevisionGUID, m[1]) {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// historyGroup is history rows of a single revision
+type historyGroup struct {
+	rows []*PostHistory
+	// suggested edit applied, recorded separately
+	suggestedEdit *PostHistory
+}
+
+func (g *historyGroup) first() *PostHistory {
+	return g.rows[0]
+}
+
+// Revisions rebuilds revisions of a post from its history, in any order.
+// History rows of one revision (e.g. title, body and tags of initial
+// revision) have the same RevisionGUID. Rows that are not about content
+// (e.g. closing a post) are ignored, except for approvals of suggested edits
+// which set SuggestedEditID of a revision
+func Revisions(history []*PostHistory) []*Revision {
+	rows := make([]*PostHistory, len(history))
+	copy(rows, history)
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if !a.CreationDate.Equal(b.CreationDate) {
+			return a.CreationDate.Before(b.CreationDate)
+		}
+		return a.ID < b.ID
+	})
+
+	var groups []*historyGroup
+	byKey := map[string]*historyGroup{}
+	var suggested []*PostHistory
+	for _, h := range rows {
+		if h.PostHistoryTypeID == HistorySuggestedEditApplied {
+			suggested = append(suggested, h)
+			continue
+		}
+		if !isRevisionHistory(h.PostHistoryTypeID) {
+			continue
+		}
+		// very old rows might not have RevisionGUID
+		key := h.RevisionGUID
+		if key == "" {
+			key = h.CreationDate.String() + "/" + strconv.Itoa(h.UserID)
+		}
+		g := byKey[key]
+		if g == nil {
+			g = &historyGroup{}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.rows = append(g.rows, h)
+	}
+	for _, h := range suggested {
+		// approval has the same RevisionGUID as the edit or, if not, is
+		// recorded at the same time
+		g := byKey[h.RevisionGUID]
+		if g == nil {
+			for _, g2 := range groups {
+				if g2.first().CreationDate.Equal(h.CreationDate) {
+					g = g2
+					break
+				}
+			}
+		}
+		if g != nil {
+			g.suggestedEdit = h
+		}
+	}
+
+	var revs []*Revision
+	var prev *Revision
+	for i, g := range groups {
+		h := g.first()
+		rev := &Revision{
+			Number:          i + 1,
+			RevisionGUID:    h.RevisionGUID,
+			CreationDate:    h.CreationDate,
+			UserID:          h.UserID,
+			UserDisplayName: h.UserDisplayName,
+		}
+		if prev != nil {
+			rev.Title = prev.Title
+			rev.Body = prev.Body
+			rev.Tags = prev.Tags
+		}
+		for _, h := range g.rows {
+			if rev.Comment == "" {
+				rev.Comment = h.Comment
+			}
+			typ := h.PostHistoryTypeID
+			isRollback := typ == HistoryRollbackTitle || typ == HistoryRollbackBody || typ == HistoryRollbackTags
+			var target *Revision
+			if isRollback {
+				rev.Rollback = true
+				target = rollbackTarget(revs, h.Comment)
+				if target != nil {
+					rev.RollbackTo = target.Number
+				}
+			}
+			// rollback rows have restored content in Text, but if it's
+			// missing we can take it from restored revision
+			useTarget := h.Text == "" && target != nil
+			switch typ {
+			case HistoryInitialTitle, HistoryEditTitle, HistoryRollbackTitle:
+				rev.Title = h.Text
+				if useTarget {
+					rev.Title = target.Title
+				}
+				rev.TitleChanged = true
+			case HistoryInitialBody, HistoryEditBody, HistoryRollbackBody:
+				rev.Body = h.Text
+				if useTarget {
+					rev.Body = target.Body
+				}
+				rev.BodyChanged = true
+			case HistoryInitialTags, HistoyrEditTags, HistoryRollbackTags:
+				rev.Tags = h.Tags
+				if useTarget {
+					rev.Tags = target.Tags
+				}
+				rev.TagsChanged = true
+			}
+		}
+		if g.suggestedEdit != nil {
+			rev.SuggestedEditID, _ = strconv.Atoi(g.suggestedEdit.Text)
+		}
+		revs = append(revs, rev)
+		prev = rev
+	}
+	return revs
+}
+
+// RevisionAt returns the revision that was current at time t, or nil if
+// the post didn't exist yet. revs must be sorted by time, as returned
+// by Revisions
+func RevisionAt(revs []*Revision, t time.Time) *Revision {
+	i := sort.Search(len(revs), func(i int) bool {
+		return revs[i].CreationDate.After(t)
+	})
+	if i == 0 {
+		return nil
+	}
+	return revs[i-1]
+}
@@ -0,0 +1,399 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// occurrences in title count as this many occurrences in body
+	titleBoost = 3
+)
+
+// Query is a parsed search query. The syntax is similar to the one of
+// Stack Overflow search:
+//
+//	foo bar      questions with words foo and bar
+//	"foo bar"    questions with phrase foo bar
+//	[go]         questions tagged go
+//	score:3      score at least 3, also score:..3 and score:1..5
+//	created:2020 created in 2020, also created:2020-03..2021 and
+//	             created:2020-03-15..
+//	is:answered  questions with at least one answer
+//	is:accepted  questions with accepted answer
+type Query struct {
+	// each phrase is one or more terms that must be next to each other.
+	// A word is a phrase with one term
+	Phrases    [][]string
+	Tags       []string
+	MinScore   *int
+	MaxScore   *int
+	From       time.Time // created at or after, if not zero
+	To         time.Time // created before, if not zero
+	IsAnswered bool
+	IsAccepted bool
+}
+
+// splitQuery splits query into parts on spaces, except for spaces inside
+// quotes. Quoted parts keep quotes
+func splitQuery(s string) []string {
+	var res []string
+	var curr strings.Builder
+	inQuote := false
+	for _, r := range s {
+		if r == '"' {
+			inQuote = !inQuote
+		}
+		if !inQuote && (r == ' ' || r == '\t') {
+			if curr.Len() > 0 {
+				res = append(res, curr.String())
+				curr.Reset()
+			}
+			continue
+		}
+		curr.WriteRune(r)
+	}
+	if curr.Len() > 0 {
+		res = append(res, curr.String())
+	}
+	return res
+}
+
+func parseIntRange(s string) (*int, *int, error) {
+	min, max := s, s
+	if i := strings.Index(s, ".."); i >= 0 {
+		min, max = s[:i], s[i+2:]
+	} else {
+		max = ""
+	}
+	var res [2]*int
+	for i, v := range []string{min, max} {
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		res[i] = &n
+	}
+	return res[0], res[1], nil
+}
+
+// parseDate parses 2020, 2020-03 or 2020-03-15 and returns start and
+// end of that period
+func parseDate(s string) (time.Time, time.Time, error) {
+	for _, f := range []struct {
+		layout     string
+		y, m, days int
+	}{
+		{"2006", 1, 0, 0},
+		{"2006-01", 0, 1, 0},
+		{"2006-01-02", 0, 0, 1},
+	} {
+		if len(s) != len(f.layout) {
+			continue
+		}
+		t, err := time.Parse(f.layout, s)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		return t, t.AddDate(f.y, f.m, f.days), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date '%s'", s)
+}
+
+func parseDateRange(s string) (time.Time, time.Time, error) {
+	from, to := s, s
+	if i := strings.Index(s, ".."); i >= 0 {
+		from, to = s[:i], s[i+2:]
+	}
+	var start, end time.Time
+	var err error
+	if from != "" {
+		if start, _, err = parseDate(from); err != nil {
+			return start, end, err
+		}
+	}
+	if to != "" {
+		_, end, err = parseDate(to)
+	}
+	return start, end, err
+}
+
+// ParseQuery parses a search query
+func ParseQuery(s string) (*Query, error) {
+	q := &Query{}
+	var err error
+	for _, part := range splitQuery(s) {
+		lower := strings.ToLower(part)
+		switch {
+		case strings.HasPrefix(part, "["):
+			for _, tag := range strings.Split(strings.Trim(lower, "[]"), "][") {
+				if tag != "" {
+					q.Tags = append(q.Tags, tag)
+				}
+			}
+		case strings.HasPrefix(lower, "score:"):
+			q.MinScore, q.MaxScore, err = parseIntRange(part[len("score:"):])
+		case strings.HasPrefix(lower, "created:"):
+			q.From, q.To, err = parseDateRange(part[len("created:"):])
+		case lower == "is:answered":
+			q.IsAnswered = true
+		case lower == "is:accepted":
+			q.IsAccepted = true
+		default:
+			// also for words like node.js which are made of several terms
+			if terms := tokenize(part); len(terms) > 0 {
+				q.Phrases = append(q.Phrases, terms)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid '%s' in query: %s", part, err)
+		}
+	}
+	return q, nil
+}
+
+func (q *Query) isEmpty() bool {
+	return len(q.Phrases) == 0 && len(q.Tags) == 0 && q.MinScore == nil && q.MaxScore == nil &&
+		q.From.IsZero() && q.To.IsZero() && !q.IsAnswered && !q.IsAccepted
+}
+
+func (q *Query) matchFilters(d *doc) bool {
+	if q.MinScore != nil && d.Score < *q.MinScore {
+		return false
+	}
+	if q.MaxScore != nil && d.Score > *q.MaxScore {
+		return false
+	}
+	if !q.From.IsZero() && d.CreationDate.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !d.CreationDate.Before(q.To) {
+		return false
+	}
+	if q.IsAnswered && d.AnswerCount == 0 {
+		return false
+	}
+	if q.IsAccepted && d.AcceptedAnswerID == 0 {
+		return false
+	}
+	return true
+}
+
+// Result is a question matching a query
+type Result struct {
+	ID               int
+	Title            string
+	Tags             []string
+	Score            int
+	CreationDate     time.Time
+	AnswerCount      int
+	AcceptedAnswerID int
+	// BM25 relevance, 0 for queries without words
+	Rank float64
+}
+
+// intersect returns ids present in all lists of postings
+func intersect(lists [][]posting) []int {
+	if len(lists) == 0 {
+		return nil
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return len(lists[i]) < len(lists[j])
+	})
+	var res []int
+	pos := make([]int, len(lists))
+next:
+	for _, p := range lists[0] {
+		for i := 1; i < len(lists); i++ {
+			l := lists[i]
+			for pos[i] < len(l) && l[pos[i]].id < p.id {
+				pos[i]++
+			}
+			if pos[i] == len(l) {
+				break next
+			}
+			if l[pos[i]].id != p.id {
+				continue next
+			}
+		}
+		res = append(res, p.id)
+	}
+	return res
+}
+
+// findPosting returns positions of term in question id
+func findPosting(l []posting, id int) []uint32 {
+	i := sort.Search(len(l), func(i int) bool { return l[i].id >= id })
+	if i < len(l) && l[i].id == id {
+		return l[i].positions
+	}
+	return nil
+}
+
+// hasPhrase returns true if terms with given positions are next to each
+// other somewhere in the question
+func hasPhrase(positions [][]uint32) bool {
+	for _, start := range positions[0] {
+		found := true
+		for i := 1; i < len(positions) && found; i++ {
+			want := start + uint32(i)
+			j := sort.Search(len(positions[i]), func(k int) bool { return positions[i][k] >= want })
+			found = j < len(positions[i]) && positions[i][j] == want
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// Search returns up to n best results for a query and total number of
+// matching questions
+func (idx *Index) Search(query string, n int) ([]Result, int, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	return idx.SearchQuery(q, n)
+}
+
+// SearchQuery is like Search but for already parsed query
+func (idx *Index) SearchQuery(q *Query, n int) ([]Result, int, error) {
+	if q.isEmpty() {
+		return nil, 0, nil
+	}
+	var res []Result
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		nDocs := float64(getUint64(meta, keyNumDocs))
+		avgLen := 1.0
+		if nDocs > 0 {
+			avgLen = float64(getUint64(meta, keyNumTokens)) / nDocs
+		}
+
+		termPostings := map[string][]posting{}
+		var lists [][]posting
+		addList := func(term string) error {
+			if _, ok := termPostings[term]; ok {
+				return nil
+			}
+			l, err := postings(tx, term)
+			if err != nil {
+				return err
+			}
+			termPostings[term] = l
+			lists = append(lists, l)
+			return nil
+		}
+		for _, phrase := range q.Phrases {
+			for _, term := range phrase {
+				if err := addList(term); err != nil {
+					return err
+				}
+			}
+		}
+		for _, tag := range q.Tags {
+			if err := addList(tagTerm(tag)); err != nil {
+				return err
+			}
+		}
+
+		docs := tx.Bucket(bucketDocs)
+		consider := func(id int, data []byte) error {
+			for _, phrase := range q.Phrases {
+				if len(phrase) < 2 {
+					continue
+				}
+				positions := make([][]uint32, len(phrase))
+				for i, term := range phrase {
+					positions[i] = findPosting(termPostings[term], id)
+				}
+				if !hasPhrase(positions) {
+					return nil
+				}
+			}
+			d, err := decodeDoc(id, data)
+			if err != nil {
+				return err
+			}
+			if !q.matchFilters(d) {
+				return nil
+			}
+			r := Result{
+				ID:               d.ID,
+				Title:            d.Title,
+				Tags:             d.Tags,
+				Score:            d.Score,
+				CreationDate:     d.CreationDate,
+				AnswerCount:      d.AnswerCount,
+				AcceptedAnswerID: d.AcceptedAnswerID,
+			}
+			for term, l := range termPostings {
+				if strings.HasPrefix(term, "[") {
+					continue
+				}
+				tf := 0
+				for _, pos := range findPosting(l, id) {
+					if int(pos) < d.TitleLen {
+						tf += titleBoost
+					} else {
+						tf++
+					}
+				}
+				df := float64(len(l))
+				idf := math.Log(1 + (nDocs-df+0.5)/(df+0.5))
+				norm := bm25K1 * (1 - bm25B + bm25B*float64(d.Len)/avgLen)
+				r.Rank += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + norm)
+			}
+			res = append(res, r)
+			return nil
+		}
+
+		if len(lists) == 0 {
+			// only filters, we have to check all questions
+			return docs.ForEach(func(k, v []byte) error {
+				return consider(decodeID(k), v)
+			})
+		}
+		for _, id := range intersect(lists) {
+			data := docs.Get(encodeID(id))
+			if data == nil {
+				continue
+			}
+			if err := consider(id, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := &res[i], &res[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.ID > b.ID
+	})
+	total := len(res)
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+	return res, total, nil
+}
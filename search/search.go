@@ -0,0 +1,426 @@
+// Package search is a full-text search index of questions, stored on disk
+// in bbolt. It indexes title, plain text of body and tags of questions and
+// ranks results with BM25.
+//
+// Postings of a term are stored in segments, one for each batch of indexed
+// questions, under keys made of the term, 0 and segment number, so that all
+// postings of a term are found with a prefix scan. A posting is question id
+// (as a delta from previous id), number of occurrences and their positions.
+// Title is at positions 0..len(title)-1 and body starts at len(title)+1,
+// so that a phrase can't match the end of title and start of body.
+//
+// Tags are indexed as terms like [go], without positions.
+package search
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kjk/stackoverflow"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketPostings = []byte("postings")
+	bucketDocs     = []byte("docs")
+	bucketMeta     = []byte("meta")
+
+	allBuckets = [][]byte{bucketPostings, bucketDocs, bucketMeta}
+
+	keyNumDocs     = []byte("docs")
+	keyNumTokens   = []byte("tokens")
+	keyNumSegments = []byte("segments")
+)
+
+// number of questions in a segment of postings. Postings of a segment are
+// kept in memory until the segment is written
+const segmentSize = 100000
+
+// Index is an on-disk full-text index of questions
+type Index struct {
+	db *bolt.DB
+}
+
+// Create creates a new index file. It fails if the file already exists
+// because adding the same questions again would duplicate their postings
+func Create(path string) (*Index, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("'%s' already exists", path)
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	// an index left incomplete by a crash is simply built again from
+	// Posts.xml, so there's no need to sync every write
+	db.NoSync = true
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Open opens an existing index file for searching
+func Open(path string) (*Index, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Close closes the index
+func (idx *Index) Close() error {
+	if idx.db.NoSync {
+		if err := idx.db.Sync(); err != nil {
+			idx.db.Close()
+			return err
+		}
+	}
+	return idx.db.Close()
+}
+
+func encodeID(id int) []byte {
+	var d [8]byte
+	binary.BigEndian.PutUint64(d[:], uint64(id))
+	return d[:]
+}
+
+func decodeID(d []byte) int {
+	return int(binary.BigEndian.Uint64(d))
+}
+
+func postingsKey(term string, segment uint32) []byte {
+	k := make([]byte, len(term)+5)
+	copy(k, term)
+	binary.BigEndian.PutUint32(k[len(term)+1:], segment)
+	return k
+}
+
+func tagTerm(tag string) string {
+	return "[" + tag + "]"
+}
+
+// doc is what we store about an indexed question
+type doc struct {
+	ID               int
+	Title            string
+	Tags             []string
+	Score            int
+	CreationDate     time.Time
+	AnswerCount      int
+	AcceptedAnswerID int
+	// number of tokens in title and in title + body
+	TitleLen int
+	Len      int
+}
+
+func appendUvarint(d []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(d, tmp[:n]...)
+}
+
+func appendVarint(d []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	return append(d, tmp[:n]...)
+}
+
+func appendString(d []byte, s string) []byte {
+	d = appendUvarint(d, uint64(len(s)))
+	return append(d, s...)
+}
+
+func (d *doc) encode() []byte {
+	var res []byte
+	res = appendVarint(res, int64(d.Score))
+	var created int64
+	if !d.CreationDate.IsZero() {
+		created = d.CreationDate.UnixNano() / int64(time.Millisecond)
+	}
+	res = appendVarint(res, created)
+	res = appendUvarint(res, uint64(d.AnswerCount))
+	res = appendUvarint(res, uint64(d.AcceptedAnswerID))
+	res = appendUvarint(res, uint64(d.TitleLen))
+	res = appendUvarint(res, uint64(d.Len))
+	res = appendString(res, d.Title)
+	res = appendUvarint(res, uint64(len(d.Tags)))
+	for _, tag := range d.Tags {
+		res = appendString(res, tag)
+	}
+	return res
+}
+
+var errCorrupted = errors.New("search: corrupted index")
+
+// decoder decodes values written with append* functions
+type decoder struct {
+	d   []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.d)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+	d.d = d.d[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.d)
+	if n <= 0 {
+		d.err = errCorrupted
+		return 0
+	}
+	d.d = d.d[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if uint64(len(d.d)) < n {
+		d.err = errCorrupted
+		return ""
+	}
+	s := string(d.d[:n])
+	d.d = d.d[n:]
+	return s
+}
+
+func decodeDoc(id int, data []byte) (*doc, error) {
+	d := &decoder{d: data}
+	res := &doc{ID: id}
+	res.Score = int(d.varint())
+	if created := d.varint(); created != 0 {
+		res.CreationDate = time.Unix(0, created*int64(time.Millisecond)).UTC()
+	}
+	res.AnswerCount = int(d.uvarint())
+	res.AcceptedAnswerID = int(d.uvarint())
+	res.TitleLen = int(d.uvarint())
+	res.Len = int(d.uvarint())
+	res.Title = d.string()
+	n := d.uvarint()
+	if n > uint64(len(d.d)) {
+		return nil, errCorrupted
+	}
+	for i := uint64(0); i < n; i++ {
+		res.Tags = append(res.Tags, d.string())
+	}
+	return res, d.err
+}
+
+// postingList is an encoded list of postings of a term in a segment
+type postingList struct {
+	d      []byte
+	lastID int
+}
+
+func (l *postingList) add(id int, positions []uint32) {
+	l.d = appendUvarint(l.d, uint64(id-l.lastID))
+	l.lastID = id
+	l.d = appendUvarint(l.d, uint64(len(positions)))
+	prev := uint32(0)
+	for _, pos := range positions {
+		l.d = appendUvarint(l.d, uint64(pos-prev))
+		prev = pos
+	}
+}
+
+// posting is a decoded posting
+type posting struct {
+	id        int
+	positions []uint32
+}
+
+// decodePostings decodes postings of a segment and appends them to res
+func decodePostings(data []byte, res []posting) ([]posting, error) {
+	d := &decoder{d: data}
+	id := 0
+	for len(d.d) > 0 && d.err == nil {
+		id += int(d.uvarint())
+		n := d.uvarint()
+		if n > uint64(len(d.d)) {
+			return res, errCorrupted
+		}
+		p := posting{id: id}
+		if n > 0 {
+			p.positions = make([]uint32, n)
+			pos := uint32(0)
+			for i := range p.positions {
+				pos += uint32(d.uvarint())
+				p.positions[i] = pos
+			}
+		}
+		res = append(res, p)
+	}
+	return res, d.err
+}
+
+// segment accumulates postings of questions in memory
+type segment struct {
+	postings map[string]*postingList
+	docs     []*doc
+	nTokens  int
+}
+
+func newSegment() *segment {
+	return &segment{postings: map[string]*postingList{}}
+}
+
+func (s *segment) addTerm(term string, id int, positions []uint32) {
+	l := s.postings[term]
+	if l == nil {
+		l = &postingList{}
+		s.postings[term] = l
+	}
+	l.add(id, positions)
+}
+
+func (s *segment) add(p *stackoverflow.Post) {
+	title := tokenize(p.Title)
+	body := tokenize(plainText(p.Body))
+	termPositions := map[string][]uint32{}
+	for i, term := range title {
+		termPositions[term] = append(termPositions[term], uint32(i))
+	}
+	// gap of one position between title and body
+	for i, term := range body {
+		termPositions[term] = append(termPositions[term], uint32(len(title)+1+i))
+	}
+	for term, positions := range termPositions {
+		s.addTerm(term, p.ID, positions)
+	}
+	for _, tag := range p.Tags {
+		s.addTerm(tagTerm(tag), p.ID, nil)
+	}
+	s.docs = append(s.docs, &doc{
+		ID:               p.ID,
+		Title:            p.Title,
+		Tags:             p.Tags,
+		Score:            p.Score,
+		CreationDate:     p.CreationDate,
+		AnswerCount:      p.AnswerCount,
+		AcceptedAnswerID: p.AcceptedAnswerID,
+		TitleLen:         len(title),
+		Len:              len(title) + len(body),
+	})
+	s.nTokens += len(title) + len(body)
+}
+
+func getUint64(b *bolt.Bucket, key []byte) uint64 {
+	d := b.Get(key)
+	if len(d) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(d)
+}
+
+func putUint64(b *bolt.Bucket, key []byte, v uint64) error {
+	var d [8]byte
+	binary.BigEndian.PutUint64(d[:], v)
+	return b.Put(key, d[:])
+}
+
+func (idx *Index) writeSegment(s *segment) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		segNo := getUint64(meta, keyNumSegments)
+		b := tx.Bucket(bucketPostings)
+		for term, l := range s.postings {
+			if err := b.Put(postingsKey(term, uint32(segNo)), l.d); err != nil {
+				return err
+			}
+		}
+		b = tx.Bucket(bucketDocs)
+		b.FillPercent = 0.9
+		for _, d := range s.docs {
+			if err := b.Put(encodeID(d.ID), d.encode()); err != nil {
+				return err
+			}
+		}
+		if err := putUint64(meta, keyNumSegments, segNo+1); err != nil {
+			return err
+		}
+		if err := putUint64(meta, keyNumDocs, getUint64(meta, keyNumDocs)+uint64(len(s.docs))); err != nil {
+			return err
+		}
+		return putUint64(meta, keyNumTokens, getUint64(meta, keyNumTokens)+uint64(s.nTokens))
+	})
+}
+
+// AddFromReader indexes all questions from a reader of Posts.xml. Questions
+// must be in the order of ids, like in .xml files. Returns number of
+// indexed questions
+func (idx *Index) AddFromReader(r *stackoverflow.Reader) (int, error) {
+	n := 0
+	s := newSegment()
+	for r.Next() {
+		p, ok := r.Record().(*stackoverflow.Post)
+		if !ok {
+			return n, errors.New("search: not a reader of posts")
+		}
+		if p.PostTypeID != stackoverflow.PostQuestion {
+			continue
+		}
+		s.add(p)
+		n++
+		if len(s.docs) == segmentSize {
+			if err := idx.writeSegment(s); err != nil {
+				return n, err
+			}
+			s = newSegment()
+		}
+	}
+	if r.Err() != nil {
+		return n, r.Err()
+	}
+	if len(s.docs) > 0 {
+		if err := idx.writeSegment(s); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// postings returns all postings of a term, sorted by question id
+func postings(tx *bolt.Tx, term string) ([]posting, error) {
+	var res []posting
+	var err error
+	prefix := append([]byte(term), 0)
+	c := tx.Bucket(bucketPostings).Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if len(k) != len(prefix)+4 {
+			continue
+		}
+		if res, err = decodePostings(v, res); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokens longer than this are most likely not words (base64, hashes)
+const maxTokenLen = 64
+
+// plainText returns text of html Body of a post, with tags replaced
+// by spaces and entities decoded
+func plainText(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			sb.WriteString(s)
+			break
+		}
+		sb.WriteString(s[:i])
+		sb.WriteByte(' ')
+		s = s[i:]
+		end := strings.IndexByte(s, '>')
+		if end < 0 {
+			break
+		}
+		s = s[end+1:]
+	}
+	return html.UnescapeString(sb.String())
+}
+
+func isTokenRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenize splits text into lower-cased words. Words are made of letters,
+// digits and '_'. Trailing '+' and '#' are part of a word so that c++ and
+// c# are words
+func tokenize(s string) []string {
+	var res []string
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if !isTokenRune(r) {
+			i += n
+			continue
+		}
+		start := i
+		for i < len(s) {
+			r, n = utf8.DecodeRuneInString(s[i:])
+			if !isTokenRune(r) {
+				break
+			}
+			i += n
+		}
+		for i < len(s) && (s[i] == '+' || s[i] == '#') {
+			i++
+		}
+		if i-start <= maxTokenLen {
+			res = append(res, strings.ToLower(s[start:i]))
+		}
+	}
+	return res
+}
@@ -9,11 +9,11 @@ import (
 
 // Tag describes a tag
 type Tag struct {
-	ID            int
-	TagName       string
-	Count         int
-	ExcerptPostID int
-	WikiPostID    int
+	ID            int    `json:"tag_id"`
+	TagName       string `json:"name"`
+	Count         int    `json:"count"`
+	ExcerptPostID int    `json:"excerpt_post_id,omitempty"`
+	WikiPostID    int    `json:"wiki_post_id,omitempty"`
 }
 
 func decodeTagAttr(attr xml.Attr, t *Tag) error {
@@ -49,3 +49,11 @@ func decodeTagRow(t xml.Token, tag *Tag) error {
 	}
 	return nil
 }
+
+func encodeTagRow(rw *rowWriter, t *Tag) {
+	rw.int("Id", t.ID)
+	rw.str("TagName", t.TagName)
+	rw.int("Count", t.Count)
+	rw.intOpt("ExcerptPostId", t.ExcerptPostID)
+	rw.intOpt("WikiPostId", t.WikiPostID)
+}
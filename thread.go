@@ -0,0 +1,298 @@
+package stackoverflow
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ThreadPost is a question or an answer in a Thread
+type ThreadPost struct {
+	Post *Post `json:"post"`
+	// nil if not known
+	Owner *User `json:"owner,omitempty"`
+	// sorted by CreationDate
+	Comments []*Comment `json:"comments,omitempty"`
+	// true for accepted answer
+	Accepted bool `json:"accepted,omitempty"`
+}
+
+// Thread is a question with its answers, comments and links to other posts
+type Thread struct {
+	Question ThreadPost `json:"question"`
+	// sorted by score, highest first
+	Answers []ThreadPost `json:"answers,omitempty"`
+	// ids of posts linked from the question
+	Related []int `json:"related,omitempty"`
+	// ids of questions this question is a duplicate of
+	DuplicateOf []int `json:"duplicate_of,omitempty"`
+	// ids of questions that are duplicates of this question
+	Duplicates []int `json:"duplicates,omitempty"`
+}
+
+// ID returns id of the question
+func (t *Thread) ID() int {
+	return t.Question.Post.ID
+}
+
+// AcceptedAnswer returns accepted answer or nil
+func (t *Thread) AcceptedAnswer() *ThreadPost {
+	for i := range t.Answers {
+		if t.Answers[i].Accepted {
+			return &t.Answers[i]
+		}
+	}
+	return nil
+}
+
+// threadID returns id of the question a post belongs to
+func threadID(p *Post) int {
+	if p.PostTypeID == PostAnswer {
+		return p.ParentID
+	}
+	return p.ID
+}
+
+// NewThread builds a thread of question q. links are links from q and
+// links to q, which are used for Duplicates. Comments of posts other than
+// q and answers and other links are ignored. user returns a user by id or
+// nil, it can be nil
+func NewThread(q *Post, answers []*Post, comments []*Comment, links []*PostLink, user func(id int) *User) *Thread {
+	owner := func(p *Post) *User {
+		if user == nil || p.OwnerUserID == 0 {
+			return nil
+		}
+		return user(p.OwnerUserID)
+	}
+	t := &Thread{
+		Question: ThreadPost{Post: q, Owner: owner(q)},
+	}
+	byID := map[int]*ThreadPost{q.ID: &t.Question}
+	t.Answers = make([]ThreadPost, len(answers))
+	for i, a := range answers {
+		t.Answers[i] = ThreadPost{
+			Post:     a,
+			Owner:    owner(a),
+			Accepted: q.AcceptedAnswerID != 0 && a.ID == q.AcceptedAnswerID,
+		}
+	}
+	sort.SliceStable(t.Answers, func(i, j int) bool {
+		a, b := t.Answers[i].Post, t.Answers[j].Post
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.CreationDate.Equal(b.CreationDate) {
+			return a.CreationDate.Before(b.CreationDate)
+		}
+		return a.ID < b.ID
+	})
+	for i := range t.Answers {
+		byID[t.Answers[i].Post.ID] = &t.Answers[i]
+	}
+	for _, c := range comments {
+		if tp := byID[c.PostID]; tp != nil {
+			tp.Comments = append(tp.Comments, c)
+		}
+	}
+	for _, tp := range byID {
+		cs := tp.Comments
+		sort.SliceStable(cs, func(i, j int) bool {
+			if !cs[i].CreationDate.Equal(cs[j].CreationDate) {
+				return cs[i].CreationDate.Before(cs[j].CreationDate)
+			}
+			return cs[i].ID < cs[j].ID
+		})
+	}
+	for _, l := range links {
+		if l.PostID != q.ID {
+			if l.RelatedPostID == q.ID && l.LinkTypeID == LinkTypeDuplicate {
+				t.Duplicates = append(t.Duplicates, l.PostID)
+			}
+			continue
+		}
+		if l.LinkTypeID == LinkTypeDuplicate {
+			t.DuplicateOf = append(t.DuplicateOf, l.RelatedPostID)
+		} else {
+			t.Related = append(t.Related, l.RelatedPostID)
+		}
+	}
+	return t
+}
+
+// ThreadBuilder builds threads from records grouped by thread i.e. by
+// question. Records of a thread are added with Add: first the question
+// and its answers, then comments of those posts and post links. A thread
+// is emitted when a post of another thread is added and by Flush.
+//
+// This allows processing all threads without keeping all records in
+// memory if inputs are sorted by thread. Links are added to the thread
+// of their source post, so Thread.Duplicates is not set.
+type ThreadBuilder struct {
+	// User returns a user by id, used to set owners of posts. Can be nil
+	User func(id int) *User
+
+	emit     func(t *Thread) error
+	threadID int
+	question *Post
+	answers  []*Post
+	comments []*Comment
+	links    []*PostLink
+	// ids of posts in current thread
+	posts map[int]bool
+}
+
+// NewThreadBuilder returns a builder that calls emit with complete threads
+func NewThreadBuilder(emit func(t *Thread) error) *ThreadBuilder {
+	return &ThreadBuilder{
+		emit:  emit,
+		posts: map[int]bool{},
+	}
+}
+
+// HasPost returns true if post with id is in current thread
+func (b *ThreadBuilder) HasPost(id int) bool {
+	return b.posts[id]
+}
+
+// Add adds *Post, *Comment or *PostLink to current thread. Records are
+// copied so the caller can re-use them
+func (b *ThreadBuilder) Add(rec interface{}) error {
+	switch v := rec.(type) {
+	case *Post:
+		if v.PostTypeID != PostQuestion && v.PostTypeID != PostAnswer {
+			return nil
+		}
+		id := threadID(v)
+		if len(b.posts) > 0 && id != b.threadID {
+			if err := b.Flush(); err != nil {
+				return err
+			}
+		}
+		b.threadID = id
+		b.posts[v.ID] = true
+		p := *v
+		if v.PostTypeID == PostQuestion {
+			b.question = &p
+		} else {
+			b.answers = append(b.answers, &p)
+		}
+	case *Comment:
+		if !b.posts[v.PostID] {
+			return fmt.Errorf("comment %d is for post %d which is not in thread %d", v.ID, v.PostID, b.threadID)
+		}
+		c := *v
+		b.comments = append(b.comments, &c)
+	case *PostLink:
+		if !b.posts[v.PostID] {
+			return fmt.Errorf("post link %d is for post %d which is not in thread %d", v.ID, v.PostID, b.threadID)
+		}
+		l := *v
+		b.links = append(b.links, &l)
+	default:
+		return fmt.Errorf("ThreadBuilder: unsupported record %T", rec)
+	}
+	return nil
+}
+
+// Flush emits current thread. Answers without a question are dropped
+func (b *ThreadBuilder) Flush() error {
+	var err error
+	if b.question != nil {
+		err = b.emit(NewThread(b.question, b.answers, b.comments, b.links, b.User))
+	}
+	b.question = nil
+	b.answers = nil
+	b.comments = nil
+	b.links = nil
+	b.posts = map[int]bool{}
+	return err
+}
+
+// ReadThreads reads posts, comments and post links sorted by thread and
+// calls emit with each complete thread. Posts must be grouped by thread,
+// with question first, and threads sorted by question id. Comments and
+// links must be in the same order of threads as posts. comments and links
+// can be nil. user is used to set owners of posts and can be nil.
+//
+// Comments and links of posts that are not questions or answers in posts
+// (e.g. tag wikis or deleted posts) must be sorted by their post id. They
+// are skipped, because posts of later threads have larger ids than their
+// questions
+func ReadThreads(posts, comments, links *Reader, user func(id int) *User, emit func(t *Thread) error) error {
+	b := NewThreadBuilder(emit)
+	b.User = user
+	// records read from comments and links but not yet added
+	var comment *Comment
+	var link *PostLink
+	nextComment := func() {
+		comment = nil
+		if comments != nil && comments.Next() {
+			comment = &comments.Comment
+		}
+	}
+	nextLink := func() {
+		link = nil
+		if links != nil && links.Next() {
+			link = &links.PostLink
+		}
+	}
+	nextComment()
+	nextLink()
+	// adds comments and links of current thread. nextThreadID is id of
+	// question of the next thread. Posts of it and later threads have ids
+	// >= nextThreadID, so records for other posts with smaller ids can't be
+	// for any thread and are skipped
+	addRest := func(nextThreadID int) error {
+		for comment != nil {
+			if b.HasPost(comment.PostID) {
+				if err := b.Add(comment); err != nil {
+					return err
+				}
+			} else if comment.PostID >= nextThreadID {
+				break
+			}
+			nextComment()
+		}
+		for link != nil {
+			if b.HasPost(link.PostID) {
+				if err := b.Add(link); err != nil {
+					return err
+				}
+			} else if link.PostID >= nextThreadID {
+				break
+			}
+			nextLink()
+		}
+		return nil
+	}
+	for posts.Next() {
+		p := &posts.Post
+		if p.PostTypeID != PostQuestion && p.PostTypeID != PostAnswer {
+			continue
+		}
+		if len(b.posts) > 0 && threadID(p) != b.threadID {
+			if err := addRest(threadID(p)); err != nil {
+				return err
+			}
+		}
+		if err := b.Add(p); err != nil {
+			return err
+		}
+	}
+	if posts.Err() != nil {
+		return posts.Err()
+	}
+	// there are no more threads so all remaining records are either for
+	// the last thread or skipped
+	if err := addRest(int(^uint(0) >> 1)); err != nil {
+		return err
+	}
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	for _, r := range []*Reader{comments, links} {
+		if r != nil && r.Err() != nil {
+			return r.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package stackoverflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func testReader(t *testing.T, typ string, rows []string, newReader func(r *strings.Reader) (*Reader, error)) *Reader {
+	s := `<?xml version="1.0" encoding="utf-8"?>` + "\n<" + typ + ">\n  " + strings.Join(rows, "\n  ") + "\n</" + typ + ">\n"
+	r, err := newReader(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReadThreadsSkipsUnknownPosts(t *testing.T) {
+	posts := testReader(t, "posts", []string{
+		`<row Id="100" PostTypeId="1" Score="0" Body="" />`,
+		`<row Id="105" PostTypeId="2" ParentId="100" Score="0" Body="" />`,
+		`<row Id="110" PostTypeId="2" ParentId="100" Score="0" Body="" />`,
+		`<row Id="115" PostTypeId="5" Score="0" Body="" />`,
+		`<row Id="120" PostTypeId="1" Score="0" Body="" />`,
+		`<row Id="121" PostTypeId="2" ParentId="120" Score="0" Body="" />`,
+	}, func(r *strings.Reader) (*Reader, error) { return NewPostsReader(r) })
+	// comments on posts 50, 103, 115 and 200 are not in any thread
+	comments := testReader(t, "comments", []string{
+		`<row Id="1" PostId="50" Score="0" Text="" />`,
+		`<row Id="2" PostId="100" Score="0" Text="" />`,
+		`<row Id="3" PostId="105" Score="0" Text="" />`,
+		`<row Id="4" PostId="103" Score="0" Text="" />`,
+		`<row Id="5" PostId="110" Score="0" Text="" />`,
+		`<row Id="6" PostId="115" Score="0" Text="" />`,
+		`<row Id="7" PostId="121" Score="0" Text="" />`,
+		`<row Id="8" PostId="120" Score="0" Text="" />`,
+		`<row Id="9" PostId="200" Score="0" Text="" />`,
+	}, func(r *strings.Reader) (*Reader, error) { return NewCommentsReader(r) })
+	links := testReader(t, "postlinks", []string{
+		`<row Id="1" PostId="103" RelatedPostId="120" LinkTypeId="1" />`,
+		`<row Id="2" PostId="100" RelatedPostId="120" LinkTypeId="1" />`,
+		`<row Id="3" PostId="120" RelatedPostId="100" LinkTypeId="2" />`,
+	}, func(r *strings.Reader) (*Reader, error) { return NewPostLinksReader(r) })
+
+	var threads []*Thread
+	err := ReadThreads(posts, comments, links, nil, func(t *Thread) error {
+		threads = append(threads, t)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(threads) != 2 {
+		t.Fatalf("got %d threads, expected 2", len(threads))
+	}
+	commentIDs := func(th *Thread) []int {
+		var res []int
+		for _, p := range append([]ThreadPost{th.Question}, th.Answers...) {
+			for _, c := range p.Comments {
+				res = append(res, c.ID)
+			}
+		}
+		return res
+	}
+	if ids := commentIDs(threads[0]); len(ids) != 3 {
+		t.Fatalf("got comments %v in thread 100, expected 2, 3 and 5", ids)
+	}
+	if ids := commentIDs(threads[1]); len(ids) != 2 {
+		t.Fatalf("got comments %v in thread 120, expected 7 and 8", ids)
+	}
+	if len(threads[0].Related) != 1 || len(threads[1].DuplicateOf) != 1 {
+		t.Fatalf("got links %v, %v, expected one link in each thread", threads[0].Related, threads[1].DuplicateOf)
+	}
+}
+
+func TestNewThreadDuplicates(t *testing.T) {
+	q := &Post{ID: 100, PostTypeID: PostQuestion}
+	links := []*PostLink{
+		{ID: 1, PostID: 100, RelatedPostID: 50, LinkTypeID: LinkTypeDuplicate},
+		{ID: 2, PostID: 100, RelatedPostID: 60, LinkTypeID: LinkTypeLinked},
+		{ID: 3, PostID: 120, RelatedPostID: 100, LinkTypeID: LinkTypeDuplicate},
+		{ID: 4, PostID: 130, RelatedPostID: 100, LinkTypeID: LinkTypeLinked},
+	}
+	th := NewThread(q, nil, nil, links, nil)
+	if len(th.DuplicateOf) != 1 || th.DuplicateOf[0] != 50 {
+		t.Fatalf("got DuplicateOf %v, expected [50]", th.DuplicateOf)
+	}
+	if len(th.Related) != 1 || th.Related[0] != 60 {
+		t.Fatalf("got Related %v, expected [60]", th.Related)
+	}
+	if len(th.Duplicates) != 1 || th.Duplicates[0] != 120 {
+		t.Fatalf("got Duplicates %v, expected [120]", th.Duplicates)
+	}
+}
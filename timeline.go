@@ -0,0 +1,213 @@
+package stackoverflow
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ScoreDay is votes for a post in a single day. Dates of votes in dumps
+// are rounded to days
+type ScoreDay struct {
+	Date      time.Time `json:"date"`
+	Up        int       `json:"up"`
+	Down      int       `json:"down"`
+	Favorites int       `json:"favorites"`
+	// score (up votes - down votes) and number of favorites at the end
+	// of the day
+	Score          int `json:"score"`
+	TotalFavorites int `json:"total_favorites"`
+}
+
+// ScoreTimeline is score of a post over time. Days only has days with votes
+type ScoreTimeline struct {
+	PostID int        `json:"post_id"`
+	Days   []ScoreDay `json:"days"`
+
+	sorted bool
+}
+
+// Score returns final score
+func (t *ScoreTimeline) Score() int {
+	if len(t.Days) == 0 {
+		return 0
+	}
+	return t.Days[len(t.Days)-1].Score
+}
+
+// ScoreAt returns score and number of favorites at the end of day of t
+func (t *ScoreTimeline) ScoreAt(tm time.Time) (int, int) {
+	i := sort.Search(len(t.Days), func(i int) bool {
+		return t.Days[i].Date.After(tm)
+	})
+	if i == 0 {
+		return 0, 0
+	}
+	d := &t.Days[i-1]
+	return d.Score, d.TotalFavorites
+}
+
+func (t *ScoreTimeline) add(v *Vote) {
+	day := v.CreationDate.UTC().Truncate(24 * time.Hour)
+	n := len(t.Days)
+	if n == 0 || !t.Days[n-1].Date.Equal(day) {
+		if n > 0 && day.Before(t.Days[n-1].Date) {
+			t.sorted = false
+		}
+		t.Days = append(t.Days, ScoreDay{Date: day})
+		n++
+	}
+	d := &t.Days[n-1]
+	switch v.VoteTypeID {
+	case VoteUpMod:
+		d.Up++
+	case VoteDownMod:
+		d.Down++
+	case VoteFavorite:
+		d.Favorites++
+	}
+}
+
+// finish sorts days and calculates running totals
+func (t *ScoreTimeline) finish() {
+	if !t.sorted {
+		sort.SliceStable(t.Days, func(i, j int) bool {
+			return t.Days[i].Date.Before(t.Days[j].Date)
+		})
+		// merge days that were not consecutive
+		n := 0
+		for i, d := range t.Days {
+			if i > 0 && d.Date.Equal(t.Days[n-1].Date) {
+				prev := &t.Days[n-1]
+				prev.Up += d.Up
+				prev.Down += d.Down
+				prev.Favorites += d.Favorites
+				continue
+			}
+			t.Days[n] = d
+			n++
+		}
+		t.Days = t.Days[:n]
+		t.sorted = true
+	}
+	score, favs := 0, 0
+	for i := range t.Days {
+		d := &t.Days[i]
+		score += d.Up - d.Down
+		favs += d.Favorites
+		d.Score = score
+		d.TotalFavorites = favs
+	}
+}
+
+// ScoreTimelines is score timelines of all posts, built from Votes.xml
+type ScoreTimelines struct {
+	posts map[int]*ScoreTimeline
+	dirty bool
+}
+
+// NewScoreTimelines returns empty timelines
+func NewScoreTimelines() *ScoreTimelines {
+	return &ScoreTimelines{posts: map[int]*ScoreTimeline{}}
+}
+
+// ReadScoreTimelines builds timelines from a reader of Votes.xml
+func ReadScoreTimelines(r *Reader) (*ScoreTimelines, error) {
+	st := NewScoreTimelines()
+	for r.Next() {
+		st.AddVote(&r.Vote)
+	}
+	return st, r.Err()
+}
+
+// AddVote adds a vote. Only up, down and favorite votes are used
+func (st *ScoreTimelines) AddVote(v *Vote) {
+	switch v.VoteTypeID {
+	case VoteUpMod, VoteDownMod, VoteFavorite:
+	default:
+		return
+	}
+	t := st.posts[v.PostID]
+	if t == nil {
+		t = &ScoreTimeline{PostID: v.PostID, sorted: true}
+		st.posts[v.PostID] = t
+	}
+	t.add(v)
+	st.dirty = true
+}
+
+func (st *ScoreTimelines) finish() {
+	if !st.dirty {
+		return
+	}
+	for _, t := range st.posts {
+		t.finish()
+	}
+	st.dirty = false
+}
+
+// Post returns timeline of a post or nil if it has no votes
+func (st *ScoreTimelines) Post(postID int) *ScoreTimeline {
+	st.finish()
+	return st.posts[postID]
+}
+
+// PostIDs returns sorted ids of posts with votes
+func (st *ScoreTimelines) PostIDs() []int {
+	res := make([]int, 0, len(st.posts))
+	for id := range st.posts {
+		res = append(res, id)
+	}
+	sort.Ints(res)
+	return res
+}
+
+// WriteCSV writes timelines of all posts as .csv, one row per post and day
+func (st *ScoreTimelines) WriteCSV(w io.Writer) error {
+	st.finish()
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"PostId", "Date", "Up", "Down", "Favorites", "Score", "TotalFavorites"})
+	for _, id := range st.PostIDs() {
+		for _, d := range st.posts[id].Days {
+			cw.Write([]string{
+				strconv.Itoa(id),
+				d.Date.Format("2006-01-02"),
+				strconv.Itoa(d.Up),
+				strconv.Itoa(d.Down),
+				strconv.Itoa(d.Favorites),
+				strconv.Itoa(d.Score),
+				strconv.Itoa(d.TotalFavorites),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ScoreMismatch is a post whose Score is different than score from votes
+type ScoreMismatch struct {
+	PostID     int
+	PostScore  int
+	VotesScore int
+}
+
+// CheckScores compares Score of posts from a reader of Posts.xml with score
+// calculated from votes. Scores don't always match because e.g. votes of
+// deleted users are not in dumps
+func (st *ScoreTimelines) CheckScores(posts *Reader) ([]ScoreMismatch, error) {
+	st.finish()
+	var res []ScoreMismatch
+	for posts.Next() {
+		p := &posts.Post
+		score := 0
+		if t := st.posts[p.ID]; t != nil {
+			score = t.Score()
+		}
+		if score != p.Score {
+			res = append(res, ScoreMismatch{PostID: p.ID, PostScore: p.Score, VotesScore: score})
+		}
+	}
+	return res, posts.Err()
+}
@@ -10,20 +10,20 @@ import (
 
 // User describes a user
 type User struct {
-	ID              int
-	Reputation      int
-	CreationDate    time.Time
-	DisplayName     string
-	LastAccessDate  time.Time
-	WebsiteURL      string
-	Location        string
-	AboutMe         string
-	Views           int
-	UpVotes         int
-	DownVotes       int
-	Age             int
-	AccountID       int
-	ProfileImageURL string
+	ID              int       `json:"user_id"`
+	Reputation      int       `json:"reputation"`
+	CreationDate    time.Time `json:"creation_date"`
+	DisplayName     string    `json:"display_name"`
+	LastAccessDate  time.Time `json:"last_access_date"`
+	WebsiteURL      string    `json:"website_url,omitempty"`
+	Location        string    `json:"location,omitempty"`
+	AboutMe         string    `json:"about_me,omitempty"`
+	Views           int       `json:"view_count"`
+	UpVotes         int       `json:"up_vote_count"`
+	DownVotes       int       `json:"down_vote_count"`
+	Age             int       `json:"age,omitempty"`
+	AccountID       int       `json:"account_id,omitempty"`
+	ProfileImageURL string    `json:"profile_image,omitempty"`
 }
 
 func decodeUserAttr(attr xml.Attr, u *User) error {
@@ -77,3 +77,20 @@ func decodeUserRow(t xml.Token, u *User) error {
 	}
 	return nil
 }
+
+func encodeUserRow(rw *rowWriter, u *User) {
+	rw.int("Id", u.ID)
+	rw.int("Reputation", u.Reputation)
+	rw.timeOpt("CreationDate", u.CreationDate)
+	rw.str("DisplayName", u.DisplayName)
+	rw.timeOpt("LastAccessDate", u.LastAccessDate)
+	rw.strOpt("WebsiteUrl", u.WebsiteURL)
+	rw.strOpt("Location", u.Location)
+	rw.strOpt("AboutMe", u.AboutMe)
+	rw.int("Views", u.Views)
+	rw.int("UpVotes", u.UpVotes)
+	rw.int("DownVotes", u.DownVotes)
+	rw.intOpt("Age", u.Age)
+	rw.intOpt("AccountId", u.AccountID)
+	rw.strOpt("ProfileImageUrl", u.ProfileImageURL)
+}
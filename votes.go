@@ -30,14 +30,14 @@ const (
 
 // Vote describes a vote
 type Vote struct {
-	ID         int
-	PostID     int
-	VoteTypeID int
+	ID         int `json:"vote_id"`
+	PostID     int `json:"post_id"`
+	VoteTypeID int `json:"vote_type_id"`
 	// only present if VoteTypeID is 5 or 8
-	UserID int
+	UserID int `json:"user_id,omitempty"`
 	// only present if VoteTypeID is 8 or 9
-	BountyAmount int
-	CreationDate time.Time
+	BountyAmount int       `json:"bounty_amount,omitempty"`
+	CreationDate time.Time `json:"creation_date"`
 }
 
 func decodeVoteAttr(attr xml.Attr, vote *Vote) error {
@@ -75,3 +75,12 @@ func decodeVoteRow(t xml.Token, vote *Vote) error {
 	}
 	return nil
 }
+
+func encodeVoteRow(rw *rowWriter, vote *Vote) {
+	rw.int("Id", vote.ID)
+	rw.int("PostId", vote.PostID)
+	rw.int("VoteTypeId", vote.VoteTypeID)
+	rw.intOpt("UserId", vote.UserID)
+	rw.intOpt("BountyAmount", vote.BountyAmount)
+	rw.timeOpt("CreationDate", vote.CreationDate)
+}
@@ -0,0 +1,254 @@
+package stackoverflow
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFormatWrite is how we format time when writing .xml files. It matches
+// millisecond precision used in the data dumps and can be parsed with TimeFormat
+const timeFormatWrite = "2006-01-02T15:04:05.000"
+
+// Writer is for writing records to xml file in the same format as data dump
+type Writer struct {
+	w   io.Writer
+	bw  *bufio.Writer
+	typ string
+	err error
+}
+
+// NewBadgesWriterToFile creates Badges.xml file and returns a writer for it
+func NewBadgesWriterToFile(path string) (*Writer, error) {
+	return newWriterToFile(path, typeBadges)
+}
+
+// NewCommentsWriterToFile creates Comments.xml file and returns a writer for it
+func NewCommentsWriterToFile(path string) (*Writer, error) {
+	return newWriterToFile(path, typeComments)
+}
+
+// NewPostHistoryWriterToFile creates PostHistory.xml file and returns a writer for it
+func NewPostHistoryWriterToFile(path string) (*Writer, error) {
+	return newWriterToFile(path, typePostHistory)
+}
+
+// NewPostLinksWriterToFile creates PostLinks.xml file and returns a writer for it
+func NewPostLinksWriterToFile(path string) (*Writer, error) {
+	return newWriterToFile(path, typePostLinks)
+}
+
+// NewPostsWriterToFile creates Posts.xml file and returns a writer for it
+func NewPostsWriterToFile(path string) (*Writer, error) {
+	return newWriterToFile(path, typePosts)
+}
+
+// NewTagsWriterToFile creates Tags.xml file and returns a writer for it
+func NewTagsWriterToFile(path string) (*Writer, error) {
+	return newWriterToFile(path, typeTags)
+}
+
+// NewUsersWriterToFile creates Users.xml file and returns a writer for it
+func NewUsersWriterToFile(path string) (*Writer, error) {
+	return newWriterToFile(path, typeUsers)
+}
+
+// NewVotesWriterToFile creates Votes.xml file and returns a writer for it
+func NewVotesWriterToFile(path string) (*Writer, error) {
+	return newWriterToFile(path, typeVotes)
+}
+
+// NewBadgesWriter returns a new writer for Badges.xml file
+func NewBadgesWriter(w io.Writer) (*Writer, error) {
+	return newWriter(w, typeBadges)
+}
+
+// NewCommentsWriter returns a new writer for Comments.xml file
+func NewCommentsWriter(w io.Writer) (*Writer, error) {
+	return newWriter(w, typeComments)
+}
+
+// NewPostHistoryWriter returns a new writer for PostHistory.xml file
+func NewPostHistoryWriter(w io.Writer) (*Writer, error) {
+	return newWriter(w, typePostHistory)
+}
+
+// NewPostLinksWriter returns a new writer for PostLinks.xml file
+func NewPostLinksWriter(w io.Writer) (*Writer, error) {
+	return newWriter(w, typePostLinks)
+}
+
+// NewPostsWriter returns a new writer for Posts.xml file
+func NewPostsWriter(w io.Writer) (*Writer, error) {
+	return newWriter(w, typePosts)
+}
+
+// NewTagsWriter returns a new writer for Tags.xml file
+func NewTagsWriter(w io.Writer) (*Writer, error) {
+	return newWriter(w, typeTags)
+}
+
+// NewUsersWriter returns a new writer for Users.xml file
+func NewUsersWriter(w io.Writer) (*Writer, error) {
+	return newWriter(w, typeUsers)
+}
+
+// NewVotesWriter returns a new writer for Votes.xml file
+func NewVotesWriter(w io.Writer) (*Writer, error) {
+	return newWriter(w, typeVotes)
+}
+
+func newWriter(w io.Writer, typ string) (*Writer, error) {
+	res := &Writer{
+		w:   w,
+		bw:  bufio.NewWriter(w),
+		typ: typ,
+	}
+	res.bw.WriteString(xml.Header)
+	res.bw.WriteString("<" + typ + ">\n")
+	return res, nil
+}
+
+func newWriterToFile(path string, typ string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return newWriter(f, typ)
+}
+
+// Err returns potential error
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Close writes closing element, flushes buffered data and closes underlying
+// writer if it's io.WriteCloser
+func (w *Writer) Close() error {
+	if w.bw == nil {
+		return w.err
+	}
+	w.bw.WriteString("</" + w.typ + ">\n")
+	err := w.bw.Flush()
+	if w.err == nil {
+		w.err = err
+	}
+	if wc, ok := w.w.(io.WriteCloser); ok {
+		err = wc.Close()
+		if w.err == nil {
+			w.err = err
+		}
+	}
+	w.bw = nil
+	w.w = nil
+	return w.err
+}
+
+func (w *Writer) writeRow(typ string, encode func(rw *rowWriter)) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.bw == nil {
+		w.err = os.ErrClosed
+		return w.err
+	}
+	if w.typ != typ {
+		panic("writing " + typ + " record with writer for " + w.typ)
+	}
+	w.bw.WriteString("  <row")
+	encode(&rowWriter{w: w.bw})
+	_, w.err = w.bw.WriteString(" />\n")
+	return w.err
+}
+
+// WriteBadge writes a Badge record
+func (w *Writer) WriteBadge(b *Badge) error {
+	return w.writeRow(typeBadges, func(rw *rowWriter) { encodeBadgeRow(rw, b) })
+}
+
+// WriteComment writes a Comment record
+func (w *Writer) WriteComment(c *Comment) error {
+	return w.writeRow(typeComments, func(rw *rowWriter) { encodeCommentRow(rw, c) })
+}
+
+// WritePostHistory writes a PostHistory record
+func (w *Writer) WritePostHistory(h *PostHistory) error {
+	return w.writeRow(typePostHistory, func(rw *rowWriter) { encodePostHistoryRow(rw, h) })
+}
+
+// WritePostLink writes a PostLink record
+func (w *Writer) WritePostLink(l *PostLink) error {
+	return w.writeRow(typePostLinks, func(rw *rowWriter) { encodePostLinkRow(rw, l) })
+}
+
+// WritePost writes a Post record
+func (w *Writer) WritePost(p *Post) error {
+	return w.writeRow(typePosts, func(rw *rowWriter) { encodePostRow(rw, p) })
+}
+
+// WriteTag writes a Tag record
+func (w *Writer) WriteTag(t *Tag) error {
+	return w.writeRow(typeTags, func(rw *rowWriter) { encodeTagRow(rw, t) })
+}
+
+// WriteUser writes a User record
+func (w *Writer) WriteUser(u *User) error {
+	return w.writeRow(typeUsers, func(rw *rowWriter) { encodeUserRow(rw, u) })
+}
+
+// WriteVote writes a Vote record
+func (w *Writer) WriteVote(v *Vote) error {
+	return w.writeRow(typeVotes, func(rw *rowWriter) { encodeVoteRow(rw, v) })
+}
+
+// rowWriter writes attributes of <row> element
+type rowWriter struct {
+	w *bufio.Writer
+}
+
+func (rw *rowWriter) str(name string, v string) {
+	rw.w.WriteString(" " + name + "=\"")
+	xml.EscapeText(rw.w, []byte(v))
+	rw.w.WriteByte('"')
+}
+
+// strOpt writes attribute only if it's not empty
+func (rw *rowWriter) strOpt(name string, v string) {
+	if v != "" {
+		rw.str(name, v)
+	}
+}
+
+func (rw *rowWriter) int(name string, v int) {
+	rw.w.WriteString(" " + name + "=\"" + strconv.Itoa(v) + "\"")
+}
+
+// intOpt writes attribute only if it's not 0
+func (rw *rowWriter) intOpt(name string, v int) {
+	if v != 0 {
+		rw.int(name, v)
+	}
+}
+
+// timeOpt writes attribute only if it's not zero time
+func (rw *rowWriter) timeOpt(name string, v time.Time) {
+	if !v.IsZero() {
+		rw.str(name, encodeTime(v))
+	}
+}
+
+func encodeTime(t time.Time) string {
+	return t.Format(timeFormatWrite)
+}
+
+// encodeTags is the reverse of decodeTags
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "<" + strings.Join(tags, "><") + ">"
+}